@@ -0,0 +1,81 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/cache"
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/logging"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneOpts    = &config.CachePruneOptions{}
+	pruneMaxSize string
+)
+
+func init() {
+	cachePruneCmd.Flags().IntVarP(&pruneOpts.MaxEntries, "max-entries", "", 0, "Maximum number of cache entries to keep, deleting the oldest ones beyond it.")
+	cachePruneCmd.Flags().StringVarP(&pruneMaxSize, "max-size", "", "", "Maximum total size of the layers of the cache entries to keep, e.g. '10GB', deleting the oldest ones beyond it.")
+	cacheCmd.AddCommand(cachePruneCmd)
+	RootCmd.AddCommand(cacheCmd)
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the layer cache",
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete the entries of the layer cache older than --cache-ttl or beyond a budget",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := logging.Configure(logLevel, logFormat, logTimestamp); err != nil {
+			return err
+		}
+		validateFlags()
+		if opts.CacheRepo == "" && len(opts.Destinations) == 0 {
+			return errors.New("you must provide --cache-repo, or --destination to infer it from")
+		}
+		if pruneMaxSize != "" {
+			size, err := units.FromHumanSize(pruneMaxSize)
+			if err != nil {
+				return errors.Wrap(err, "parsing --max-size")
+			}
+			pruneOpts.MaxSize = size
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		pruned, err := cache.PruneCache(opts, pruneOpts, opts.DryRun)
+		if err != nil {
+			exit(errors.Wrap(err, "error pruning cache"))
+		}
+		verb := "Deleted"
+		if opts.DryRun {
+			verb = "Would delete"
+		}
+		for _, entry := range pruned {
+			fmt.Printf("%s %s (created %s, %s)\n", verb, entry.Key, entry.Created.Format(time.RFC3339), units.HumanSize(float64(entry.Size)))
+		}
+	},
+}
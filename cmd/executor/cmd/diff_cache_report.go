@@ -0,0 +1,56 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/executor"
+	"github.com/GoogleContainerTools/kaniko/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.AddCommand(diffCacheReportCmd)
+}
+
+var diffCacheReportCmd = &cobra.Command{
+	Use:   "diff-cache-report <previous report> <current report>",
+	Short: "Show which inputs of the cache keys changed between two builds written with --cache-report",
+	Args:  cobra.ExactArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return logging.Configure(logLevel, logFormat, logTimestamp)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		previous, err := executor.ReadCacheReport(args[0])
+		if err != nil {
+			exit(err)
+		}
+		current, err := executor.ReadCacheReport(args[1])
+		if err != nil {
+			exit(err)
+		}
+		changes := executor.DiffCacheReports(previous, current)
+		if len(changes) == 0 {
+			fmt.Println("No cache key changed")
+			return
+		}
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+	},
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/executor"
+	"github.com/GoogleContainerTools/kaniko/pkg/logging"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var manifestOpts = &config.ManifestOptions{Format: config.OCIIndex}
+
+func init() {
+	manifestOpts.Annotations = make(map[string]string)
+	manifestCmd.Flags().VarP(&manifestOpts.Images, "image", "i", "Image to add to the image index, either a reference to a registry or 'oci-layout://<path>[@<digest>|:<tag>]'. Set it repeatedly for multiple images.")
+	manifestCmd.Flags().VarP(&manifestOpts.Annotations, "annotation", "", "Annotation to set on the image index. Expected format is 'key=value'. Set it repeatedly for multiple annotations.")
+	manifestCmd.Flags().VarP(&manifestOpts.Format, "index-format", "", "Format of the image index (oci, docker)")
+	RootCmd.AddCommand(manifestCmd)
+}
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Assemble images built for different platforms into an image index",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := logging.Configure(logLevel, logFormat, logTimestamp); err != nil {
+			return err
+		}
+		validateFlags()
+		if len(manifestOpts.Images) == 0 {
+			return errors.New("you must provide at least one --image")
+		}
+		if !opts.NoPush && len(opts.Destinations) == 0 {
+			return errors.New("you must provide --destination, or use --no-push")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		index, err := executor.BuildIndex(manifestOpts, opts)
+		if err != nil {
+			exit(errors.Wrap(err, "error building image index"))
+		}
+		if err := executor.DoPushIndex(index, opts); err != nil {
+			exit(errors.Wrap(err, "error pushing image index"))
+		}
+	},
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"os"
@@ -28,6 +29,8 @@ import (
 	"time"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/buildcontext"
+	"github.com/GoogleContainerTools/kaniko/pkg/cache"
+	"github.com/GoogleContainerTools/kaniko/pkg/commands"
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/constants"
 	"github.com/GoogleContainerTools/kaniko/pkg/executor"
@@ -130,7 +133,7 @@ var RootCmd = &cobra.Command{
 
 			resolveEnvironmentBuildArgs(opts.BuildArgs, os.Getenv)
 
-			if !opts.NoPush && len(opts.Destinations) == 0 {
+			if !opts.NoPush && !opts.DryRun && len(opts.Destinations) == 0 {
 				return errors.New("you must provide --destination, or use --no-push")
 			}
 			if err := cacheFlagsValid(); err != nil {
@@ -139,6 +142,9 @@ var RootCmd = &cobra.Command{
 			if err := resolveSourceContext(); err != nil {
 				return errors.Wrap(err, "error resolving source context")
 			}
+			if err := resolveBuildContexts(); err != nil {
+				return errors.Wrap(err, "error resolving build contexts")
+			}
 			if err := resolveDockerfilePath(); err != nil {
 				return errors.Wrap(err, "error resolving dockerfile path")
 			}
@@ -166,10 +172,29 @@ var RootCmd = &cobra.Command{
 					PrefixMatchOnly: false,
 				})
 			}
+			util.AddToDefaultIgnoreList(util.IgnoreListEntry{
+				Path:            commands.CacheMountDir(opts),
+				PrefixMatchOnly: false,
+			})
+			// Secret files must never end up in a layer, even if they live in the root filesystem.
+			for _, s := range opts.Secrets {
+				if s.Source != "" {
+					util.AddToDefaultIgnoreList(util.IgnoreListEntry{
+						Path:            s.Source,
+						PrefixMatchOnly: false,
+					})
+				}
+			}
 		}
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if opts.DryRun {
+			if err := printBuildPlan(); err != nil {
+				exit(errors.Wrap(err, "error planning build"))
+			}
+			return
+		}
 		if !checkContained() {
 			if !force {
 				exit(errors.New("kaniko should only be run inside of a container, run with the --force flag if you are sure you want to continue"))
@@ -248,8 +273,12 @@ func addKanikoOptionsFlags() {
 	RootCmd.PersistentFlags().StringVarP(&opts.Target, "target", "", "", "Set the target build stage to build")
 	RootCmd.PersistentFlags().BoolVarP(&opts.NoPush, "no-push", "", false, "Do not push the image to the registry")
 	RootCmd.PersistentFlags().BoolVarP(&opts.NoPushCache, "no-push-cache", "", false, "Do not push the cache layers to the registry")
-	RootCmd.PersistentFlags().StringVarP(&opts.CacheRepo, "cache-repo", "", "", "Specify a repository to use as a cache, otherwise one will be inferred from the destination provided; when prefixed with 'oci:' the repository will be written in OCI image layout format at the path provided")
+	RootCmd.PersistentFlags().StringVarP(&opts.CacheRepo, "cache-repo", "", "", "Specify a repository to use as a cache, otherwise one will be inferred from the destination provided; when prefixed with 'oci:' the repository will be written in OCI image layout format at the path provided; when an 's3://', 'gs://' or Azure blob storage URL, the layers will be stored in the bucket or container")
+	RootCmd.PersistentFlags().VarP(&opts.CacheMode, "cache-mode", "", "How layers are stored in a registry cache repo: 'layer' pushes an image per cache key, 'index' a single cache index fetched once per build (layer, index)")
+	RootCmd.PersistentFlags().VarP(&opts.CacheFrom, "cache-from", "", "Image whose layers built by kaniko are reused when their cache keys match the build. Set it repeatedly for multiple images.")
 	RootCmd.PersistentFlags().StringVarP(&opts.CacheDir, "cache-dir", "", "/cache", "Specify a local directory to use as a cache.")
+	RootCmd.PersistentFlags().StringVarP(&opts.CacheReport, "cache-report", "", "", "Specify a file to save the inputs of the cache keys of the built commands to, which 'executor diff-cache-report' compares between builds.")
+	RootCmd.PersistentFlags().StringVarP(&opts.CacheMountDir, "cache-mount-dir", "", "", "Specify a local directory to keep RUN --mount=type=cache directories in. Defaults to a directory inside the kaniko directory.")
 	RootCmd.PersistentFlags().StringVarP(&opts.DigestFile, "digest-file", "", "", "Specify a file to save the digest of the built image to.")
 	RootCmd.PersistentFlags().StringVarP(&opts.ImageNameDigestFile, "image-name-with-digest-file", "", "", "Specify a file to save the image name w/ digest of the built image to.")
 	RootCmd.PersistentFlags().StringVarP(&opts.ImageNameTagDigestFile, "image-name-tag-with-digest-file", "", "", "Specify a file to save the image name w/ image tag w/ digest of the built image to.")
@@ -272,6 +301,10 @@ func addKanikoOptionsFlags() {
 	RootCmd.PersistentFlags().BoolVarP(&opts.SkipDefaultRegistryFallback, "skip-default-registry-fallback", "", false, "If an image is not found on any mirrors (defined with registry-mirror) do not fallback to the default registry. If registry-mirror is not defined, this flag is ignored.")
 	RootCmd.PersistentFlags().BoolVarP(&opts.IgnoreVarRun, "ignore-var-run", "", true, "Ignore /var/run directory when taking image snapshot. Set it to false to preserve /var/run/ in destination image.")
 	RootCmd.PersistentFlags().VarP(&opts.Labels, "label", "", "Set metadata for an image. Set it repeatedly for multiple labels.")
+	opts.Secrets = make(map[string]config.Secret)
+	RootCmd.PersistentFlags().VarP(&opts.Secrets, "secret", "", "Secret to expose to RUN --mount=type=secret instructions. Expected format is 'id=mysecret,src=/path/to/secret' or 'id=mysecret,env=ENV_VAR'. Set it repeatedly for multiple secrets.")
+	opts.BuildContexts = make(map[string]string)
+	RootCmd.PersistentFlags().VarP(&opts.BuildContexts, "build-context", "", "Additional build context which FROM, COPY --from and RUN --mount=from= can refer to by name. Expected format is 'name=source', where source is a directory, a build context like for --context, 'docker-image://<image>' or 'oci-layout://<path>[@<digest>|:<tag>]'. Set it repeatedly for multiple contexts.")
 	RootCmd.PersistentFlags().BoolVarP(&opts.SkipUnusedStages, "skip-unused-stages", "", false, "Build only used stages if defined to true. Otherwise it builds by default all stages, even the unnecessaries ones until it reaches the target stage / end of Dockerfile")
 	RootCmd.PersistentFlags().BoolVarP(&opts.RunV2, "use-new-run", "", false, "Use the experimental run implementation for detecting changes without requiring file system snapshots.")
 	RootCmd.PersistentFlags().Var(&opts.Git, "git", "Branch to clone if build context is a git repository")
@@ -280,6 +313,7 @@ func addKanikoOptionsFlags() {
 	RootCmd.PersistentFlags().VarP(&opts.IgnorePaths, "ignore-path", "", "Ignore these paths when taking a snapshot. Set it repeatedly for multiple paths.")
 	RootCmd.PersistentFlags().BoolVarP(&opts.ForceBuildMetadata, "force-build-metadata", "", false, "Force add metadata layers to build image")
 	RootCmd.PersistentFlags().BoolVarP(&opts.SkipPushPermissionCheck, "skip-push-permission-check", "", false, "Skip check of the push permission")
+	RootCmd.PersistentFlags().BoolVarP(&opts.DryRun, "dry-run", "", false, "Print the build plan as JSON without building the image: the stages to build, their base images, and the cache keys of their commands with whether they are found in the cache.")
 
 	// Deprecated flags.
 	RootCmd.PersistentFlags().StringVarP(&opts.SnapshotModeDeprecated, "snapshotMode", "", "", "This flag is deprecated. Please use '--snapshot-mode'.")
@@ -342,6 +376,9 @@ func checkNoDeprecatedFlags() {
 // cacheFlagsValid makes sure the flags passed in related to caching are valid
 func cacheFlagsValid() error {
 	if !opts.Cache {
+		if opts.CacheReport != "" && len(opts.CacheFrom) == 0 {
+			return errors.New("--cache-report requires --cache or --cache-from")
+		}
 		return nil
 	}
 	// If --cache=true and --no-push=true, then cache repo must be provided
@@ -349,6 +386,9 @@ func cacheFlagsValid() error {
 	if opts.CacheRepo == "" && opts.NoPush {
 		return errors.New("if using cache with --no-push, specify cache repo with --cache-repo")
 	}
+	if opts.CacheMode == config.CacheModeIndex && (strings.HasPrefix(opts.CacheRepo, "oci:") || cache.IsObjectStorage(opts.CacheRepo)) {
+		return errors.New("--cache-mode=index requires a registry cache repo")
+	}
 	return nil
 }
 
@@ -445,6 +485,26 @@ func resolveSourceContext() error {
 	return nil
 }
 
+// resolveBuildContexts unpacks the named build contexts which aren't images,
+// and resets their source to the directory they reside in
+func resolveBuildContexts() error {
+	if len(opts.BuildContexts) == 0 {
+		return nil
+	}
+	contexts, err := buildcontext.UnpackNamedBuildContexts(opts.BuildContexts, buildcontext.BuildOptions{
+		GitBranch:            opts.Git.Branch,
+		GitSingleBranch:      opts.Git.SingleBranch,
+		GitRecurseSubmodules: opts.Git.RecurseSubmodules,
+		InsecureSkipTLS:      opts.Git.InsecureSkipTLS,
+	})
+	if err != nil {
+		return err
+	}
+	opts.BuildContexts = contexts
+	logrus.Debugf("Build contexts located at %v", opts.BuildContexts)
+	return nil
+}
+
 func resolveRelativePaths() error {
 	optsPaths := []*string{
 		&opts.DockerfilePath,
@@ -452,6 +512,7 @@ func resolveRelativePaths() error {
 		&opts.CacheDir,
 		&opts.TarPath,
 		&opts.DigestFile,
+		&opts.CacheReport,
 		&opts.ImageNameDigestFile,
 		&opts.ImageNameTagDigestFile,
 	}
@@ -473,6 +534,24 @@ func resolveRelativePaths() error {
 	return nil
 }
 
+// printBuildPlan prints the plan of the build to stdout, leaving the logs on
+// stderr. Nothing is unpacked, so kaniko doesn't need to run in a container.
+func printBuildPlan() error {
+	if err := resolveRelativePaths(); err != nil {
+		return errors.Wrap(err, "error resolving relative paths to absolute paths")
+	}
+	plan, err := executor.DoBuildPlan(opts)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
 func exit(err error) {
 	var execErr *exec.ExitError
 	if errors.As(err, &execErr) {
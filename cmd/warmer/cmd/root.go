@@ -26,9 +26,12 @@ import (
 
 	"github.com/GoogleContainerTools/kaniko/pkg/cache"
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/constants"
+	"github.com/GoogleContainerTools/kaniko/pkg/executor"
 	"github.com/GoogleContainerTools/kaniko/pkg/logging"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/containerd/containerd/platforms"
+	"github.com/docker/go-units"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pkg/errors"
@@ -41,6 +44,7 @@ var (
 	logLevel     string
 	logFormat    string
 	logTimestamp bool
+	cacheMaxSize string
 )
 
 func init() {
@@ -87,6 +91,36 @@ var RootCmd = &cobra.Command{
 			}
 		}
 
+		if opts.LayerCacheRepo != "" {
+			if err := validateLayerCacheFlags(); err != nil {
+				return err
+			}
+		}
+
+		if opts.Parallelism < 1 {
+			return errors.New("--parallelism must be at least 1")
+		}
+
+		if len(opts.Platforms) > 0 && !opts.OCILayout {
+			return errors.New("--platform requires --oci-layout")
+		}
+		for _, platform := range opts.Platforms {
+			if _, err := v1.ParsePlatform(platform); err != nil {
+				return errors.Wrapf(err, "invalid platform %q", platform)
+			}
+		}
+
+		if cacheMaxSize != "" {
+			if opts.OCILayout {
+				return errors.New("--cache-max-size is not supported with --oci-layout")
+			}
+			size, err := units.FromHumanSize(cacheMaxSize)
+			if err != nil {
+				return errors.Wrap(err, "parsing --cache-max-size")
+			}
+			opts.CacheMaxSize = size
+		}
+
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -99,6 +133,13 @@ var RootCmd = &cobra.Command{
 		if err := cache.WarmCache(opts); err != nil {
 			exit(errors.Wrap(err, "Failed warming cache"))
 		}
+		if opts.LayerCacheRepo != "" {
+			warmed, err := executor.WarmLayerCache(layerCacheOptions(), opts.LayerCacheRepo)
+			if err != nil {
+				exit(errors.Wrap(err, "Failed warming layer cache"))
+			}
+			logrus.Infof("Warmed %d cached layers", len(warmed))
+		}
 
 	},
 }
@@ -109,6 +150,11 @@ func addKanikoOptionsFlags() {
 	RootCmd.PersistentFlags().StringVarP(&opts.CacheDir, "cache-dir", "c", "/cache", "Directory of the cache.")
 	RootCmd.PersistentFlags().BoolVarP(&opts.Force, "force", "f", false, "Force cache overwriting.")
 	RootCmd.PersistentFlags().DurationVarP(&opts.CacheTTL, "cache-ttl", "", time.Hour*336, "Cache timeout in hours. Defaults to two weeks.")
+	RootCmd.PersistentFlags().StringVarP(&cacheMaxSize, "cache-max-size", "", "", "Maximum size of the cache directory, e.g. '50GB', evicting the least recently used images beyond it.")
+	RootCmd.PersistentFlags().BoolVarP(&opts.OCILayout, "oci-layout", "", false, "Write the images to the cache directory as an OCI image layout, keeping the image index of multi-platform images.")
+	RootCmd.PersistentFlags().VarP(&opts.Platforms, "platform", "", "Platform of the images of multi-platform images to keep with --oci-layout, all of them if not set. Set it repeatedly for multiple platforms.")
+	RootCmd.PersistentFlags().IntVarP(&opts.Parallelism, "parallelism", "", 4, "Maximum number of images, and of layers, downloaded at once.")
+	RootCmd.PersistentFlags().IntVarP(&opts.ImageDownloadRetry, "image-download-retry", "", 3, "Number of retries for downloading the remote image and each of its layers, resuming the downloads of the layers.")
 	RootCmd.PersistentFlags().BoolVarP(&opts.InsecurePull, "insecure-pull", "", false, "Pull from insecure registry using plain HTTP")
 	RootCmd.PersistentFlags().BoolVarP(&opts.SkipTLSVerifyPull, "skip-tls-verify-pull", "", false, "Pull from insecure registry ignoring TLS verify")
 	RootCmd.PersistentFlags().VarP(&opts.InsecureRegistries, "insecure-registry", "", "Insecure registry using plain HTTP to pull. Set it repeatedly for multiple registries.")
@@ -124,6 +170,11 @@ func addKanikoOptionsFlags() {
 	RootCmd.PersistentFlags().StringVarP(&opts.CustomPlatform, "customPlatform", "", "", "Specify the build platform if different from the current host")
 	RootCmd.PersistentFlags().StringVarP(&opts.DockerfilePath, "dockerfile", "d", "", "Path to the dockerfile to be cached. The kaniko warmer will parse and write out each stage's base image layers to the cache-dir. Using the same dockerfile path as what you plan to build in the kaniko executor is the expected usage.")
 	RootCmd.PersistentFlags().VarP(&opts.BuildArgs, "build-arg", "", "This flag should be used in conjunction with the dockerfile flag for scenarios where dynamic replacement of the base image is required.")
+	RootCmd.PersistentFlags().StringVarP(&opts.LayerCacheRepo, "layer-cache-repo", "", "", "'oci:' cache repo to copy the cached layers of the commands of the dockerfile to from --cache-repo. Use it as --cache-repo of the executor.")
+	RootCmd.PersistentFlags().StringVarP(&opts.CacheRepo, "cache-repo", "", "", "Remote cache repo to copy the cached layers from, requires --layer-cache-repo.")
+	RootCmd.PersistentFlags().StringVarP(&opts.SrcContext, "context", "", "", "Path to the build context of the dockerfile, requires --layer-cache-repo.")
+	RootCmd.PersistentFlags().BoolVarP(&opts.CacheCopyLayers, "cache-copy-layers", "", false, "Copy the cached copy layers, like the executor flag of the same name.")
+	RootCmd.PersistentFlags().BoolVarP(&opts.CacheRunLayers, "cache-run-layers", "", true, "Copy the cached run layers, like the executor flag of the same name.")
 
 	// Default the custom platform flag to our current platform, and validate it.
 	if opts.CustomPlatform == "" {
@@ -155,6 +206,42 @@ func validateDockerfilePath() error {
 	return errors.New("please provide a valid path to a Dockerfile within the build context with --dockerfile")
 }
 
+// validateLayerCacheFlags makes sure the flags to warm the layer cache are
+// valid, resolving the context to an absolute path.
+func validateLayerCacheFlags() error {
+	if !strings.HasPrefix(opts.LayerCacheRepo, "oci:") {
+		return errors.New("--layer-cache-repo must be an 'oci:' cache repo")
+	}
+	if opts.DockerfilePath == "" || opts.CacheRepo == "" || opts.SrcContext == "" {
+		return errors.New("--layer-cache-repo requires --dockerfile, --cache-repo and --context")
+	}
+	abs, err := filepath.Abs(opts.SrcContext)
+	if err != nil {
+		return errors.Wrap(err, "getting absolute path for context")
+	}
+	opts.SrcContext = abs
+	return nil
+}
+
+// layerCacheOptions returns the executor options the layer cache is warmed
+// for: cache keys depend on the dockerfile, its context and build args. The
+// snapshot mode is the default one of the executor.
+func layerCacheOptions() *config.KanikoOptions {
+	return &config.KanikoOptions{
+		SnapshotMode:    constants.SnapshotModeFull,
+		RegistryOptions: opts.RegistryOptions,
+		CacheOptions:    opts.CacheOptions,
+		DockerfilePath:  opts.DockerfilePath,
+		SrcContext:      opts.SrcContext,
+		BuildArgs:       opts.BuildArgs,
+		CustomPlatform:  opts.CustomPlatform,
+		CacheRepo:       opts.CacheRepo,
+		Cache:           true,
+		CacheCopyLayers: opts.CacheCopyLayers,
+		CacheRunLayers:  opts.CacheRunLayers,
+	}
+}
+
 func isURL(path string) bool {
 	if match, _ := regexp.MatchString("^https?://", path); match {
 		return true
@@ -18,46 +18,30 @@ package buildcontext
 
 import (
 	"context"
-	"errors"
 	"os"
 	"path/filepath"
-	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
-	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/constants"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/pkg/util/bucket"
 )
 
 // AzureBlob struct for Azure Blob Storage processing
 type AzureBlob struct {
-	context string
+	context   string
+	directory string
 }
 
 // Download context file from given azure blob storage url and unpack it to BuildContextDir
 func (b *AzureBlob) UnpackTarFromBuildContext() (string, error) {
 
-	// Get Azure_STORAGE_ACCESS_KEY from environment variables
-	accountKey := os.Getenv("AZURE_STORAGE_ACCESS_KEY")
-	if len(accountKey) == 0 {
-		return "", errors.New("AZURE_STORAGE_ACCESS_KEY environment variable is not set")
-	}
-
-	// Get storage accountName for Azure Blob Storage
-	parts, err := azblob.ParseURL(b.context)
-	if err != nil {
-		return parts.Host, err
-	}
-	accountName := strings.Split(parts.Host, ".")[0]
-
-	// Generate credential with accountName and accountKey
-	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	client, parts, err := bucket.NewAzureBlobClient(b.context)
 	if err != nil {
 		return parts.Host, err
 	}
 
 	// Create directory and target file for downloading the context file
-	directory := kConfig.BuildContextDir
+	directory := b.directory
 	tarPath := filepath.Join(directory, constants.ContextTar)
 	file, err := util.CreateTargetTarfile(tarPath)
 	if err != nil {
@@ -65,10 +49,6 @@ func (b *AzureBlob) UnpackTarFromBuildContext() (string, error) {
 	}
 
 	// Downloading context file from Azure Blob Storage
-	client, err := azblob.NewClientWithSharedKeyCredential(b.context, credential, nil)
-	if err != nil {
-		return parts.Host, err
-	}
 	ctx := context.Background()
 
 	if _, err := client.DownloadFile(ctx, parts.ContainerName, parts.BlobName, file, nil); err != nil {
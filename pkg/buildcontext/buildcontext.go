@@ -20,6 +20,7 @@ import (
 	"errors"
 	"strings"
 
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/constants"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 )
@@ -33,6 +34,9 @@ type BuildOptions struct {
 	GitSingleBranch      bool
 	GitRecurseSubmodules bool
 	InsecureSkipTLS      bool
+	// Directory is where remote build contexts are unpacked to, the
+	// BuildContextDir by default.
+	Directory string
 }
 
 // BuildContext unifies calls to download and unpack the build context.
@@ -49,22 +53,27 @@ func GetBuildContext(srcContext string, opts BuildOptions) (BuildContext, error)
 		prefix := split[0]
 		context := split[1]
 
+		directory := opts.Directory
+		if directory == "" {
+			directory = kConfig.BuildContextDir
+		}
+
 		switch prefix {
 		case constants.GCSBuildContextPrefix:
-			return &GCS{context: srcContext}, nil
+			return &GCS{context: srcContext, directory: directory}, nil
 		case constants.S3BuildContextPrefix:
-			return &S3{context: srcContext}, nil
+			return &S3{context: srcContext, directory: directory}, nil
 		case constants.LocalDirBuildContextPrefix:
 			return &Dir{context: context}, nil
 		case constants.GitBuildContextPrefix:
-			return &Git{context: context, opts: opts}, nil
+			return &Git{context: context, opts: opts, directory: directory}, nil
 		case constants.HTTPSBuildContextPrefix:
 			if util.ValidAzureBlobStorageHost(srcContext) {
-				return &AzureBlob{context: srcContext}, nil
+				return &AzureBlob{context: srcContext, directory: directory}, nil
 			}
-			return &HTTPSTar{context: srcContext}, nil
+			return &HTTPSTar{context: srcContext, directory: directory}, nil
 		case TarBuildContextPrefix:
-			return &Tar{context: context}, nil
+			return &Tar{context: context, directory: directory}, nil
 		}
 	}
 	return nil, errors.New("unknown build context prefix provided, please use one of the following: gs://, dir://, tar://, s3://, git://, https://")
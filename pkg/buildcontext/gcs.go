@@ -22,7 +22,6 @@ import (
 	"os"
 	"path/filepath"
 
-	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/constants"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/GoogleContainerTools/kaniko/pkg/util/bucket"
@@ -32,7 +31,8 @@ import (
 
 // GCS struct for Google Cloud Storage processing
 type GCS struct {
-	context string
+	context   string
+	directory string
 }
 
 func (g *GCS) UnpackTarFromBuildContext() (string, error) {
@@ -40,7 +40,7 @@ func (g *GCS) UnpackTarFromBuildContext() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("getting bucketname and filepath from context: %w", err)
 	}
-	return kConfig.BuildContextDir, unpackTarFromGCSBucket(bucketName, filepath, kConfig.BuildContextDir)
+	return g.directory, unpackTarFromGCSBucket(bucketName, filepath, g.directory)
 }
 
 func UploadToBucket(r io.Reader, dest string) error {
@@ -19,10 +19,10 @@ package buildcontext
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 
-	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
@@ -31,6 +31,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/sirupsen/logrus"
 )
 
@@ -50,13 +51,14 @@ var (
 
 // Git unifies calls to download and unpack the build context.
 type Git struct {
-	context string
-	opts    BuildOptions
+	context   string
+	opts      BuildOptions
+	directory string
 }
 
 // UnpackTarFromBuildContext will provide the directory where Git Repository is Cloned
 func (g *Git) UnpackTarFromBuildContext() (string, error) {
-	directory := kConfig.BuildContextDir
+	directory := g.directory
 	parts := strings.Split(g.context, "#")
 	url := getGitPullMethod() + "://" + parts[0]
 	options := git.CloneOptions{
@@ -207,3 +209,137 @@ func getGitPullMethod() string {
 	}
 	return gitPullMethod
 }
+
+// GitSource is a git repository used as a source of ADD, in the form
+// <url>[#<ref>[:<subdir>]].
+type GitSource struct {
+	URL    string
+	Ref    string
+	Subdir string
+}
+
+// ParseGitSource returns the git repository referred to by src, and false if
+// src is not one. Repositories are recognized by a git@ or git:// prefix, or
+// by an http(s), ssh or file URL ending in .git.
+func ParseGitSource(src string) (GitSource, bool) {
+	remote, fragment, _ := strings.Cut(src, "#")
+	if !isGitURL(remote) {
+		return GitSource{}, false
+	}
+	ref, subdir, _ := strings.Cut(fragment, ":")
+	return GitSource{URL: remote, Ref: ref, Subdir: subdir}, true
+}
+
+func isGitURL(remote string) bool {
+	if strings.HasPrefix(remote, "git@") || strings.HasPrefix(remote, "git://") {
+		return true
+	}
+	u, err := url.Parse(remote)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "ssh", "file":
+		return strings.HasSuffix(u.Path, ".git")
+	}
+	return false
+}
+
+// Resolve returns the commit the ref of the repository points to, without
+// cloning it. The default branch is used if no ref is given.
+func (s GitSource) Resolve() (string, error) {
+	if plumbing.IsHash(s.Ref) {
+		return s.Ref, nil
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{s.URL},
+	})
+	refs, err := remote.List(&git.ListOptions{
+		Auth:          s.auth(),
+		PeelingOption: git.AppendPeeled,
+	})
+	if err != nil {
+		return "", fmt.Errorf("listing refs of %s: %w", s.URL, err)
+	}
+	hashes := map[plumbing.ReferenceName]plumbing.Hash{}
+	var head plumbing.ReferenceName
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			head = ref.Target()
+			continue
+		}
+		hashes[ref.Name()] = ref.Hash()
+	}
+
+	candidates := []plumbing.ReferenceName{plumbing.HEAD, head}
+	if s.Ref != "" {
+		candidates = []plumbing.ReferenceName{
+			plumbing.NewBranchReferenceName(s.Ref),
+			plumbing.NewTagReferenceName(s.Ref),
+			plumbing.ReferenceName(s.Ref),
+		}
+	}
+	for _, name := range candidates {
+		// Annotated tags are peeled to the commit they point to.
+		if hash, ok := hashes[name+"^{}"]; ok {
+			return hash.String(), nil
+		}
+		if hash, ok := hashes[name]; ok {
+			return hash.String(), nil
+		}
+	}
+	return "", fmt.Errorf("ref %q not found in %s", s.Ref, s.URL)
+}
+
+// Clone clones the repository into directory, including its submodules, and
+// checks out commit, as returned by Resolve.
+func (s GitSource) Clone(directory, commit string) error {
+	r, err := git.PlainClone(directory, false, &git.CloneOptions{
+		URL:        s.URL,
+		Auth:       s.auth(),
+		NoCheckout: true,
+	})
+	if err != nil {
+		return err
+	}
+	// A commit which isn't reachable from a branch or tag, like a pull
+	// request, isn't part of the clone and is fetched separately.
+	hash := plumbing.NewHash(commit)
+	if _, err := r.CommitObject(hash); err != nil && s.Ref != "" && !plumbing.IsHash(s.Ref) {
+		err = r.Fetch(&git.FetchOptions{
+			RemoteName: "origin",
+			Auth:       s.auth(),
+			RefSpecs:   []config.RefSpec{config.RefSpec(s.Ref + ":" + s.Ref)},
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return err
+		}
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: hash}); err != nil {
+		return fmt.Errorf("checking out %s: %w", commit, err)
+	}
+	submodules, err := w.Submodules()
+	if err != nil {
+		return err
+	}
+	return submodules.Update(&git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: getRecurseSubmodules(true),
+		Auth:              s.auth(),
+	})
+}
+
+// auth returns the credentials of the GIT_* environment variables, which
+// only apply to repositories over http(s).
+func (s GitSource) auth() transport.AuthMethod {
+	if strings.HasPrefix(s.URL, "http://") || strings.HasPrefix(s.URL, "https://") {
+		return getGitAuth()
+	}
+	return nil
+}
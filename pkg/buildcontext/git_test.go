@@ -18,6 +18,9 @@ package buildcontext
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/GoogleContainerTools/kaniko/testutil"
@@ -189,3 +192,130 @@ func clearTestAuthEnv() {
 	_ = os.Unsetenv(gitAuthUsernameEnvKey)
 	_ = os.Unsetenv(gitAuthPasswordEnvKey)
 }
+
+func TestParseGitSource(t *testing.T) {
+	tests := []struct {
+		src    string
+		want   GitSource
+		isRepo bool
+	}{
+		{
+			src:    "https://github.com/org/repo.git",
+			want:   GitSource{URL: "https://github.com/org/repo.git"},
+			isRepo: true,
+		},
+		{
+			src:    "https://github.com/org/repo.git#v1.2.3",
+			want:   GitSource{URL: "https://github.com/org/repo.git", Ref: "v1.2.3"},
+			isRepo: true,
+		},
+		{
+			src:    "git@github.com:org/repo.git#main:docs",
+			want:   GitSource{URL: "git@github.com:org/repo.git", Ref: "main", Subdir: "docs"},
+			isRepo: true,
+		},
+		{
+			src:    "git://example.com/repo#:sub/dir",
+			want:   GitSource{URL: "git://example.com/repo", Subdir: "sub/dir"},
+			isRepo: true,
+		},
+		{
+			src:    "file:///srv/repo.git#refs/pull/1/head",
+			want:   GitSource{URL: "file:///srv/repo.git", Ref: "refs/pull/1/head"},
+			isRepo: true,
+		},
+		{src: "https://example.com/file.tar.gz"},
+		{src: "https://example.com/repo.git.tar.gz"},
+		{src: "repo.git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, ok := ParseGitSource(tt.src)
+			testutil.CheckDeepEqual(t, tt.isRepo, ok)
+			testutil.CheckDeepEqual(t, tt.want, got)
+		})
+	}
+}
+
+// gitCmd runs git in dir and returns its trimmed output.
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	args = append([]string{
+		"-c", "user.name=kaniko", "-c", "user.email=kaniko@example.com",
+		"-c", "init.defaultBranch=main", "-c", "protocol.file.allow=always",
+	}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func gitCommit(t *testing.T, dir, file, content string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, dir, "add", file)
+	gitCmd(t, dir, "commit", "-m", file)
+	return gitCmd(t, dir, "rev-parse", "HEAD")
+}
+
+func TestGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required to serve file:// repositories")
+	}
+	tmp := t.TempDir()
+
+	sub := filepath.Join(tmp, "sub")
+	gitCmd(t, tmp, "init", sub)
+	gitCommit(t, sub, "sub.txt", "submodule")
+
+	work := filepath.Join(tmp, "work")
+	gitCmd(t, tmp, "init", work)
+	tagged := gitCommit(t, work, "README", "v1")
+	gitCmd(t, work, "tag", "-a", "v1.0.0", "-m", "v1.0.0")
+	gitCmd(t, work, "submodule", "add", "file://"+sub, "lib")
+	head := gitCommit(t, work, "README", "v2")
+	gitCmd(t, work, "checkout", "-b", "dev")
+	dev := gitCommit(t, work, "README", "dev")
+	gitCmd(t, work, "checkout", "main")
+	gitCmd(t, tmp, "clone", "--bare", work, filepath.Join(tmp, "repo.git"))
+	url := "file://" + filepath.Join(tmp, "repo.git")
+
+	tests := []struct {
+		ref      string
+		want     string
+		readme   string
+		withLib  bool
+		notFound bool
+	}{
+		{ref: "", want: head, readme: "v2", withLib: true},
+		{ref: "main", want: head, readme: "v2", withLib: true},
+		{ref: "v1.0.0", want: tagged, readme: "v1"},
+		{ref: "dev", want: dev, readme: "dev", withLib: true},
+		{ref: tagged, want: tagged, readme: "v1"},
+		{ref: "missing", notFound: true},
+	}
+	for _, tt := range tests {
+		t.Run("ref="+tt.ref, func(t *testing.T) {
+			source, ok := ParseGitSource(url + "#" + tt.ref)
+			testutil.CheckDeepEqual(t, true, ok)
+
+			commit, err := source.Resolve()
+			testutil.CheckErrorAndDeepEqual(t, tt.notFound, err, tt.want, commit)
+			if tt.notFound {
+				return
+			}
+
+			dir := t.TempDir()
+			testutil.CheckNoError(t, source.Clone(dir, commit))
+			readme, err := os.ReadFile(filepath.Join(dir, "README"))
+			testutil.CheckErrorAndDeepEqual(t, false, err, tt.readme, string(readme))
+			_, err = os.Stat(filepath.Join(dir, "lib", "sub.txt"))
+			testutil.CheckDeepEqual(t, tt.withLib, err == nil)
+		})
+	}
+}
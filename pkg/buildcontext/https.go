@@ -23,7 +23,6 @@ import (
 	"os"
 	"path/filepath"
 
-	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/constants"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/sirupsen/logrus"
@@ -31,7 +30,8 @@ import (
 
 // HTTPSTar struct for https tar.gz files processing
 type HTTPSTar struct {
-	context string
+	context   string
+	directory string
 }
 
 // UnpackTarFromBuildContext downloads context file from https server
@@ -40,7 +40,7 @@ func (h *HTTPSTar) UnpackTarFromBuildContext() (directory string, err error) {
 	logrus.Info("Retrieving https tar file")
 
 	// Create directory and target file for downloading the context file
-	directory = kConfig.BuildContextDir
+	directory = h.directory
 	tarPath := filepath.Join(directory, constants.ContextTar)
 	file, err := util.CreateTargetTarfile(tarPath)
 	if err != nil {
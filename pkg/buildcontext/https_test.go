@@ -56,7 +56,8 @@ func TestBuildWithHttpsTar(t *testing.T) {
 			defer server.Close()
 
 			context := &HTTPSTar{
-				context: server.URL + "/data.tar.gz",
+				context:   server.URL + "/data.tar.gz",
+				directory: t.TempDir(),
 			}
 
 			_, err := context.UnpackTarFromBuildContext()
@@ -0,0 +1,97 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buildcontext
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/constants"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// UnpackNamedBuildContexts unpacks the build contexts given a name with
+// --build-context. It returns their sources with the ones which aren't images
+// replaced by the dir:// of the directory they were unpacked to, which is
+// inside the kaniko directory for remote contexts. Sources without a prefix
+// are local directories, like for --context.
+func UnpackNamedBuildContexts(contexts map[string]string, opts BuildOptions) (map[string]string, error) {
+	names := make([]string, 0, len(contexts))
+	for name := range contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := make(map[string]string, len(contexts))
+	for i, name := range names {
+		source := contexts[name]
+		if name == "" {
+			return nil, fmt.Errorf("build context %s has no name", source)
+		}
+		switch {
+		case strings.HasPrefix(source, constants.DockerImageBuildContextPrefix):
+			resolved[name] = source
+			continue
+		case strings.HasPrefix(source, constants.OCILayoutBuildContextPrefix):
+			path, ref := ParseOCILayout(source)
+			path, err := filepath.Abs(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "resolving build context %s", name)
+			}
+			resolved[name] = constants.OCILayoutBuildContextPrefix + path + ref
+			continue
+		case !strings.Contains(source, "://"):
+			source = constants.LocalDirBuildContextPrefix + source
+		}
+
+		contextOpts := opts
+		contextOpts.Directory = filepath.Join(kConfig.KanikoDir, "contexts", strconv.Itoa(i))
+		buildContext, err := GetBuildContext(source, contextOpts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "build context %s", name)
+		}
+		logrus.Debugf("Getting build context %s from %s", name, source)
+		dir, err := buildContext.UnpackTarFromBuildContext()
+		if err != nil {
+			return nil, errors.Wrapf(err, "unpacking build context %s", name)
+		}
+		if dir, err = filepath.Abs(dir); err != nil {
+			return nil, errors.Wrapf(err, "resolving build context %s", name)
+		}
+		resolved[name] = constants.LocalDirBuildContextPrefix + dir
+	}
+	return resolved, nil
+}
+
+// ParseOCILayout splits the source of an oci-layout:// build context into the
+// path of the layout and the reference of the image in it, which is either
+// empty, @<digest> or :<tag>.
+func ParseOCILayout(source string) (string, string) {
+	path := strings.TrimPrefix(source, constants.OCILayoutBuildContextPrefix)
+	if i := strings.LastIndex(path, "@"); i >= 0 {
+		return path[:i], path[i:]
+	}
+	if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		return path[:i], path[i:]
+	}
+	return path, ""
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buildcontext
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+)
+
+func TestUnpackNamedBuildContexts(t *testing.T) {
+	kanikoDir := t.TempDir()
+	original := kConfig.KanikoDir
+	kConfig.KanikoDir = kanikoDir
+	t.Cleanup(func() { kConfig.KanikoDir = original })
+
+	// A tar.gz holding a single file.
+	tarPath := filepath.Join(t.TempDir(), "context.tar.gz")
+	f, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	content := []byte("from tar")
+	if err := tw.WriteHeader(&tar.Header{Name: "file", Mode: 0o644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []interface{ Close() error }{tw, gw, f} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	localDir := t.TempDir()
+	t.Chdir(filepath.Dir(localDir))
+
+	contexts, err := UnpackNamedBuildContexts(map[string]string{
+		"archive": "tar://" + tarPath,
+		"base":    "docker-image://alpine:3.19",
+		"deps":    filepath.Base(localDir),
+		"layout":  "oci-layout://layout:v1",
+		"shared":  "dir://" + localDir,
+	}, BuildOptions{})
+	testutil.CheckError(t, false, err)
+	testutil.CheckDeepEqual(t, map[string]string{
+		"archive": "dir://" + filepath.Join(kanikoDir, "contexts", "0"),
+		"base":    "docker-image://alpine:3.19",
+		"deps":    "dir://" + localDir,
+		"layout":  "oci-layout://" + filepath.Join(filepath.Dir(localDir), "layout") + ":v1",
+		"shared":  "dir://" + localDir,
+	}, contexts)
+
+	unpacked, err := os.ReadFile(filepath.Join(kanikoDir, "contexts", "0", "file"))
+	testutil.CheckErrorAndDeepEqual(t, false, err, content, unpacked)
+
+	_, err = UnpackNamedBuildContexts(map[string]string{"": "dir:///deps"}, BuildOptions{})
+	testutil.CheckError(t, true, err)
+}
+
+func TestParseOCILayout(t *testing.T) {
+	tests := []struct {
+		source   string
+		wantPath string
+		wantRef  string
+	}{
+		{source: "oci-layout:///layouts/app", wantPath: "/layouts/app"},
+		{source: "oci-layout:///layouts/app:v1", wantPath: "/layouts/app", wantRef: ":v1"},
+		{source: "oci-layout:///layouts/app@sha256:abc", wantPath: "/layouts/app", wantRef: "@sha256:abc"},
+		{source: "oci-layout://layouts:1/app", wantPath: "layouts:1/app"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			path, ref := ParseOCILayout(test.source)
+			testutil.CheckDeepEqual(t, test.wantPath, path)
+			testutil.CheckDeepEqual(t, test.wantRef, ref)
+		})
+	}
+}
@@ -21,56 +21,34 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
-	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/constants"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/GoogleContainerTools/kaniko/pkg/util/bucket"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	s3manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 // S3 unifies calls to download and unpack the build context.
 type S3 struct {
-	context string
+	context   string
+	directory string
 }
 
 // UnpackTarFromBuildContext download and untar a file from s3
 func (s *S3) UnpackTarFromBuildContext() (string, error) {
+	client, err := bucket.NewS3Client(context.TODO())
+	if err != nil {
+		return "", err
+	}
 	bucket, item, err := bucket.GetNameAndFilepathFromURI(s.context)
 	if err != nil {
 		return "", fmt.Errorf("getting bucketname and filepath from context: %w", err)
 	}
 
-	endpoint := os.Getenv(constants.S3EndpointEnv)
-	forcePath := false
-	if strings.ToLower(os.Getenv(constants.S3ForcePathStyle)) == "true" {
-		forcePath = true
-	}
-
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		if endpoint != "" {
-			return aws.Endpoint{
-				URL: endpoint,
-			}, nil
-		}
-		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
-	})
-
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithEndpointResolverWithOptions(customResolver))
-	if err != nil {
-		return bucket, err
-	}
-	client := s3.NewFromConfig(cfg, func(options *s3.Options) {
-		if endpoint != "" {
-			options.UsePathStyle = forcePath
-		}
-	})
 	downloader := s3manager.NewDownloader(client)
-	directory := kConfig.BuildContextDir
+	directory := s.directory
 	tarPath := filepath.Join(directory, constants.ContextTar)
 	if err := os.MkdirAll(directory, 0750); err != nil {
 		return directory, err
@@ -21,7 +21,6 @@ import (
 	"fmt"
 	"os"
 
-	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -29,12 +28,13 @@ import (
 
 // Tar unifies calls to download and unpack the build context.
 type Tar struct {
-	context string
+	context   string
+	directory string
 }
 
 // UnpackTarFromBuildContext unpack the compressed tar file
 func (t *Tar) UnpackTarFromBuildContext() (string, error) {
-	directory := kConfig.BuildContextDir
+	directory := t.directory
 	if err := os.MkdirAll(directory, 0750); err != nil {
 		return "", errors.Wrap(err, "unpacking tar from build context")
 	}
@@ -157,18 +157,29 @@ func locateImage(path string) (v1.Image, error) {
 // Destination returns the repo where the layer should be stored
 // If no cache is specified, one is inferred from the destination provided
 func Destination(opts *config.KanikoOptions, cacheKey string) (string, error) {
-	cache := opts.CacheRepo
-	if cache == "" {
-		destination := opts.Destinations[0]
-		destRef, err := name.NewTag(destination, name.WeakValidation)
-		if err != nil {
-			return "", errors.Wrap(err, "getting tag for destination")
-		}
-		return fmt.Sprintf("%s/cache:%s", destRef.Context(), cacheKey), nil
+	cache, err := Repo(opts)
+	if err != nil {
+		return "", err
 	}
 	return fmt.Sprintf("%s:%s", cache, cacheKey), nil
 }
 
+// Repo returns the cache repo, inferred from the destination if none is
+// specified.
+func Repo(opts *config.KanikoOptions) (string, error) {
+	if opts.CacheRepo != "" {
+		return opts.CacheRepo, nil
+	}
+	if len(opts.Destinations) == 0 {
+		return "", errors.New("no cache repo or destination to infer it from")
+	}
+	destRef, err := name.NewTag(opts.Destinations[0], name.WeakValidation)
+	if err != nil {
+		return "", errors.Wrap(err, "getting tag for destination")
+	}
+	return fmt.Sprintf("%s/cache", destRef.Context()), nil
+}
+
 // LocalSource retrieves a source image from a local cache given cacheKey
 func LocalSource(opts *config.CacheOptions, cacheKey string) (v1.Image, error) {
 	cache := opts.CacheDir
@@ -176,7 +187,22 @@ func LocalSource(opts *config.CacheOptions, cacheKey string) (v1.Image, error) {
 		return nil, nil
 	}
 
-	path := path.Join(cache, cacheKey)
+	store := &LocalStore{Dir: cache, MaxSize: opts.CacheMaxSize}
+	img, err := store.Image(cacheKey, opts.CacheTTL)
+	if IsNotFound(err) {
+		return tarballSource(opts, cacheKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Found %s in local cache", cacheKey)
+	return img, nil
+}
+
+// tarballSource retrieves a source image given cacheKey from the tarballs
+// earlier versions of the warmer wrote to the local cache.
+func tarballSource(opts *config.CacheOptions, cacheKey string) (v1.Image, error) {
+	path := path.Join(opts.CacheDir, cacheKey)
 
 	fi, err := os.Stat(path)
 	if err != nil {
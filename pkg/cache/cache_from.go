@@ -0,0 +1,151 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/sirupsen/logrus"
+)
+
+// cacheKeyCommentPrefix starts the comment of the history entry of a layer
+// kaniko built with its cache key.
+const cacheKeyCommentPrefix = "kaniko cache key: "
+
+// CacheKeyComment returns the history comment recording the cache key of a
+// layer.
+func CacheKeyComment(ck string) string {
+	return cacheKeyCommentPrefix + ck
+}
+
+// ImageCache is the layer cache of the layers of the images given with
+// --cache-from, which are matched by the cache keys recorded in their history.
+type ImageCache struct {
+	Opts   *config.KanikoOptions
+	Remote FetchRemoteImage
+
+	once   sync.Once
+	layers map[string]cachedLayer
+}
+
+type cachedLayer struct {
+	layer   v1.Layer
+	history v1.History
+}
+
+// RetrieveLayer retrieves the layer with the cache key ck from the images.
+func (ic *ImageCache) RetrieveLayer(ck string) (v1.Image, error) {
+	ic.once.Do(ic.load)
+	l, ok := ic.layers[ck]
+	if !ok {
+		return nil, fmt.Errorf("no layer with cache key %s in the --cache-from images", ck)
+	}
+	logrus.Infof("Found cached layer %s in the --cache-from images", ck)
+	return mutate.Append(empty.Image, mutate.Addendum{
+		Layer:   l.layer,
+		History: l.history,
+	})
+}
+
+// load indexes the layers of the images by cache key. An image which can't be
+// retrieved is only a cache miss.
+func (ic *ImageCache) load() {
+	ic.layers = make(map[string]cachedLayer)
+	for _, image := range ic.Opts.CacheFrom {
+		img, err := ic.Remote(image, ic.Opts.RegistryOptions, ic.Opts.CustomPlatform)
+		if err != nil {
+			logrus.Warnf("Failed to retrieve --cache-from image %s: %s", image, err)
+			continue
+		}
+		if err := ic.addLayers(img); err != nil {
+			logrus.Warnf("Failed to read layers of --cache-from image %s: %s", image, err)
+		}
+	}
+}
+
+// addLayers adds the layers of the image whose history entry records their
+// cache key.
+func (ic *ImageCache) addLayers(img v1.Image) error {
+	layers, err := keyedLayers(img)
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		if _, ok := ic.layers[l.key]; !ok {
+			ic.layers[l.key] = l.cachedLayer
+		}
+	}
+	return nil
+}
+
+type keyedLayer struct {
+	cachedLayer
+	key string
+}
+
+// keyedLayers returns the layers of the image whose history entry records
+// their cache key, in order.
+func keyedLayers(img v1.Image) ([]keyedLayer, error) {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	var history []v1.History
+	for _, h := range cf.History {
+		if !h.EmptyLayer {
+			history = append(history, h)
+		}
+	}
+	if len(history) != len(layers) {
+		return nil, fmt.Errorf("the history has %d entries for %d layers", len(history), len(layers))
+	}
+	var keyed []keyedLayer
+	for i, h := range history {
+		ck, ok := strings.CutPrefix(h.Comment, cacheKeyCommentPrefix)
+		if !ok {
+			continue
+		}
+		keyed = append(keyed, keyedLayer{cachedLayer: cachedLayer{layer: layers[i], history: h}, key: ck})
+	}
+	return keyed, nil
+}
+
+// LayerCaches looks layers up in each of the layer caches in turn.
+type LayerCaches []LayerCache
+
+// RetrieveLayer retrieves the layer from the first cache it is found in.
+func (lc LayerCaches) RetrieveLayer(ck string) (v1.Image, error) {
+	var err error
+	for _, c := range lc {
+		var img v1.Image
+		if img, err = c.RetrieveLayer(ck); err == nil {
+			return img, nil
+		}
+		logrus.Debugf("Failed to retrieve layer: %s", err)
+	}
+	return nil, err
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func Test_ImageCache(t *testing.T) {
+	base, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	built, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: base, History: v1.History{CreatedBy: "ADD rootfs.tar /"}},
+		mutate.Addendum{History: v1.History{CreatedBy: "ENV FOO=bar", EmptyLayer: true}},
+		mutate.Addendum{Layer: built, History: v1.History{CreatedBy: "RUN make", Comment: CacheKeyComment("key")}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetched []string
+	c := &ImageCache{
+		Opts: &config.KanikoOptions{CacheFrom: []string{"missing", "image"}},
+		Remote: func(image string, _ config.RegistryOptions, _ string) (v1.Image, error) {
+			fetched = append(fetched, image)
+			if image == "missing" {
+				return nil, errors.New("not found")
+			}
+			return img, nil
+		},
+	}
+
+	cached, err := c.RetrieveLayer("key")
+	testutil.CheckNoError(t, err)
+	layers, err := cached.Layers()
+	testutil.CheckErrorAndDeepEqual(t, false, err, 1, len(layers))
+	want, _ := built.Digest()
+	got, err := layers[0].Digest()
+	testutil.CheckErrorAndDeepEqual(t, false, err, want, got)
+	cf, err := cached.ConfigFile()
+	testutil.CheckErrorAndDeepEqual(t, false, err, "RUN make", cf.History[0].CreatedBy)
+
+	_, err = c.RetrieveLayer("other")
+	testutil.CheckError(t, true, err)
+	// The images are only fetched once.
+	testutil.CheckDeepEqual(t, []string{"missing", "image"}, fetched)
+}
+
+type fakeLayerCache struct {
+	keys map[string]v1.Image
+}
+
+func (f *fakeLayerCache) RetrieveLayer(ck string) (v1.Image, error) {
+	if img, ok := f.keys[ck]; ok {
+		return img, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func Test_LayerCaches(t *testing.T) {
+	first := empty.Image
+	second, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caches := LayerCaches{
+		&fakeLayerCache{keys: map[string]v1.Image{"a": first}},
+		&fakeLayerCache{keys: map[string]v1.Image{"a": second, "b": second}},
+	}
+
+	img, err := caches.RetrieveLayer("a")
+	testutil.CheckErrorAndDeepEqual(t, false, err, first, img)
+	img, err = caches.RetrieveLayer("b")
+	testutil.CheckErrorAndDeepEqual(t, false, err, second, img)
+	_, err = caches.RetrieveLayer("c")
+	testutil.CheckError(t, true, err)
+}
@@ -17,7 +17,6 @@ limitations under the License.
 package cache
 
 import (
-	"bytes"
 	"log"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
@@ -25,13 +24,10 @@ import (
 )
 
 func ExampleWarmer_Warm() {
-	tarBuf := new(bytes.Buffer)
-	manifestBuf := new(bytes.Buffer)
 	w := &Warmer{
-		Remote:         remote.RetrieveRemoteImage,
-		Local:          LocalSource,
-		TarWriter:      tarBuf,
-		ManifestWriter: manifestBuf,
+		Remote: remote.RetrieveRemoteImage,
+		Local:  LocalSource,
+		Store:  &LocalStore{Dir: "/cache", MaxSize: 10 << 30},
 	}
 
 	options := &config.WarmerOptions{}
@@ -43,5 +39,5 @@ func ExampleWarmer_Warm() {
 		}
 	}
 
-	log.Printf("digest %v tar len %d\nmanifest:\n%s\n", digest, tarBuf.Len(), manifestBuf.String())
+	log.Printf("digest %v", digest)
 }
@@ -0,0 +1,329 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/constants"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// IndexTag is the tag of the cache index in the cache repo.
+const IndexTag = "kaniko-cache-index"
+
+// indexPushAttempts is how many times Push merges its layers into the cache
+// index again when another build pushed it in the meantime.
+const indexPushAttempts = 5
+
+// For testing
+var beforeIndexWrite = func() {}
+
+// IndexCache is the registry cache of --cache-mode=index. Instead of an image
+// per cache key, the cache repo holds a single cache index: an image whose
+// layers are the cached layers, each with its cache key recorded in its
+// history. The index is fetched once, so that looking layers up doesn't need
+// a round trip to the registry per command.
+type IndexCache struct {
+	Opts *config.KanikoOptions
+
+	once    sync.Once
+	loadErr error
+	repo    name.Repository
+	options []remote.Option
+
+	mu      sync.Mutex
+	layers  []keyedLayer
+	pushed  []keyedLayer
+	expired bool
+}
+
+// RetrieveLayer retrieves the layer with the cache key ck from the cache index.
+func (ic *IndexCache) RetrieveLayer(ck string) (v1.Image, error) {
+	if err := ic.load(); err != nil {
+		return nil, err
+	}
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	for _, l := range ic.layers {
+		if l.key == ck {
+			logrus.Infof("Found cached layer %s in the cache index", ck)
+			return mutate.Append(empty.Image, mutate.Addendum{
+				Layer:   l.layer,
+				History: l.history,
+			})
+		}
+	}
+	return nil, fmt.Errorf("no layer with cache key %s in the cache index", ck)
+}
+
+// PushLayer pushes the layer to the cache repo and adds it to the cache index
+// with the cache key ck. The index itself is only pushed by Push.
+func (ic *IndexCache) PushLayer(ck string, layer v1.Layer, createdBy string) error {
+	if err := ic.load(); err != nil {
+		return err
+	}
+	if err := remote.WriteLayer(ic.repo, layer, ic.options...); err != nil {
+		return errors.Wrapf(err, "pushing layer %s", ck)
+	}
+	l := keyedLayer{
+		cachedLayer: cachedLayer{
+			layer: layer,
+			history: v1.History{
+				Author:    constants.Author,
+				Created:   v1.Time{Time: time.Now()},
+				CreatedBy: createdBy,
+				Comment:   CacheKeyComment(ck),
+			},
+		},
+		key: ck,
+	}
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	ic.layers = withLayer(ic.layers, l)
+	ic.pushed = withLayer(ic.pushed, l)
+	return nil
+}
+
+// withLayer returns the layers with l, replacing the layer with the same key.
+func withLayer(layers []keyedLayer, l keyedLayer) []keyedLayer {
+	for i, existing := range layers {
+		if existing.key == l.key {
+			layers = append(layers[:i], layers[i+1:]...)
+			break
+		}
+	}
+	return append(layers, l)
+}
+
+// Push pushes the cache index if layers were added to it or expired since it
+// was fetched. Other builds may push the index at the same time, so the
+// index is fetched again and the added layers are merged into it right before
+// pushing it. If it was pushed in the meantime, or is replaced by another
+// build once pushed, this is done again.
+func (ic *IndexCache) Push() error {
+	if err := ic.load(); err != nil {
+		return err
+	}
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if len(ic.pushed) == 0 && !ic.expired {
+		return nil
+	}
+
+	index := ic.repo.Tag(IndexTag)
+	for attempt := 1; attempt <= indexPushAttempts; attempt++ {
+		current, currentDigest, err := ic.fetchIndex()
+		if err != nil {
+			return err
+		}
+		layers, changed, err := ic.merge(current)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			logrus.Infof("Cache index %s is up to date", index)
+			return nil
+		}
+		img, err := indexImage(layers)
+		if err != nil {
+			return err
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			return err
+		}
+
+		beforeIndexWrite()
+		if d, err := ic.indexDigest(); err != nil {
+			return err
+		} else if d != currentDigest {
+			logrus.Infof("Cache index %s was pushed by another build, merging again", index)
+			continue
+		}
+		logrus.Infof("Pushing cache index %s with %d layers", index, len(layers))
+		if err := remote.Write(index, img, ic.options...); err != nil {
+			return errors.Wrap(err, "pushing cache index")
+		}
+		if d, err := ic.indexDigest(); err != nil {
+			return err
+		} else if d != digest {
+			logrus.Infof("Cache index %s was replaced by another build, merging again", index)
+			continue
+		}
+		ic.pushed, ic.expired = nil, false
+		return nil
+	}
+	return fmt.Errorf("cache index %s kept being pushed by other builds", index)
+}
+
+// merge returns the layers of the current cache index with the layers added
+// by PushLayer. Expired layers are dropped, and of the layers with the same
+// key only the newest one is kept. It returns false if the index
+// already is the one to push.
+func (ic *IndexCache) merge(current []keyedLayer) ([]keyedLayer, bool, error) {
+	var layers []keyedLayer
+	changed := false
+	for _, l := range current {
+		if ic.isExpired(l) {
+			logrus.Debugf("Cache entry expired: %s", l.key)
+			changed = true
+			continue
+		}
+		if i := slices.IndexFunc(layers, func(k keyedLayer) bool { return k.key == l.key }); i >= 0 {
+			changed = true
+			if !l.history.Created.After(layers[i].history.Created.Time) {
+				continue
+			}
+		}
+		layers = withLayer(layers, l)
+	}
+	for _, l := range ic.pushed {
+		i := slices.IndexFunc(layers, func(k keyedLayer) bool { return k.key == l.key })
+		if i >= 0 {
+			same, err := sameLayer(layers[i].layer, l.layer)
+			if err != nil {
+				return nil, false, err
+			}
+			// Another build may have pushed a newer layer for the key.
+			if same || !l.history.Created.After(layers[i].history.Created.Time) {
+				continue
+			}
+		}
+		layers = withLayer(layers, l)
+		changed = true
+	}
+	return layers, changed, nil
+}
+
+func sameLayer(a, b v1.Layer) (bool, error) {
+	da, err := a.Digest()
+	if err != nil {
+		return false, err
+	}
+	db, err := b.Digest()
+	if err != nil {
+		return false, err
+	}
+	return da == db, nil
+}
+
+func (ic *IndexCache) isExpired(l keyedLayer) bool {
+	return l.history.Created.Add(ic.Opts.CacheTTL).Before(time.Now())
+}
+
+// indexImage returns the cache index image of the layers.
+func indexImage(layers []keyedLayer) (v1.Image, error) {
+	img, err := mutate.CreatedAt(empty.Image, v1.Time{Time: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range layers {
+		img, err = mutate.Append(img, mutate.Addendum{Layer: l.layer, History: l.history})
+		if err != nil {
+			return nil, errors.Wrap(err, "appending layer to cache index")
+		}
+	}
+	return img, nil
+}
+
+// load fetches the cache index once, dropping the layers older than the cache
+// TTL. A missing index is an empty one.
+func (ic *IndexCache) load() error {
+	ic.once.Do(func() {
+		ic.loadErr = ic.fetch()
+	})
+	return ic.loadErr
+}
+
+func (ic *IndexCache) fetch() error {
+	repo, err := Repo(ic.Opts)
+	if err != nil {
+		return errors.Wrap(err, "getting cache repo")
+	}
+	ic.repo, ic.options, err = cacheRepository(repo, ic.Opts)
+	if err != nil {
+		return err
+	}
+
+	layers, _, err := ic.fetchIndex()
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		if ic.isExpired(l) {
+			logrus.Debugf("Cache entry expired: %s", l.key)
+			ic.expired = true
+			continue
+		}
+		ic.layers = append(ic.layers, l)
+	}
+	return nil
+}
+
+// fetchIndex returns the layers of the cache index and its digest. A missing
+// index has no layers and an empty digest.
+func (ic *IndexCache) fetchIndex() ([]keyedLayer, v1.Hash, error) {
+	index := ic.repo.Tag(IndexTag)
+	logrus.Infof("Fetching cache index %s...", index)
+	desc, err := remote.Get(index, ic.options...)
+	if isNotFound(err) {
+		logrus.Infof("No cache index %s yet", index)
+		return nil, v1.Hash{}, nil
+	}
+	if err != nil {
+		return nil, v1.Hash{}, errors.Wrapf(err, "fetching cache index %s", index)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, v1.Hash{}, errors.Wrapf(err, "fetching cache index %s", index)
+	}
+	layers, err := keyedLayers(img)
+	if err != nil {
+		return nil, v1.Hash{}, errors.Wrapf(err, "reading cache index %s", index)
+	}
+	return layers, desc.Digest, nil
+}
+
+// indexDigest returns the digest the cache index tag points to, empty if it
+// doesn't exist.
+func (ic *IndexCache) indexDigest() (v1.Hash, error) {
+	desc, err := remote.Head(ic.repo.Tag(IndexTag), ic.options...)
+	if isNotFound(err) {
+		return v1.Hash{}, nil
+	}
+	if err != nil {
+		return v1.Hash{}, errors.Wrap(err, "checking cache index")
+	}
+	return desc.Digest, nil
+}
+
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == 404
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func Test_IndexCache(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := u.Host + "/cache"
+	opts := &config.KanikoOptions{CacheRepo: repo, CacheOptions: config.CacheOptions{CacheTTL: 24 * time.Hour}}
+
+	// An expired layer is dropped from the index.
+	expired, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:   expired,
+		History: v1.History{Created: v1.Time{Time: time.Now().Add(-48 * time.Hour)}, Comment: CacheKeyComment("expired")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexRef, err := name.NewTag(repo + ":" + IndexTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(indexRef, index); err != nil {
+		t.Fatal(err)
+	}
+
+	layer, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := &IndexCache{Opts: opts}
+	_, err = first.RetrieveLayer("expired")
+	testutil.CheckError(t, true, err)
+	testutil.CheckNoError(t, first.PushLayer("key", layer, "RUN make"))
+	testutil.CheckNoError(t, first.Push())
+
+	// The next build finds the layer in the index.
+	second := &IndexCache{Opts: opts}
+	img, err := second.RetrieveLayer("key")
+	testutil.CheckNoError(t, err)
+	layers, err := img.Layers()
+	testutil.CheckErrorAndDeepEqual(t, false, err, 1, len(layers))
+	want, _ := layer.Digest()
+	got, err := layers[0].Digest()
+	testutil.CheckErrorAndDeepEqual(t, false, err, want, got)
+	cf, err := img.ConfigFile()
+	testutil.CheckErrorAndDeepEqual(t, false, err, "RUN make", cf.History[0].CreatedBy)
+	_, err = second.RetrieveLayer("expired")
+	testutil.CheckError(t, true, err)
+
+	pushed, err := remote.Image(indexRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyed, err := keyedLayers(pushed)
+	testutil.CheckErrorAndDeepEqual(t, false, err, 1, len(keyed))
+	testutil.CheckDeepEqual(t, "key", keyed[0].key)
+}
+
+func Test_IndexCache_ConcurrentPush(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := u.Host + "/cache"
+	opts := &config.KanikoOptions{CacheRepo: repo, CacheOptions: config.CacheOptions{CacheTTL: 24 * time.Hour}}
+
+	newLayer := func() v1.Layer {
+		l, err := random.Layer(100, types.DockerLayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+	first := &IndexCache{Opts: opts}
+	second := &IndexCache{Opts: opts}
+	testutil.CheckNoError(t, first.PushLayer("first", newLayer(), "RUN first"))
+	testutil.CheckNoError(t, first.PushLayer("shared", newLayer(), "RUN shared"))
+	testutil.CheckNoError(t, second.PushLayer("second", newLayer(), "RUN second"))
+	shared := newLayer()
+	testutil.CheckNoError(t, second.PushLayer("shared", shared, "RUN shared"))
+
+	// The second build pushes the index while the first one is about to.
+	interleaved := false
+	original := beforeIndexWrite
+	beforeIndexWrite = func() {
+		if !interleaved {
+			interleaved = true
+			testutil.CheckNoError(t, second.Push())
+		}
+	}
+	t.Cleanup(func() { beforeIndexWrite = original })
+	testutil.CheckNoError(t, first.Push())
+
+	indexRef, err := name.NewTag(repo + ":" + IndexTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := remote.Image(indexRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyed, err := keyedLayers(pushed)
+	testutil.CheckNoError(t, err)
+	var keys []string
+	for _, l := range keyed {
+		keys = append(keys, l.key)
+		if l.key == "shared" {
+			// The newer layer of the key wins.
+			want, _ := shared.Digest()
+			got, err := l.layer.Digest()
+			testutil.CheckErrorAndDeepEqual(t, false, err, want, got)
+		}
+	}
+	sort.Strings(keys)
+	testutil.CheckDeepEqual(t, []string{"first", "second", "shared"}, keys)
+}
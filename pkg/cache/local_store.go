@@ -0,0 +1,744 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/image/remote"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sys/unix"
+)
+
+// blobGCGracePeriod keeps the blobs no image refers to from being deleted for
+// a while after they are written, as they may belong to an image which is
+// still being written.
+const blobGCGracePeriod = time.Hour
+
+// storeLock is the file of the store locked by the processes using it, which
+// may share it on a volume.
+const storeLock = ".lock"
+
+// LocalStore is the content-addressed store of the base images cached in
+// --cache-dir. The manifests, configs and layers of the images are stored
+// once by digest under blobs/, so that images sharing layers share their
+// blobs. Each cached image has a file under images/ named after its digest,
+// whose modification time is the last time it was used.
+type LocalStore struct {
+	Dir string
+	// MaxSize is the maximum size of the blobs. The least recently used images
+	// are evicted beyond it. There is no limit if it is 0.
+	MaxSize int64
+	// Parallelism is the maximum number of layers downloaded at once, across
+	// the images written concurrently. It is 1 if it is 0.
+	Parallelism int
+	// Retries is the number of times the download of a layer is retried,
+	// resuming it if possible.
+	Retries int
+
+	// mu serializes the updates of the list of images of the store.
+	mu        sync.Mutex
+	blobLocks map[v1.Hash]*sync.Mutex
+	once      sync.Once
+	downloads *semaphore.Weighted
+}
+
+// Image returns the image with the given digest, or a NotFoundErr if it isn't
+// cached, or an ExpiredErr if it was cached longer than ttl ago. The blobs of
+// the image are verified against their digest when they are read, and deleted
+// if they don't match.
+func (s *LocalStore) Image(digest string, ttl time.Duration) (v1.Image, error) {
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing digest %s", digest)
+	}
+	marker := s.imagePath(h)
+	if _, err := os.Stat(marker); err != nil {
+		// The images of an OCI image layout have no file under images/, but
+		// their manifests are blobs as well.
+		if !s.IsLayout() || !s.hasBlob(h) {
+			msg := fmt.Sprintf("No image found for cache key %v %v", digest, err)
+			logrus.Debug(msg)
+			return nil, NotFoundErr{msg: msg}
+		}
+		marker = ""
+	}
+
+	// A stale cache is a bad cache
+	fi, err := os.Stat(s.blobPath(h))
+	if err == nil && fi.ModTime().Add(ttl).Before(time.Now()) {
+		msg := fmt.Sprintf("Cached image is too old: %v", fi.ModTime())
+		logrus.Debug(msg)
+		return nil, ExpiredErr{msg: msg}
+	}
+
+	rawManifest, err := s.readBlob(h)
+	if err != nil {
+		return nil, s.corrupted(h, err)
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, s.corrupted(h, err)
+	}
+	rawConfig, err := s.readBlob(manifest.Config.Digest)
+	if err != nil {
+		return nil, s.corrupted(h, err)
+	}
+	// The layers are only verified when they are read.
+	for _, l := range manifest.Layers {
+		if _, err := os.Stat(s.blobPath(l.Digest)); err != nil {
+			return nil, s.corrupted(h, err)
+		}
+	}
+
+	if marker != "" {
+		now := time.Now()
+		if err := os.Chtimes(marker, now, now); err != nil {
+			logrus.Warnf("Failed to record the use of cached image %s: %s", digest, err)
+		}
+	}
+	return partial.CompressedToImage(&localImage{
+		store:       s,
+		manifest:    manifest,
+		rawManifest: rawManifest,
+		rawConfig:   rawConfig,
+	})
+}
+
+// Write writes the image to the store, only downloading the blobs which aren't
+// stored yet, then evicts the least recently used images beyond MaxSize. The
+// layers are downloaded concurrently, with open if it isn't nil so that their
+// downloads are resumed when they are retried, and counted towards progress.
+func (s *LocalStore) Write(img v1.Image, open remote.BlobOpener, progress *Progress) (v1.Hash, error) {
+	digest, err := img.Digest()
+	if err != nil {
+		return v1.Hash{}, errors.Wrap(err, "getting digest")
+	}
+	// The store is locked until the image is added, so that the blobs it
+	// shares with the images already stored aren't evicted in the meantime.
+	unlock, err := s.lockStore(unix.LOCK_SH)
+	if err != nil {
+		return v1.Hash{}, errors.Wrap(err, "locking the cache")
+	}
+	err = s.writeImage(img, digest, open, progress)
+	unlock()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	if err := s.Evict(digest); err != nil {
+		logrus.Warnf("Failed to evict images from the cache: %s", err)
+	}
+	return digest, nil
+}
+
+func (s *LocalStore) writeImage(img v1.Image, digest v1.Hash, open remote.BlobOpener, progress *Progress) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return errors.Wrap(err, "getting layers")
+	}
+	if err := s.writeLayers(layers, open, progress); err != nil {
+		return err
+	}
+	if err := s.writeManifest(img); err != nil {
+		return err
+	}
+	return s.addImage(digest)
+}
+
+func (s *LocalStore) addImage(digest v1.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Join(s.Dir, "images"), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.imagePath(digest), nil, 0644); err != nil {
+		return errors.Wrap(err, "writing image")
+	}
+	return nil
+}
+
+// writeLayers downloads the layers which aren't stored yet concurrently, with
+// open if it isn't nil, and counts them towards progress.
+func (s *LocalStore) writeLayers(layers []v1.Layer, open remote.BlobOpener, progress *Progress) error {
+	var missing []v1.Layer
+	var total int64
+	seen := map[v1.Hash]bool{}
+	for _, l := range layers {
+		d, err := l.Digest()
+		if err != nil {
+			return errors.Wrap(err, "getting layer digest")
+		}
+		if seen[d] || s.hasBlob(d) {
+			continue
+		}
+		seen[d] = true
+		size, err := l.Size()
+		if err != nil {
+			return errors.Wrapf(err, "getting size of layer %s", d)
+		}
+		missing = append(missing, l)
+		total += size
+	}
+
+	progress.Start(total)
+	var g errgroup.Group
+	for _, l := range missing {
+		g.Go(func() error {
+			return s.writeLayer(l, open, progress)
+		})
+	}
+	err := g.Wait()
+	progress.Stop()
+	return err
+}
+
+// writeManifest writes the config and the manifest of the image. The manifest
+// is rewritten even if it is stored already, its modification time being the
+// time the image was cached.
+func (s *LocalStore) writeManifest(img v1.Image) error {
+	rawConfig, err := img.RawConfigFile()
+	if err != nil {
+		return errors.Wrap(err, "getting config")
+	}
+	configName, err := img.ConfigName()
+	if err != nil {
+		return errors.Wrap(err, "getting config digest")
+	}
+	if err := s.writeBlob(configName, bytes.NewReader(rawConfig)); err != nil {
+		return err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return errors.Wrap(err, "getting digest")
+	}
+	rawManifest, err := img.RawManifest()
+	if err != nil {
+		return errors.Wrap(err, "getting manifest")
+	}
+	return s.writeBlob(digest, bytes.NewReader(rawManifest))
+}
+
+// writeLayer downloads the layer to its blob, retrying Retries times, once a
+// download slot is available.
+func (s *LocalStore) writeLayer(l v1.Layer, open remote.BlobOpener, progress *Progress) error {
+	d, err := l.Digest()
+	if err != nil {
+		return errors.Wrap(err, "getting layer digest")
+	}
+	size, err := l.Size()
+	if err != nil {
+		return errors.Wrapf(err, "getting size of layer %s", d)
+	}
+
+	s.once.Do(func() {
+		s.downloads = semaphore.NewWeighted(int64(max(s.Parallelism, 1)))
+	})
+	if err := s.downloads.Acquire(context.Background(), 1); err != nil {
+		return err
+	}
+	defer s.downloads.Release(1)
+	// Images sharing the layer download it once.
+	unlock := s.lockBlob(d)
+	defer unlock()
+	if s.hasBlob(d) {
+		progress.Add(size)
+		return nil
+	}
+
+	counter := &blobProgress{progress: progress}
+	return util.Retry(func() error {
+		return s.downloadBlob(l, d, size, open, counter)
+	}, s.Retries, 1000)
+}
+
+// downloadBlob downloads the layer to its blob through a partial file, which
+// is kept if the download fails so that the next attempt resumes it.
+func (s *LocalStore) downloadBlob(l v1.Layer, digest v1.Hash, size int64, open remote.BlobOpener, counter *blobProgress) error {
+	path := s.blobPath(digest)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	partial := path + ".partial"
+	f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hasher, err := v1.Hasher(digest.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	var rc io.ReadCloser
+	var offset int64
+	if open != nil {
+		n, err := io.Copy(hasher, f)
+		if err != nil {
+			return errors.Wrapf(err, "reading partial blob %s", digest)
+		}
+		if n == size && hex(hasher) == digest.Hex {
+			counter.reset(n)
+			f.Close()
+			return os.Rename(partial, path)
+		}
+		if n >= size {
+			n = 0
+			hasher.Reset()
+		}
+		if n > 0 {
+			logrus.Infof("Resuming download of blob %s at %d/%d bytes", digest, n, size)
+		}
+		rc, offset, err = open(digest, size, n)
+		if err != nil {
+			return errors.Wrapf(err, "downloading blob %s", digest)
+		}
+		if offset != n {
+			hasher.Reset()
+		}
+	} else {
+		rc, err = l.Compressed()
+		if err != nil {
+			return errors.Wrapf(err, "reading layer %s", digest)
+		}
+		hasher.Reset()
+	}
+	defer rc.Close()
+
+	if err := f.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	counter.reset(offset)
+	if _, err := io.Copy(io.MultiWriter(f, hasher, counter), rc); err != nil {
+		return errors.Wrapf(err, "downloading blob %s", digest)
+	}
+	if got := hex(hasher); got != digest.Hex {
+		os.Remove(partial)
+		return fmt.Errorf("blob %s has digest %s:%s", digest, digest.Algorithm, got)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partial, path)
+}
+
+// lockBlob locks the blob with the given digest until the returned function
+// is called.
+func (s *LocalStore) lockBlob(digest v1.Hash) func() {
+	s.mu.Lock()
+	if s.blobLocks == nil {
+		s.blobLocks = map[v1.Hash]*sync.Mutex{}
+	}
+	l, ok := s.blobLocks[digest]
+	if !ok {
+		l = &sync.Mutex{}
+		s.blobLocks[digest] = l
+	}
+	s.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// Evict deletes the least recently used images, except keep, until the blobs
+// fit in MaxSize, along with the blobs no image refers to anymore. Nothing is
+// evicted while other processes write images to the store, the last of them
+// evicts once it is done. The blobs being read are kept.
+func (s *LocalStore) Evict(keep v1.Hash) error {
+	if s.MaxSize <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.IsLayout() {
+		// The blobs the index.json of the layout refers to aren't counted.
+		logrus.Warnf("Not evicting images from %s, it is an OCI image layout", s.Dir)
+		return nil
+	}
+	unlock, err := s.lockStore(unix.LOCK_EX | unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		logrus.Debugf("Not evicting images from %s, images are being written to it", s.Dir)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "locking the cache")
+	}
+	defer unlock()
+	images, err := s.images()
+	if err != nil {
+		return err
+	}
+	blobs, err := s.blobs()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, b := range blobs {
+		size += b.size
+	}
+
+	refs := map[string]int{}
+	for _, img := range images {
+		for _, d := range img.blobs {
+			refs[d]++
+		}
+	}
+	// The blobs no image refers to were left by failed writes, or belong to
+	// images which are being written.
+	for d, b := range blobs {
+		if refs[d] == 0 && time.Since(b.modified) > blobGCGracePeriod {
+			size -= s.deleteBlob(d, b)
+		}
+	}
+
+	// Least recently used first.
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].used.Before(images[j].used)
+	})
+	for _, img := range images {
+		if size <= s.MaxSize {
+			break
+		}
+		if img.digest == keep.String() {
+			continue
+		}
+		logrus.Infof("Evicting %s from the cache", img.digest)
+		if err := os.Remove(img.path); err != nil {
+			return err
+		}
+		for _, d := range img.blobs {
+			refs[d]--
+			if b, ok := blobs[d]; ok && refs[d] == 0 {
+				size -= s.deleteBlob(d, b)
+				delete(blobs, d)
+			}
+		}
+	}
+	if size > s.MaxSize {
+		logrus.Warnf("The cache takes %d bytes, more than its maximum size %d", size, s.MaxSize)
+	}
+	return nil
+}
+
+type storedImage struct {
+	digest string
+	path   string
+	used   time.Time
+	blobs  []string
+}
+
+type storedBlob struct {
+	path     string
+	size     int64
+	modified time.Time
+}
+
+// images lists the images of the store with the blobs they refer to.
+func (s *LocalStore) images() ([]storedImage, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, "images"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var images []storedImage
+	for _, e := range entries {
+		h, err := v1.NewHash(e.Name())
+		if err != nil {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		img := storedImage{
+			digest: h.String(),
+			path:   s.imagePath(h),
+			used:   fi.ModTime(),
+			blobs:  []string{h.String()},
+		}
+		if f, err := os.Open(s.blobPath(h)); err == nil {
+			manifest, err := v1.ParseManifest(f)
+			f.Close()
+			if err == nil {
+				img.blobs = append(img.blobs, manifest.Config.Digest.String())
+				for _, l := range manifest.Layers {
+					img.blobs = append(img.blobs, l.Digest.String())
+				}
+			}
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// blobs lists the blobs of the store by digest, including the partially
+// written ones.
+func (s *LocalStore) blobs() (map[string]storedBlob, error) {
+	blobs := map[string]storedBlob{}
+	root := filepath.Join(s.Dir, "blobs")
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		alg, hex := filepath.Split(rel)
+		blobs[filepath.Clean(alg)+":"+hex] = storedBlob{path: path, size: fi.Size(), modified: fi.ModTime()}
+		return nil
+	})
+	return blobs, err
+}
+
+// deleteBlob deletes the blob and returns its size, or 0 if it can't be
+// deleted or is being read.
+func (s *LocalStore) deleteBlob(digest string, b storedBlob) int64 {
+	f, err := os.Open(b.path)
+	if err != nil {
+		logrus.Warnf("Failed to delete blob %s: %s", digest, err)
+		return 0
+	}
+	defer f.Close()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		logrus.Debugf("Not deleting blob %s, it is being read: %s", digest, err)
+		return 0
+	}
+	if err := os.Remove(b.path); err != nil {
+		logrus.Warnf("Failed to delete blob %s: %s", digest, err)
+		return 0
+	}
+	return b.size
+}
+
+// lockStore takes the lock of the store with the given flock operation, and
+// returns the function releasing it. Writers share it, so that Evict, which
+// takes it exclusively, doesn't delete the blobs of the images being written.
+func (s *LocalStore) lockStore(how int) (func(), error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(s.Dir, storeLock), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// IsLayout returns true if the store is an OCI image layout written by the
+// warmer with --oci-layout.
+func (s *LocalStore) IsLayout() bool {
+	_, err := os.Stat(filepath.Join(s.Dir, "oci-layout"))
+	return err == nil
+}
+
+func (s *LocalStore) imagePath(digest v1.Hash) string {
+	return filepath.Join(s.Dir, "images", digest.String())
+}
+
+func (s *LocalStore) blobPath(digest v1.Hash) string {
+	return filepath.Join(s.Dir, "blobs", digest.Algorithm, digest.Hex)
+}
+
+func (s *LocalStore) hasBlob(digest v1.Hash) bool {
+	_, err := os.Stat(s.blobPath(digest))
+	return err == nil
+}
+
+// writeBlob writes the content of r to the blob with the given digest, and
+// fails if its digest doesn't match.
+func (s *LocalStore) writeBlob(digest v1.Hash, r io.Reader) error {
+	dir := filepath.Dir(s.blobPath(digest))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	hasher, err := v1.Hasher(digest.Algorithm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.MultiWriter(f, hasher), r); err != nil {
+		return errors.Wrapf(err, "writing blob %s", digest)
+	}
+	if got := hex(hasher); got != digest.Hex {
+		return fmt.Errorf("blob %s has digest %s:%s", digest, digest.Algorithm, got)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.blobPath(digest))
+}
+
+// readBlob reads the blob with the given digest, which is deleted if its
+// digest doesn't match.
+func (s *LocalStore) readBlob(digest v1.Hash) ([]byte, error) {
+	rc, err := s.openBlob(digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// openBlob opens the blob with the given digest. Reading it fails at its end,
+// and deletes it, if its digest doesn't match.
+func (s *LocalStore) openBlob(digest v1.Hash) (io.ReadCloser, error) {
+	hasher, err := v1.Hasher(digest.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.blobPath(digest))
+	if err != nil {
+		return nil, err
+	}
+	// The blob is pinned until it is closed, Evict doesn't delete it.
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_SH); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &verifyingReader{ReadCloser: f, hasher: hasher, digest: digest, path: f.Name()}, nil
+}
+
+// corrupted drops the image whose blobs can't be read from the store.
+func (s *LocalStore) corrupted(digest v1.Hash, err error) error {
+	logrus.Warnf("Dropping cached image %s: %s", digest, err)
+	os.Remove(s.imagePath(digest))
+	return NotFoundErr{msg: fmt.Sprintf("Cached image %s is corrupted: %v", digest, err)}
+}
+
+// verifyingReader fails at the end of a blob whose digest doesn't match, and
+// deletes the file at path if it is set.
+type verifyingReader struct {
+	io.ReadCloser
+	hasher hash.Hash
+	digest v1.Hash
+	path   string
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hasher.Write(p[:n])
+	if err == io.EOF {
+		if got := hex(r.hasher); got != r.digest.Hex {
+			if r.path != "" {
+				os.Remove(r.path)
+			}
+			return n, fmt.Errorf("blob %s is corrupted, its digest is %s:%s", r.digest, r.digest.Algorithm, got)
+		}
+	}
+	return n, err
+}
+
+func hex(h hash.Hash) string {
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// localImage is an image of the store.
+type localImage struct {
+	store       *LocalStore
+	manifest    *v1.Manifest
+	rawManifest []byte
+	rawConfig   []byte
+}
+
+func (i *localImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *localImage) MediaType() (types.MediaType, error) {
+	if i.manifest.MediaType != "" {
+		return i.manifest.MediaType, nil
+	}
+	return types.OCIManifestSchema1, nil
+}
+
+func (i *localImage) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *localImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	if h == i.manifest.Config.Digest {
+		return &localLayer{store: i.store, desc: i.manifest.Config}, nil
+	}
+	for _, l := range i.manifest.Layers {
+		if l.Digest == h {
+			return &localLayer{store: i.store, desc: l}, nil
+		}
+	}
+	return nil, fmt.Errorf("blob %s not found in the manifest", h)
+}
+
+// localLayer is a blob of the store.
+type localLayer struct {
+	store *LocalStore
+	desc  v1.Descriptor
+}
+
+func (l *localLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+func (l *localLayer) Compressed() (io.ReadCloser, error) {
+	return l.store.openBlob(l.desc.Digest)
+}
+
+func (l *localLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+func (l *localLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
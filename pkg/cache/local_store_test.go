@@ -0,0 +1,296 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sys/unix"
+)
+
+func randomImage(t *testing.T) v1.Image {
+	t.Helper()
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func randomLayer(t *testing.T) v1.Layer {
+	t.Helper()
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layer
+}
+
+func Test_LocalStore_Image(t *testing.T) {
+	store := &LocalStore{Dir: t.TempDir()}
+	img := randomImage(t)
+	// An image sharing the layers of img.
+	shared, err := mutate.Append(img, mutate.Addendum{Layer: randomLayer(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := store.Write(img, nil, nil)
+	testutil.CheckNoError(t, err)
+	_, err = store.Write(shared, nil, nil)
+	testutil.CheckNoError(t, err)
+	// 2 manifests, 2 configs and 3 layers.
+	blobs, err := store.blobs()
+	testutil.CheckErrorAndDeepEqual(t, false, err, 7, len(blobs))
+
+	cached, err := store.Image(digest.String(), time.Hour)
+	testutil.CheckNoError(t, err)
+	got, err := cached.Digest()
+	testutil.CheckErrorAndDeepEqual(t, false, err, digest, got)
+	layers, err := cached.Layers()
+	testutil.CheckErrorAndDeepEqual(t, false, err, 2, len(layers))
+	for _, l := range layers {
+		rc, err := l.Uncompressed()
+		testutil.CheckNoError(t, err)
+		_, err = io.Copy(io.Discard, rc)
+		testutil.CheckNoError(t, err)
+		rc.Close()
+	}
+
+	_, err = store.Image(digest.String(), -time.Hour)
+	testutil.CheckDeepEqual(t, true, IsExpired(err))
+	_, err = store.Image("sha256:0000000000000000000000000000000000000000000000000000000000000000", time.Hour)
+	testutil.CheckDeepEqual(t, true, IsNotFound(err))
+}
+
+func Test_LocalStore_Image_Corrupted(t *testing.T) {
+	store := &LocalStore{Dir: t.TempDir()}
+	digest, err := store.Write(randomImage(t), nil, nil)
+	testutil.CheckNoError(t, err)
+
+	cached, err := store.Image(digest.String(), time.Hour)
+	testutil.CheckNoError(t, err)
+	layers, err := cached.Layers()
+	testutil.CheckNoError(t, err)
+	layerDigest, err := layers[0].Digest()
+	testutil.CheckNoError(t, err)
+	if err := os.WriteFile(store.blobPath(layerDigest), []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := layers[0].Compressed()
+	testutil.CheckNoError(t, err)
+	_, err = io.ReadAll(rc)
+	testutil.CheckError(t, true, err)
+	rc.Close()
+
+	// The corrupted blob is deleted, so the image isn't cached anymore.
+	_, err = store.Image(digest.String(), time.Hour)
+	testutil.CheckDeepEqual(t, true, IsNotFound(err))
+}
+
+func Test_LocalStore_Evict(t *testing.T) {
+	store := &LocalStore{Dir: t.TempDir()}
+	var digests []v1.Hash
+	for i := 0; i < 3; i++ {
+		digest, err := store.Write(randomImage(t), nil, nil)
+		testutil.CheckNoError(t, err)
+		digests = append(digests, digest)
+		used := time.Now().Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(store.imagePath(digest), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blobs, err := store.blobs()
+	testutil.CheckNoError(t, err)
+	var size int64
+	for _, b := range blobs {
+		size += b.size
+	}
+
+	// Using the oldest image makes the second one the least recently used.
+	_, err = store.Image(digests[0].String(), time.Hour)
+	testutil.CheckNoError(t, err)
+	store.MaxSize = size - 1
+	testutil.CheckNoError(t, store.Evict(v1.Hash{}))
+
+	for i, want := range []bool{true, false, true} {
+		_, err := store.Image(digests[i].String(), time.Hour)
+		testutil.CheckDeepEqual(t, want, err == nil)
+	}
+	blobs, err = store.blobs()
+	testutil.CheckErrorAndDeepEqual(t, false, err, 8, len(blobs))
+	entries, err := os.ReadDir(filepath.Join(store.Dir, "images"))
+	testutil.CheckErrorAndDeepEqual(t, false, err, 2, len(entries))
+}
+
+func Test_LocalStore_Evict_Locked(t *testing.T) {
+	store := &LocalStore{Dir: t.TempDir()}
+	var digests []v1.Hash
+	for i := 0; i < 2; i++ {
+		digest, err := store.Write(randomImage(t), nil, nil)
+		testutil.CheckNoError(t, err)
+		digests = append(digests, digest)
+		used := time.Now().Add(time.Duration(i-2) * time.Hour)
+		if err := os.Chtimes(store.imagePath(digest), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.MaxSize = 1
+
+	// Another process writing an image to the store.
+	other := &LocalStore{Dir: store.Dir}
+	unlock, err := other.lockStore(unix.LOCK_SH)
+	testutil.CheckNoError(t, err)
+	testutil.CheckNoError(t, store.Evict(digests[1]))
+	_, err = store.Image(digests[0].String(), time.Hour)
+	testutil.CheckNoError(t, err)
+
+	unlock()
+	testutil.CheckNoError(t, store.Evict(digests[1]))
+	_, err = store.Image(digests[0].String(), time.Hour)
+	testutil.CheckDeepEqual(t, true, IsNotFound(err))
+}
+
+func Test_LocalStore_Evict_Pinned(t *testing.T) {
+	store := &LocalStore{Dir: t.TempDir()}
+	img := randomImage(t)
+	digest, err := store.Write(img, nil, nil)
+	testutil.CheckNoError(t, err)
+	layers, err := img.Layers()
+	testutil.CheckNoError(t, err)
+	pinned, err := layers[0].Digest()
+	testutil.CheckNoError(t, err)
+	evicted, err := layers[1].Digest()
+	testutil.CheckNoError(t, err)
+
+	// A build reads a layer of the image while it is evicted.
+	rc, err := store.openBlob(pinned)
+	testutil.CheckNoError(t, err)
+	store.MaxSize = 1
+	testutil.CheckNoError(t, store.Evict(v1.Hash{}))
+	_, err = store.Image(digest.String(), time.Hour)
+	testutil.CheckDeepEqual(t, true, IsNotFound(err))
+	testutil.CheckDeepEqual(t, false, store.hasBlob(evicted))
+	testutil.CheckDeepEqual(t, true, store.hasBlob(pinned))
+	_, err = io.ReadAll(rc)
+	testutil.CheckNoError(t, err)
+	rc.Close()
+}
+
+// failingReader fails after reading its content.
+type failingReader struct {
+	io.Reader
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func Test_LocalStore_Write_Resume(t *testing.T) {
+	layer := randomLayer(t)
+	rc, err := layer.Compressed()
+	testutil.CheckNoError(t, err)
+	data, err := io.ReadAll(rc)
+	testutil.CheckNoError(t, err)
+	rc.Close()
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	testutil.CheckNoError(t, err)
+	half := int64(len(data) / 2)
+
+	tests := []struct {
+		name         string
+		partial      []byte
+		ignoreRange  bool
+		failFirst    bool
+		wantRequests []int64
+	}{
+		{
+			name:         "partial blob is resumed",
+			partial:      data[:half],
+			wantRequests: []int64{half},
+		},
+		{
+			name:         "failed download is resumed",
+			failFirst:    true,
+			wantRequests: []int64{0, 10},
+		},
+		{
+			name:         "registry without range requests",
+			partial:      data[:half],
+			ignoreRange:  true,
+			wantRequests: []int64{half},
+		},
+		{
+			name:         "corrupted partial blob is downloaded again",
+			partial:      make([]byte, len(data)),
+			wantRequests: []int64{0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &LocalStore{Dir: t.TempDir(), Retries: 1}
+			layerDigest, err := layer.Digest()
+			testutil.CheckNoError(t, err)
+			if test.partial != nil {
+				path := store.blobPath(layerDigest) + ".partial"
+				testutil.CheckNoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				testutil.CheckNoError(t, os.WriteFile(path, test.partial, 0644))
+			}
+
+			var requests []int64
+			open := func(digest v1.Hash, size, offset int64) (io.ReadCloser, int64, error) {
+				testutil.CheckDeepEqual(t, layerDigest, digest)
+				testutil.CheckDeepEqual(t, int64(len(data)), size)
+				requests = append(requests, offset)
+				if test.ignoreRange {
+					offset = 0
+				}
+				if test.failFirst && len(requests) == 1 {
+					return io.NopCloser(failingReader{bytes.NewReader(data[offset : offset+10])}), offset, nil
+				}
+				return io.NopCloser(bytes.NewReader(data[offset:])), offset, nil
+			}
+			progress := NewProgress("image")
+			digest, err := store.Write(img, open, progress)
+			testutil.CheckNoError(t, err)
+			testutil.CheckDeepEqual(t, test.wantRequests, requests)
+			testutil.CheckDeepEqual(t, int64(len(data)), progress.done.Load())
+
+			_, err = store.Image(digest.String(), time.Hour)
+			testutil.CheckNoError(t, err)
+			_, err = os.Stat(store.blobPath(layerDigest) + ".partial")
+			testutil.CheckDeepEqual(t, true, os.IsNotExist(err))
+		})
+	}
+}
@@ -0,0 +1,336 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/constants"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/pkg/util/bucket"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ObjectStore reads and writes the objects of an ObjectStorageCache.
+type ObjectStore interface {
+	Read(ctx context.Context, key string) (io.ReadCloser, error)
+	Write(ctx context.Context, key string, r io.Reader) error
+}
+
+// ObjectStorageCache is the layer cache stored in an S3 or GCS bucket, or in
+// an Azure blob storage container. The compressed layers are stored by
+// digest, next to a small index entry for each cache key which refers to
+// the layer.
+type ObjectStorageCache struct {
+	Opts *config.KanikoOptions
+	// Store is created from the cache repo if not set.
+	Store ObjectStore
+
+	mu sync.Mutex
+}
+
+// objectCacheEntry is the index entry of a cache key.
+type objectCacheEntry struct {
+	Created   time.Time       `json:"created"`
+	CreatedBy string          `json:"createdBy"`
+	Digest    v1.Hash         `json:"digest"`
+	DiffID    v1.Hash         `json:"diffID"`
+	Size      int64           `json:"size"`
+	MediaType types.MediaType `json:"mediaType"`
+}
+
+// IsObjectStorage returns true if the cache repo is an S3 or GCS bucket, or
+// an Azure blob storage container.
+func IsObjectStorage(repo string) bool {
+	return strings.HasPrefix(repo, constants.S3BuildContextPrefix) ||
+		strings.HasPrefix(repo, constants.GCSBuildContextPrefix) ||
+		(strings.HasPrefix(repo, constants.HTTPSBuildContextPrefix) && util.ValidAzureBlobStorageHost(repo))
+}
+
+// RetrieveLayer retrieves the layer cached with the cache key ck.
+func (c *ObjectStorageCache) RetrieveLayer(ck string) (v1.Image, error) {
+	cache, err := Destination(c.Opts, ck)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting cache destination")
+	}
+	logrus.Infof("Checking for cached layer %s...", cache)
+
+	store, err := c.store()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	r, err := store.Read(ctx, keyObject(ck))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading cache entry %s", cache)
+	}
+	defer r.Close()
+	var entry objectCacheEntry
+	if err := json.NewDecoder(r).Decode(&entry); err != nil {
+		return nil, errors.Wrapf(err, "parsing cache entry %s", cache)
+	}
+
+	layer, err := partial.CompressedToLayer(&objectLayer{store: store, entry: entry})
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating layer for %s", cache)
+	}
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: layer,
+		History: v1.History{
+			Author:    constants.Author,
+			CreatedBy: entry.CreatedBy,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "appending layer onto empty image")
+	}
+	img, err = mutate.CreatedAt(img, v1.Time{Time: entry.Created})
+	if err != nil {
+		return nil, errors.Wrap(err, "setting image created time")
+	}
+
+	if err = verifyImage(img, c.Opts.CacheTTL, cache); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// PushLayer stores the layer as the one cached with the cache key ck. The
+// layer is written before the entry of the cache key, so that a cache key
+// never refers to a missing layer.
+func (c *ObjectStorageCache) PushLayer(ck string, layer v1.Layer, createdBy string) error {
+	store, err := c.store()
+	if err != nil {
+		return err
+	}
+	entry := objectCacheEntry{Created: time.Now(), CreatedBy: createdBy}
+	if entry.Digest, err = layer.Digest(); err != nil {
+		return errors.Wrap(err, "getting layer digest")
+	}
+	if entry.DiffID, err = layer.DiffID(); err != nil {
+		return errors.Wrap(err, "getting layer diff id")
+	}
+	if entry.Size, err = layer.Size(); err != nil {
+		return errors.Wrap(err, "getting layer size")
+	}
+	if entry.MediaType, err = layer.MediaType(); err != nil {
+		return errors.Wrap(err, "getting layer media type")
+	}
+
+	ctx := context.Background()
+	rc, err := layer.Compressed()
+	if err != nil {
+		return errors.Wrap(err, "getting compressed layer")
+	}
+	defer rc.Close()
+	if err := store.Write(ctx, blobObject(entry.Digest), rc); err != nil {
+		return errors.Wrapf(err, "writing layer %s", entry.Digest)
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "marshaling cache entry")
+	}
+	if err := store.Write(ctx, keyObject(ck), bytes.NewReader(b)); err != nil {
+		return errors.Wrapf(err, "writing cache entry %s", ck)
+	}
+	return nil
+}
+
+func (c *ObjectStorageCache) store() (ObjectStore, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Store == nil {
+		store, err := NewObjectStore(c.Opts.CacheRepo)
+		if err != nil {
+			return nil, errors.Wrapf(err, "creating client for cache repo %s", c.Opts.CacheRepo)
+		}
+		c.Store = store
+	}
+	return c.Store, nil
+}
+
+func keyObject(ck string) string {
+	return path.Join("keys", ck)
+}
+
+func blobObject(digest v1.Hash) string {
+	return path.Join("blobs", digest.Algorithm, digest.Hex)
+}
+
+// objectLayer is a compressed layer read from an object store.
+type objectLayer struct {
+	store ObjectStore
+	entry objectCacheEntry
+}
+
+func (l *objectLayer) Digest() (v1.Hash, error) {
+	return l.entry.Digest, nil
+}
+
+func (l *objectLayer) DiffID() (v1.Hash, error) {
+	return l.entry.DiffID, nil
+}
+
+func (l *objectLayer) Size() (int64, error) {
+	return l.entry.Size, nil
+}
+
+func (l *objectLayer) MediaType() (types.MediaType, error) {
+	return l.entry.MediaType, nil
+}
+
+// Compressed reads the layer, failing at its end if its digest doesn't match
+// the one of the entry.
+func (l *objectLayer) Compressed() (io.ReadCloser, error) {
+	hasher, err := v1.Hasher(l.entry.Digest.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := l.store.Read(context.Background(), blobObject(l.entry.Digest))
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{ReadCloser: rc, hasher: hasher, digest: l.entry.Digest}, nil
+}
+
+// NewObjectStore returns the object store of a cache repo: 's3://bucket/prefix',
+// 'gs://bucket/prefix' or 'https://account.blob.core.windows.net/container/prefix'.
+func NewObjectStore(repo string) (ObjectStore, error) {
+	ctx := context.Background()
+	switch {
+	case strings.HasPrefix(repo, constants.S3BuildContextPrefix):
+		bucketName, prefix, err := bucketAndPrefix(repo)
+		if err != nil {
+			return nil, err
+		}
+		client, err := bucket.NewS3Client(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &s3Store{client: client, bucket: bucketName, prefix: prefix}, nil
+	case strings.HasPrefix(repo, constants.GCSBuildContextPrefix):
+		bucketName, prefix, err := bucketAndPrefix(repo)
+		if err != nil {
+			return nil, err
+		}
+		client, err := bucket.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &gcsStore{client: client, bucket: bucketName, prefix: prefix}, nil
+	case IsObjectStorage(repo):
+		client, parts, err := bucket.NewAzureBlobClient(repo)
+		if err != nil {
+			return nil, err
+		}
+		return &azureBlobStore{client: client, container: parts.ContainerName, prefix: parts.BlobName}, nil
+	}
+	return nil, fmt.Errorf("%s is not an object storage cache repo", repo)
+}
+
+// bucketAndPrefix returns the bucket of an object storage URL and the path in it.
+func bucketAndPrefix(repo string) (string, string, error) {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Host == "" {
+		return "", "", fmt.Errorf("no bucket in %s", repo)
+	}
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+type s3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func (s *s3Store) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path.Join(s.prefix, key)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3Store) Write(ctx context.Context, key string, r io.Reader) error {
+	_, err := s3manager.NewUploader(s.client).Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path.Join(s.prefix, key)),
+		Body:   r,
+	})
+	return err
+}
+
+type gcsStore struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+func (s *gcsStore) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	return bucket.ReadCloser(ctx, s.bucket, path.Join(s.prefix, key), s.client)
+}
+
+func (s *gcsStore) Write(ctx context.Context, key string, r io.Reader) error {
+	return bucket.Upload(ctx, s.bucket, path.Join(s.prefix, key), r, s.client)
+}
+
+type azureBlobStore struct {
+	client    *azblob.Client
+	container string
+	prefix    string
+}
+
+func (s *azureBlobStore) Read(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.client.DownloadStream(ctx, s.container, path.Join(s.prefix, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *azureBlobStore) Write(ctx context.Context, key string, r io.Reader) error {
+	_, err := s.client.UploadStream(ctx, s.container, path.Join(s.prefix, key), r, nil)
+	return err
+}
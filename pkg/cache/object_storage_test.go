@@ -0,0 +1,184 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// memStore is an ObjectStore keeping the objects in memory.
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memStore) Read(_ context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.objects[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (s *memStore) Write(_ context.Context, key string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = b
+	return nil
+}
+
+func Test_ObjectStorageCache(t *testing.T) {
+	store := &memStore{objects: map[string][]byte{}}
+	opts := &config.KanikoOptions{CacheRepo: "s3://bucket/cache", CacheOptions: config.CacheOptions{CacheTTL: time.Hour}}
+	c := &ObjectStorageCache{Opts: opts, Store: store}
+
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.CheckNoError(t, c.PushLayer("key", layer, "RUN make"))
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.objects["blobs/sha256/"+digest.Hex]; !ok {
+		t.Errorf("expected the layer to be stored by digest, got objects %v", store.objects)
+	}
+
+	img, err := c.RetrieveLayer("key")
+	testutil.CheckNoError(t, err)
+	layers, err := img.Layers()
+	testutil.CheckErrorAndDeepEqual(t, false, err, 1, len(layers))
+	gotDigest, err := layers[0].Digest()
+	testutil.CheckErrorAndDeepEqual(t, false, err, digest, gotDigest)
+	wantDiffID, err := layer.DiffID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotDiffID, err := layers[0].DiffID()
+	testutil.CheckErrorAndDeepEqual(t, false, err, wantDiffID, gotDiffID)
+
+	want, err := io.ReadAll(mustUncompressed(t, layer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(mustUncompressed(t, layers[0]))
+	testutil.CheckErrorAndDeepEqual(t, false, err, want, got)
+
+	cf, err := img.ConfigFile()
+	testutil.CheckErrorAndDeepEqual(t, false, err, "RUN make", cf.History[0].CreatedBy)
+
+	_, err = c.RetrieveLayer("missing")
+	testutil.CheckError(t, true, err)
+
+	opts.CacheTTL = -time.Hour
+	_, err = c.RetrieveLayer("key")
+	testutil.CheckError(t, true, err)
+}
+
+func Test_ObjectStorageCache_Corrupted(t *testing.T) {
+	store := &memStore{objects: map[string][]byte{}}
+	opts := &config.KanikoOptions{CacheRepo: "s3://bucket/cache", CacheOptions: config.CacheOptions{CacheTTL: time.Hour}}
+	c := &ObjectStorageCache{Opts: opts, Store: store}
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.CheckNoError(t, c.PushLayer("key", layer, "RUN make"))
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"truncated": func(b []byte) []byte { return b[:len(b)/2] },
+		"changed":   func(b []byte) []byte { b[len(b)-1]++; return b },
+	} {
+		t.Run(name, func(t *testing.T) {
+			blob := "blobs/sha256/" + digest.Hex
+			original := append([]byte{}, store.objects[blob]...)
+			store.objects[blob] = corrupt(append([]byte{}, original...))
+			t.Cleanup(func() { store.objects[blob] = original })
+
+			img, err := c.RetrieveLayer("key")
+			testutil.CheckNoError(t, err)
+			layers, err := img.Layers()
+			testutil.CheckErrorAndDeepEqual(t, false, err, 1, len(layers))
+			rc, err := layers[0].Compressed()
+			testutil.CheckNoError(t, err)
+			defer rc.Close()
+			_, err = io.ReadAll(rc)
+			testutil.CheckError(t, true, err)
+		})
+	}
+}
+
+func mustUncompressed(t *testing.T, layer v1.Layer) io.ReadCloser {
+	t.Helper()
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return rc
+}
+
+func Test_IsObjectStorage(t *testing.T) {
+	tests := []struct {
+		repo string
+		want bool
+	}{
+		{repo: "s3://bucket/cache", want: true},
+		{repo: "gs://bucket", want: true},
+		{repo: "https://account.blob.core.windows.net/container/cache", want: true},
+		{repo: "https://example.com/cache", want: false},
+		{repo: "gcr.io/project/cache", want: false},
+		{repo: "oci:/cache", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.repo, func(t *testing.T) {
+			testutil.CheckDeepEqual(t, test.want, IsObjectStorage(test.repo))
+		})
+	}
+}
+
+func Test_bucketAndPrefix(t *testing.T) {
+	bucket, prefix, err := bucketAndPrefix("s3://bucket/kaniko/cache/")
+	testutil.CheckErrorAndDeepEqual(t, false, err, []string{"bucket", "kaniko/cache"}, []string{bucket, prefix})
+	bucket, prefix, err = bucketAndPrefix("gs://bucket")
+	testutil.CheckErrorAndDeepEqual(t, false, err, []string{"bucket", ""}, []string{bucket, prefix})
+	_, _, err = bucketAndPrefix("s3:///cache")
+	testutil.CheckError(t, true, err)
+}
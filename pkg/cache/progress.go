@@ -0,0 +1,109 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+)
+
+// progressInterval is the interval the progress of downloads is reported at.
+var progressInterval = 10 * time.Second
+
+// Progress reports the progress of the download of an image. A nil Progress
+// reports nothing.
+type Progress struct {
+	image string
+	total int64
+	done  atomic.Int64
+	stop  chan struct{}
+}
+
+// NewProgress returns the Progress of the download of image.
+func NewProgress(image string) *Progress {
+	return &Progress{image: image}
+}
+
+// Start reports the progress towards total bytes every progressInterval, until
+// Stop is called. Nothing is reported if there is nothing to download.
+func (p *Progress) Start(total int64) {
+	if p == nil || total == 0 {
+		return
+	}
+	p.total = total
+	p.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops reporting the progress, reporting it one last time.
+func (p *Progress) Stop() {
+	if p == nil || p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.report()
+}
+
+// Add adds n downloaded bytes, which may be negative when a download restarts.
+func (p *Progress) Add(n int64) {
+	if p == nil {
+		return
+	}
+	p.done.Add(n)
+}
+
+func (p *Progress) report() {
+	done := p.done.Load()
+	logrus.WithFields(logrus.Fields{
+		"image": p.image,
+		"bytes": done,
+		"total": p.total,
+	}).Infof("Downloading %s: %s/%s", p.image, units.HumanSize(float64(done)), units.HumanSize(float64(p.total)))
+}
+
+// blobProgress counts the bytes of a blob towards the progress of its image,
+// across the attempts to download it.
+type blobProgress struct {
+	progress *Progress
+	n        int64
+}
+
+// reset sets the bytes of the blob downloaded so far to n.
+func (b *blobProgress) reset(n int64) {
+	b.progress.Add(n - b.n)
+	b.n = n
+}
+
+func (b *blobProgress) Write(p []byte) (int, error) {
+	b.n += int64(len(p))
+	b.progress.Add(int64(len(p)))
+	return len(p), nil
+}
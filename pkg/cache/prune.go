@@ -0,0 +1,274 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/creds"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// cacheKeyRegexp matches the cache keys, the hex SHA256 of composite keys.
+var cacheKeyRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// CacheEntry is a layer cached with a cache key.
+type CacheEntry struct {
+	Key     string
+	Created time.Time
+	// Size is the size of the layers of the entry.
+	Size   int64
+	digest v1.Hash
+}
+
+// cacheEntries lists and deletes the entries of a layer cache.
+type cacheEntries interface {
+	List() ([]CacheEntry, error)
+	Delete(CacheEntry) error
+}
+
+// PruneCache deletes the entries of the layer cache which are older than the
+// cache TTL, and the oldest ones beyond the budget of pruneOpts. It returns
+// the deleted entries, which are only listed if dryRun is set.
+func PruneCache(opts *config.KanikoOptions, pruneOpts *config.CachePruneOptions, dryRun bool) ([]CacheEntry, error) {
+	repo, err := Repo(opts)
+	if err != nil {
+		return nil, err
+	}
+	var entries cacheEntries
+	switch {
+	case strings.HasPrefix(repo, "oci:"):
+		entries = &layoutCacheEntries{path: strings.TrimPrefix(repo, "oci:")}
+	case IsObjectStorage(repo):
+		return nil, fmt.Errorf("pruning object storage cache %s is not supported", repo)
+	default:
+		entries, err = newRegistryCacheEntries(repo, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	all, err := entries.List()
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing cache entries of %s", repo)
+	}
+	pruned := entriesToPrune(all, time.Now(), opts.CacheTTL, pruneOpts)
+	if dryRun {
+		return pruned, nil
+	}
+	for _, entry := range pruned {
+		logrus.Infof("Deleting cache entry %s", entry.Key)
+		if err := entries.Delete(entry); err != nil {
+			return nil, errors.Wrapf(err, "deleting cache entry %s", entry.Key)
+		}
+	}
+	return pruned, nil
+}
+
+// entriesToPrune returns the entries older than ttl, and the oldest ones
+// beyond the number of entries and total size of the budget. A zero ttl or
+// budget is no limit.
+func entriesToPrune(entries []CacheEntry, now time.Time, ttl time.Duration, budget *config.CachePruneOptions) []CacheEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+	var pruned []CacheEntry
+	kept := 0
+	var keptSize int64
+	for _, entry := range entries {
+		switch {
+		case ttl > 0 && entry.Created.Add(ttl).Before(now):
+		case budget.MaxEntries > 0 && kept >= budget.MaxEntries:
+		case budget.MaxSize > 0 && keptSize+entry.Size > budget.MaxSize:
+		default:
+			kept++
+			keptSize += entry.Size
+			continue
+		}
+		pruned = append(pruned, entry)
+	}
+	return pruned
+}
+
+// entryOf returns the cache entry of the cached image of key.
+func entryOf(key string, img v1.Image) (CacheEntry, error) {
+	cf, err := img.ConfigFile()
+	if err != nil {
+		return CacheEntry{}, errors.Wrap(err, "retrieving config file")
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return CacheEntry{}, errors.Wrap(err, "retrieving manifest")
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return CacheEntry{}, errors.Wrap(err, "retrieving digest")
+	}
+	entry := CacheEntry{Key: key, Created: cf.Created.Time, digest: digest}
+	for _, l := range m.Layers {
+		entry.Size += l.Size
+	}
+	return entry, nil
+}
+
+// registryCacheEntries are the tags of a registry cache repo.
+type registryCacheEntries struct {
+	repo    name.Repository
+	options []remote.Option
+}
+
+func newRegistryCacheEntries(repo string, opts *config.KanikoOptions) (*registryCacheEntries, error) {
+	repoRef, options, err := cacheRepository(repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &registryCacheEntries{
+		repo:    repoRef,
+		options: options,
+	}, nil
+}
+
+// cacheRepository returns the reference of the cache repo and the options to
+// access it with.
+func cacheRepository(repo string, opts *config.KanikoOptions) (name.Repository, []remote.Option, error) {
+	repoRef, err := name.NewRepository(repo, name.WeakValidation)
+	if err != nil {
+		return name.Repository{}, nil, errors.Wrapf(err, "getting reference for %s", repo)
+	}
+	registryName := repoRef.Registry.Name()
+	if opts.Insecure || opts.InsecureRegistries.Contains(registryName) {
+		newReg, err := name.NewRegistry(registryName, name.WeakValidation, name.Insecure)
+		if err != nil {
+			return name.Repository{}, nil, err
+		}
+		repoRef.Registry = newReg
+	}
+	tr, err := util.MakeTransport(opts.RegistryOptions, registryName)
+	if err != nil {
+		return name.Repository{}, nil, errors.Wrapf(err, "making transport for registry %q", registryName)
+	}
+	return repoRef, []remote.Option{remote.WithTransport(tr), remote.WithAuthFromKeychain(creds.GetKeychain())}, nil
+}
+
+// List lists the tags of the repo named like cache keys, which other images
+// of the repo aren't, and the cache index isn't either. The tags which aren't
+// images are skipped.
+func (r *registryCacheEntries) List() ([]CacheEntry, error) {
+	tags, err := remote.List(r.repo, r.options...)
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, tag := range tags {
+		if !cacheKeyRegexp.MatchString(tag) {
+			logrus.Debugf("Skipping tag %s, it isn't a cache key", tag)
+			continue
+		}
+		img, err := remote.Image(r.repo.Tag(tag), r.options...)
+		if err != nil {
+			logrus.Warnf("Skipping cache entry %s: %s", tag, err)
+			continue
+		}
+		entry, err := entryOf(tag, img)
+		if err != nil {
+			logrus.Warnf("Skipping cache entry %s: %s", tag, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Delete deletes the tag of the entry. Its manifest is only deleted by digest
+// if the registry doesn't support deleting tags, as other tags may refer to
+// it.
+func (r *registryCacheEntries) Delete(entry CacheEntry) error {
+	err := remote.Delete(r.repo.Tag(entry.Key), r.options...)
+	if err == nil || !isUnsupported(err) {
+		return err
+	}
+	logrus.Infof("Deleting tags isn't supported, deleting the manifest of cache entry %s", entry.Key)
+	return remote.Delete(r.repo.Digest(entry.digest.String()), r.options...)
+}
+
+// isUnsupported returns true if the registry doesn't support a request.
+func isUnsupported(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	if terr.StatusCode == http.StatusMethodNotAllowed {
+		return true
+	}
+	for _, d := range terr.Errors {
+		if d.Code == transport.UnsupportedErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutCacheEntries are the OCI image layouts of an 'oci:' cache repo, which
+// stores each entry in a layout at '<path>:<cache key>'.
+type layoutCacheEntries struct {
+	path string
+}
+
+func (l *layoutCacheEntries) List() ([]CacheEntry, error) {
+	matches, err := filepath.Glob(l.path + ":*")
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, match := range matches {
+		key := strings.TrimPrefix(match, l.path+":")
+		if !cacheKeyRegexp.MatchString(key) {
+			logrus.Debugf("Skipping %s, it isn't a cache entry", match)
+			continue
+		}
+		img, err := locateImage(match)
+		if err != nil {
+			logrus.Warnf("Skipping cache entry %s: %s", key, err)
+			continue
+		}
+		entry, err := entryOf(key, img)
+		if err != nil {
+			logrus.Warnf("Skipping cache entry %s: %s", key, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (l *layoutCacheEntries) Delete(entry CacheEntry) error {
+	return os.RemoveAll(l.path + ":" + entry.Key)
+}
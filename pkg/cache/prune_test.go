@@ -0,0 +1,266 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func Test_entriesToPrune(t *testing.T) {
+	now := time.Now()
+	entries := []CacheEntry{
+		{Key: "a", Created: now.Add(-3 * time.Hour), Size: 10},
+		{Key: "b", Created: now.Add(-time.Hour), Size: 10},
+		{Key: "c", Created: now.Add(-2 * time.Hour), Size: 10},
+		{Key: "d", Created: now.Add(-30 * time.Hour), Size: 10},
+	}
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		budget config.CachePruneOptions
+		want   []string
+	}{
+		{name: "no limit"},
+		{name: "ttl", ttl: 24 * time.Hour, want: []string{"d"}},
+		{name: "max entries", budget: config.CachePruneOptions{MaxEntries: 2}, want: []string{"a", "d"}},
+		{name: "max size", budget: config.CachePruneOptions{MaxSize: 25}, want: []string{"a", "d"}},
+		{name: "ttl and max entries", ttl: 150 * time.Minute, budget: config.CachePruneOptions{MaxEntries: 1}, want: []string{"c", "a", "d"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range entriesToPrune(append([]CacheEntry{}, entries...), now, test.ttl, &test.budget) {
+				got = append(got, entry.Key)
+			}
+			testutil.CheckDeepEqual(t, test.want, got)
+		})
+	}
+}
+
+// cacheImage returns an image like the ones pushed to the layer cache.
+func cacheImage(t *testing.T, created time.Time) v1.Image {
+	t.Helper()
+	layer, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.CreatedAt(img, v1.Time{Time: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// cacheKey returns a cache key named after name, and records the name of the
+// key in names.
+func cacheKey(names map[string]string, name string) string {
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+	names[key] = name
+	return key
+}
+
+// namesOf returns the sorted names of the keys of the entries.
+func namesOf(names map[string]string, entries []CacheEntry) []string {
+	var got []string
+	for _, key := range keys(entries) {
+		got = append(got, names[key])
+	}
+	sort.Strings(got)
+	return got
+}
+
+func Test_PruneCache_Registry(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := u.Host + "/cache"
+	write := func(tag string, img v1.Image) {
+		ref, err := name.NewTag(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	names := map[string]string{}
+	var older v1.Image
+	for key, age := range map[string]time.Duration{"old": 48 * time.Hour, "older": 72 * time.Hour, "new": time.Hour, "newer": time.Minute} {
+		img := cacheImage(t, now.Add(-age))
+		if key == "older" {
+			older = img
+		}
+		write(cacheKey(names, key), img)
+	}
+	// The other images of the repo are left alone, even if they share the
+	// manifest of an entry, and the tags which can't be read are skipped.
+	write("latest", cacheImage(t, now.Add(-96*time.Hour)))
+	write("v1", older)
+	indexRef, err := name.NewTag(repo + ":" + cacheKey(names, "index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(indexRef, empty.Index); err != nil {
+		t.Fatal(err)
+	}
+	list := func() []string {
+		repoRef, err := name.NewRepository(repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags, err := remote.List(repoRef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tag := range tags {
+			if n, ok := names[tag]; ok {
+				tag = n
+			}
+			got = append(got, tag)
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	opts := &config.KanikoOptions{CacheRepo: repo, CacheOptions: config.CacheOptions{CacheTTL: 24 * time.Hour}}
+	budget := &config.CachePruneOptions{MaxEntries: 1}
+
+	pruned, err := PruneCache(opts, budget, true)
+	testutil.CheckErrorAndDeepEqual(t, false, err, []string{"new", "old", "older"}, namesOf(names, pruned))
+	testutil.CheckDeepEqual(t, []string{"index", "latest", "new", "newer", "old", "older", "v1"}, list())
+
+	pruned, err = PruneCache(opts, budget, false)
+	testutil.CheckErrorAndDeepEqual(t, false, err, []string{"new", "old", "older"}, namesOf(names, pruned))
+	testutil.CheckDeepEqual(t, []string{"index", "latest", "newer", "v1"}, list())
+}
+
+func Test_registryCacheEntries_Delete_Unsupported(t *testing.T) {
+	// A registry which only deletes manifests by digest.
+	reg := registry.New()
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			ref := path.Base(r.URL.Path)
+			if !strings.HasPrefix(ref, "sha256:") {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			deleted = append(deleted, ref)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := u.Host + "/cache"
+	key := cacheKey(map[string]string{}, "entry")
+	ref, err := name.NewTag(repo + ":" + key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := cacheImage(t, time.Now())
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := newRegistryCacheEntries(repo, &config.KanikoOptions{})
+	testutil.CheckNoError(t, err)
+	all, err := entries.List()
+	testutil.CheckErrorAndDeepEqual(t, false, err, 1, len(all))
+	testutil.CheckNoError(t, entries.Delete(all[0]))
+	digest, err := img.Digest()
+	testutil.CheckErrorAndDeepEqual(t, false, err, []string{digest.String()}, deleted)
+}
+
+func Test_PruneCache_Layout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	now := time.Now()
+	names := map[string]string{}
+	for key, age := range map[string]time.Duration{"old": 48 * time.Hour, "new": time.Hour} {
+		p, err := layout.Write(path+":"+cacheKey(names, key), empty.Index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AppendImage(cacheImage(t, now.Add(-age))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Neither other layouts nor unreadable entries are pruned.
+	if _, err := layout.Write(path+":latest", empty.Index); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := layout.Write(path+":"+cacheKey(names, "empty"), empty.Index); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &config.KanikoOptions{CacheRepo: "oci:" + path, CacheOptions: config.CacheOptions{CacheTTL: 24 * time.Hour}}
+	pruned, err := PruneCache(opts, &config.CachePruneOptions{}, false)
+	testutil.CheckErrorAndDeepEqual(t, false, err, []string{"old"}, namesOf(names, pruned))
+	remaining, err := filepath.Glob(path + ":*")
+	testutil.CheckErrorAndDeepEqual(t, false, err, 3, len(remaining))
+	_, err = os.Stat(path + ":" + cacheKey(names, "new"))
+	testutil.CheckNoError(t, err)
+}
+
+func Test_PruneCache_ObjectStorage(t *testing.T) {
+	_, err := PruneCache(&config.KanikoOptions{CacheRepo: "s3://bucket/cache"}, &config.CachePruneOptions{}, true)
+	testutil.CheckError(t, true, err)
+}
+
+func keys(entries []CacheEntry) []string {
+	var keys []string
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
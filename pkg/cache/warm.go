@@ -21,8 +21,8 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"path"
 	"regexp"
+	"sync/atomic"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
@@ -30,9 +30,9 @@ import (
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
 // WarmCache populates the cache
@@ -55,70 +55,46 @@ func WarmCache(opts *config.WarmerOptions) error {
 	logrus.Debugf("%s\n", cacheDir)
 	logrus.Debugf("%s\n", images)
 
-	errs := 0
-	for _, img := range images {
-		err := warmToFile(cacheDir, img, opts)
-		if err != nil {
-			logrus.Warnf("Error while trying to warm image: %v %v", img, err)
-			errs++
-		}
-	}
-
-	if len(images) == errs {
-		return errors.New("Failed to warm any of the given images")
-	}
-
-	return nil
-}
-
-// Download image in temporary files then move files to final destination
-func warmToFile(cacheDir, img string, opts *config.WarmerOptions) error {
-	f, err := os.CreateTemp(cacheDir, "warmingImage.*")
-	if err != nil {
-		return err
-	}
-	// defer called in reverse order
-	defer os.Remove(f.Name())
-	defer f.Close()
-
-	mtfsFile, err := os.CreateTemp(cacheDir, "warmingManifest.*")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(mtfsFile.Name())
-	defer mtfsFile.Close()
-
 	cw := &Warmer{
-		Remote:         remote.RetrieveRemoteImage,
-		Local:          LocalSource,
-		TarWriter:      f,
-		ManifestWriter: mtfsFile,
-	}
-
-	digest, err := cw.Warm(img, opts)
-	if err != nil {
-		if IsAlreadyCached(err) {
-			logrus.Infof("Image already in cache: %v", img)
+		Remote:     remote.RetrieveRemoteImage,
+		Descriptor: remote.RetrieveRemoteDescriptor,
+		Blobs:      remote.RetrieveRemoteBlobOpener,
+		Local:      LocalSource,
+		Store: &LocalStore{
+			Dir:         cacheDir,
+			MaxSize:     opts.CacheMaxSize,
+			Parallelism: opts.Parallelism,
+			Retries:     opts.ImageDownloadRetry,
+		},
+	}
+	warm := cw.Warm
+	if opts.OCILayout {
+		warm = cw.WarmLayout
+	}
+	var errs atomic.Int32
+	var g errgroup.Group
+	g.SetLimit(max(opts.Parallelism, 1))
+	for _, img := range images {
+		g.Go(func() error {
+			if _, err := warm(img, opts); err != nil {
+				if IsAlreadyCached(err) {
+					logrus.Infof("Image already in cache: %v", img)
+					return nil
+				}
+				logrus.Warnf("Error while trying to warm image: %v %v", img, err)
+				errs.Add(1)
+				return nil
+			}
+			logrus.Debugf("Wrote %s to cache", img)
 			return nil
-		}
-		logrus.Warnf("Error while trying to warm image: %v %v", img, err)
-		return err
-	}
-
-	finalCachePath := path.Join(cacheDir, digest.String())
-	finalMfstPath := finalCachePath + ".json"
-
-	err = os.Rename(f.Name(), finalCachePath)
-	if err != nil {
-		return err
+		})
 	}
+	_ = g.Wait()
 
-	err = os.Rename(mtfsFile.Name(), finalMfstPath)
-	if err != nil {
-		return errors.Wrap(err, "Failed to rename manifest file")
+	if len(images) == int(errs.Load()) {
+		return errors.New("Failed to warm any of the given images")
 	}
 
-	logrus.Debugf("Wrote %s to cache", img)
 	return nil
 }
 
@@ -127,6 +103,11 @@ func warmToFile(cacheDir, img string, opts *config.WarmerOptions) error {
 // this type.
 type FetchRemoteImage func(image string, opts config.RegistryOptions, customPlatform string) (v1.Image, error)
 
+// FetchRemoteBlobs returns the function opening the blobs of an image from a
+// remote source. github.com/GoogleContainerTools/kaniko/image/remote.RetrieveRemoteBlobOpener
+// can be used as this type.
+type FetchRemoteBlobs func(image string, opts config.RegistryOptions, customPlatform string) (remote.BlobOpener, error)
+
 // FetchLocalSource retrieves a Docker image manifest from a local source.
 // github.com/GoogleContainerTools/kaniko/cache.LocalSource can be used as
 // this type.
@@ -134,17 +115,17 @@ type FetchLocalSource func(*config.CacheOptions, string) (v1.Image, error)
 
 // Warmer is used to prepopulate the cache with a Docker image
 type Warmer struct {
-	Remote         FetchRemoteImage
-	Local          FetchLocalSource
-	TarWriter      io.Writer
-	ManifestWriter io.Writer
+	Remote     FetchRemoteImage
+	Descriptor FetchRemoteDescriptor
+	Blobs      FetchRemoteBlobs
+	Local      FetchLocalSource
+	Store      *LocalStore
 }
 
-// Warm retrieves a Docker image and populates the supplied buffer with the image content and manifest
+// Warm retrieves a Docker image and writes it to the local store
 // or returns an AlreadyCachedErr if the image is present in the cache.
 func (w *Warmer) Warm(image string, opts *config.WarmerOptions) (v1.Hash, error) {
-	cacheRef, err := name.ParseReference(image, name.WeakValidation)
-	if err != nil {
+	if _, err := name.ParseReference(image, name.WeakValidation); err != nil {
 		return v1.Hash{}, errors.Wrapf(err, "Failed to verify image name: %s", image)
 	}
 
@@ -165,21 +146,25 @@ func (w *Warmer) Warm(image string, opts *config.WarmerOptions) (v1.Hash, error)
 		}
 	}
 
-	err = tarball.Write(cacheRef, img, w.TarWriter)
-	if err != nil {
-		return v1.Hash{}, errors.Wrapf(err, "Failed to write %s to tar buffer", image)
+	if _, err := w.Store.Write(img, w.blobOpener(image, opts), NewProgress(image)); err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "Failed to write %s to the cache", image)
 	}
 
-	mfst, err := img.RawManifest()
-	if err != nil {
-		return v1.Hash{}, errors.Wrapf(err, "Failed to retrieve manifest for %s", image)
-	}
+	return digest, nil
+}
 
-	if _, err := w.ManifestWriter.Write(mfst); err != nil {
-		return v1.Hash{}, errors.Wrapf(err, "Failed to save manifest to buffer for %s", image)
+// blobOpener returns the opener of the blobs of image, or nil if there is
+// none, in which case the layers are downloaded without resuming them.
+func (w *Warmer) blobOpener(image string, opts *config.WarmerOptions) remote.BlobOpener {
+	if w.Blobs == nil {
+		return nil
 	}
-
-	return digest, nil
+	open, err := w.Blobs(image, opts.RegistryOptions, opts.CustomPlatform)
+	if err != nil {
+		logrus.Warnf("Downloads of %s won't be resumed: %s", image, err)
+		return nil
+	}
+	return open
 }
 
 func ParseDockerfile(opts *config.WarmerOptions) ([]string, error) {
@@ -206,8 +191,12 @@ func ParseDockerfile(opts *config.WarmerOptions) ([]string, error) {
 		return nil, errors.Wrap(err, "parsing dockerfile")
 	}
 
+	buildArgs, err := dockerfile.BuildArgsWithPlatform(opts.BuildArgs, opts.CustomPlatform)
+	if err != nil {
+		return nil, err
+	}
 	for i, s := range stages {
-		resolvedBaseName, err := util.ResolveEnvironmentReplacement(s.BaseName, opts.BuildArgs, false)
+		resolvedBaseName, err := util.ResolveEnvironmentReplacement(s.BaseName, buildArgs, false)
 		if err != nil {
 			return nil, errors.Wrap(err, fmt.Sprintf("resolving base name %s", s.BaseName))
 		}
@@ -0,0 +1,267 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// FetchRemoteDescriptor retrieves the descriptor of an image from a remote
+// source. github.com/GoogleContainerTools/kaniko/image/remote.RetrieveRemoteDescriptor
+// can be used as this type.
+type FetchRemoteDescriptor func(image string, opts config.RegistryOptions, customPlatform string) (*remote.Descriptor, error)
+
+// WarmLayout retrieves a Docker image and writes it to the OCI image layout of
+// the local store, named after the image. The image index of a multi-platform
+// image is kept, with the images of opts.Platforms only if any is given. It
+// returns an AlreadyCachedErr if the layout has the image already.
+func (w *Warmer) WarmLayout(image string, opts *config.WarmerOptions) (v1.Hash, error) {
+	ref, err := name.ParseReference(image, name.WeakValidation)
+	if err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "Failed to verify image name: %s", image)
+	}
+
+	desc, err := w.Descriptor(image, opts.RegistryOptions, opts.CustomPlatform)
+	if err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "Failed to retrieve image: %s", image)
+	}
+
+	var add mutate.Appendable
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return v1.Hash{}, errors.Wrapf(err, "Failed to retrieve image index: %s", image)
+		}
+		if idx, err = selectPlatforms(idx, opts.Platforms); err != nil {
+			return v1.Hash{}, errors.Wrap(err, image)
+		}
+		add = idx
+	} else {
+		img, err := desc.Image()
+		if err != nil {
+			return v1.Hash{}, errors.Wrapf(err, "Failed to retrieve image: %s", image)
+		}
+		add = img
+	}
+
+	digest, err := add.Digest()
+	if err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "Failed to retrieve digest: %s", image)
+	}
+	if !opts.Force && w.inLayout(ref.Name(), digest, opts.CacheTTL) {
+		return v1.Hash{}, AlreadyCachedErr{}
+	}
+
+	// The blobs are downloaded first, concurrently with other images, the
+	// layout then refers to them.
+	if err := w.writeLayout(image, add, opts); err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "Failed to write %s to the cache", image)
+	}
+	if err := w.addToLayout(ref.Name(), add); err != nil {
+		return v1.Hash{}, errors.Wrapf(err, "Failed to write %s to the cache", image)
+	}
+	return digest, nil
+}
+
+// writeLayout writes the blobs of image, an image or image index, to the
+// layout of the local store. The layers are downloaded like the ones of the
+// images of the local store, then the configs and manifests are written, the
+// manifests being rewritten so that their modification time is the time the
+// image was cached.
+func (w *Warmer) writeLayout(image string, add mutate.Appendable, opts *config.WarmerOptions) error {
+	images, indexes, err := layoutManifests(add)
+	if err != nil {
+		return err
+	}
+	var layers []v1.Layer
+	for _, img := range images {
+		l, err := img.Layers()
+		if err != nil {
+			return errors.Wrap(err, "getting layers")
+		}
+		layers = append(layers, l...)
+	}
+	if err := w.Store.writeLayers(layers, w.blobOpener(image, opts), NewProgress(image)); err != nil {
+		return err
+	}
+	for _, img := range images {
+		if err := w.Store.writeManifest(img); err != nil {
+			return err
+		}
+	}
+	for _, idx := range indexes {
+		digest, err := idx.Digest()
+		if err != nil {
+			return errors.Wrap(err, "getting digest")
+		}
+		rawManifest, err := idx.RawManifest()
+		if err != nil {
+			return errors.Wrap(err, "getting manifest")
+		}
+		if err := w.Store.writeBlob(digest, bytes.NewReader(rawManifest)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layoutManifests returns the images of the image or image index, and the
+// image indexes, the nested ones first.
+func layoutManifests(add mutate.Appendable) ([]v1.Image, []v1.ImageIndex, error) {
+	idx, ok := add.(v1.ImageIndex)
+	if !ok {
+		img, ok := add.(v1.Image)
+		if !ok {
+			return nil, nil, errors.Errorf("unexpected manifest %T", add)
+		}
+		return []v1.Image{img}, nil, nil
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "getting image index")
+	}
+	var images []v1.Image
+	var indexes []v1.ImageIndex
+	for _, d := range manifest.Manifests {
+		switch {
+		case d.MediaType.IsImage():
+			img, err := idx.Image(d.Digest)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "getting image %s", d.Digest)
+			}
+			images = append(images, img)
+		case d.MediaType.IsIndex():
+			child, err := idx.ImageIndex(d.Digest)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "getting image index %s", d.Digest)
+			}
+			childImages, childIndexes, err := layoutManifests(child)
+			if err != nil {
+				return nil, nil, err
+			}
+			images = append(images, childImages...)
+			indexes = append(indexes, childIndexes...)
+		}
+	}
+	return images, append(indexes, idx), nil
+}
+
+// addToLayout names the image or image index in the index.json of the layout,
+// replacing the one of the same name.
+func (w *Warmer) addToLayout(ref string, add mutate.Appendable) error {
+	w.Store.mu.Lock()
+	defer w.Store.mu.Unlock()
+	p, err := openLayout(w.Store.Dir)
+	if err != nil {
+		return err
+	}
+	// The blobs are written already, only the descriptor is replaced.
+	desc, err := partial.Descriptor(add)
+	if err != nil {
+		return err
+	}
+	desc.Annotations = map[string]string{imagespec.AnnotationRefName: ref}
+	if err := p.RemoveDescriptors(match.Name(ref)); err != nil {
+		return err
+	}
+	return p.AppendDescriptor(*desc)
+}
+
+// inLayout returns true if the layout of the local store has the image with
+// the given name and digest, cached less than ttl ago.
+func (w *Warmer) inLayout(ref string, digest v1.Hash, ttl time.Duration) bool {
+	idx, err := layout.ImageIndexFromPath(w.Store.Dir)
+	if err != nil {
+		return false
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return false
+	}
+	found := slices.ContainsFunc(manifest.Manifests, func(d v1.Descriptor) bool {
+		return d.Digest == digest && match.Name(ref)(d)
+	})
+	if !found {
+		return false
+	}
+	fi, err := os.Stat(w.Store.blobPath(digest))
+	return err == nil && fi.ModTime().Add(ttl).After(time.Now())
+}
+
+// openLayout opens the OCI image layout at path, creating it if needed.
+func openLayout(path string) (layout.Path, error) {
+	if p, err := layout.FromPath(path); err == nil {
+		return p, nil
+	}
+	p, err := layout.Write(path, empty.Index)
+	if err != nil {
+		return "", errors.Wrapf(err, "creating OCI image layout %s", path)
+	}
+	return p, nil
+}
+
+// selectPlatforms removes the images of the image index which are not for any
+// of the given platforms, and fails if there is no image for one of them.
+func selectPlatforms(idx v1.ImageIndex, platforms []string) (v1.ImageIndex, error) {
+	if len(platforms) == 0 {
+		return idx, nil
+	}
+	var specs []v1.Platform
+	for _, p := range platforms {
+		spec, err := v1.ParsePlatform(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing platform %s", p)
+		}
+		specs = append(specs, *spec)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		if !slices.ContainsFunc(manifest.Manifests, platformMatcher(spec)) {
+			return nil, errors.Errorf("no image for platform %s", spec)
+		}
+	}
+	return mutate.RemoveManifests(idx, func(d v1.Descriptor) bool {
+		return !slices.ContainsFunc(specs, func(spec v1.Platform) bool {
+			return platformMatcher(spec)(d)
+		})
+	}), nil
+}
+
+func platformMatcher(spec v1.Platform) match.Matcher {
+	return func(d v1.Descriptor) bool {
+		return d.Platform != nil && d.Platform.Satisfies(spec)
+	}
+}
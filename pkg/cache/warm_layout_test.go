@@ -0,0 +1,254 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	kremote "github.com/GoogleContainerTools/kaniko/pkg/image/remote"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// pushMultiPlatformImage pushes an image index with an image for each
+// platform to an in-memory registry, and returns its name with the digests of
+// the images.
+func pushMultiPlatformImage(t *testing.T, platforms ...string) (string, map[string]v1.Hash) {
+	t.Helper()
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := u.Host + "/base:latest"
+
+	digests := map[string]v1.Hash{}
+	var idx v1.ImageIndex = empty.Index
+	for _, p := range platforms {
+		platform, err := v1.ParsePlatform(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := randomImage(t)
+		if digests[p], err = img.Digest(); err != nil {
+			t.Fatal(err)
+		}
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: platform}})
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+	return image, digests
+}
+
+func getDescriptor(image string, _ config.RegistryOptions, _ string) (*remote.Descriptor, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	return remote.Get(ref)
+}
+
+func Test_Warmer_WarmLayout(t *testing.T) {
+	image, digests := pushMultiPlatformImage(t, "linux/amd64", "linux/arm64")
+	opts := &config.WarmerOptions{CacheOptions: config.CacheOptions{CacheDir: t.TempDir(), CacheTTL: time.Hour}}
+	w := &Warmer{Descriptor: getDescriptor, Store: &LocalStore{Dir: opts.CacheDir}}
+
+	_, err := w.WarmLayout(image, opts)
+	testutil.CheckNoError(t, err)
+
+	// The layout has the image index, named after the image.
+	idx, err := layout.ImageIndexFromPath(opts.CacheDir)
+	testutil.CheckNoError(t, err)
+	manifest, err := idx.IndexManifest()
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, 1, len(manifest.Manifests))
+	ref, err := name.ParseReference(image)
+	testutil.CheckErrorAndDeepEqual(t, false, err, ref.Name(), manifest.Manifests[0].Annotations["org.opencontainers.image.ref.name"])
+
+	// Builds for either platform find their image.
+	for _, digest := range digests {
+		img, err := LocalSource(&opts.CacheOptions, digest.String())
+		testutil.CheckNoError(t, err)
+		got, err := img.Digest()
+		testutil.CheckErrorAndDeepEqual(t, false, err, digest, got)
+	}
+
+	_, err = w.WarmLayout(image, opts)
+	testutil.CheckDeepEqual(t, true, IsAlreadyCached(err))
+
+	opts.Force = true
+	_, err = w.WarmLayout(image, opts)
+	testutil.CheckNoError(t, err)
+	idx, err = layout.ImageIndexFromPath(opts.CacheDir)
+	testutil.CheckNoError(t, err)
+	manifest, err = idx.IndexManifest()
+	testutil.CheckErrorAndDeepEqual(t, false, err, 1, len(manifest.Manifests))
+}
+
+func Test_Warmer_WarmLayout_Platforms(t *testing.T) {
+	image, digests := pushMultiPlatformImage(t, "linux/amd64", "linux/arm64/v8", "linux/s390x")
+
+	tests := []struct {
+		name      string
+		platforms []string
+		want      []string
+		shdErr    bool
+	}{
+		{
+			name:      "selected platforms",
+			platforms: []string{"linux/amd64", "linux/arm64"},
+			want:      []string{"linux/amd64", "linux/arm64/v8"},
+		},
+		{
+			name:      "missing platform",
+			platforms: []string{"linux/amd64", "windows/amd64"},
+			shdErr:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := &config.WarmerOptions{
+				CacheOptions: config.CacheOptions{CacheDir: t.TempDir(), CacheTTL: time.Hour},
+				Platforms:    test.platforms,
+			}
+			w := &Warmer{Descriptor: getDescriptor, Store: &LocalStore{Dir: opts.CacheDir}}
+
+			_, err := w.WarmLayout(image, opts)
+			testutil.CheckError(t, test.shdErr, err)
+			if test.shdErr {
+				return
+			}
+			for platform, digest := range digests {
+				_, err := LocalSource(&opts.CacheOptions, digest.String())
+				wantFound := false
+				for _, p := range test.want {
+					wantFound = wantFound || p == platform
+				}
+				testutil.CheckDeepEqual(t, wantFound, err == nil)
+			}
+		})
+	}
+}
+
+func Test_Warmer_WarmLayout_Resume(t *testing.T) {
+	image, _ := pushMultiPlatformImage(t, "linux/amd64", "linux/arm64")
+	desc, err := getDescriptor(image, config.RegistryOptions{}, "")
+	testutil.CheckNoError(t, err)
+	idx, err := desc.ImageIndex()
+	testutil.CheckNoError(t, err)
+	images, _, err := layoutManifests(idx)
+	testutil.CheckNoError(t, err)
+	blobs := map[v1.Hash][]byte{}
+	for _, img := range images {
+		layers, err := img.Layers()
+		testutil.CheckNoError(t, err)
+		for _, l := range layers {
+			d, err := l.Digest()
+			testutil.CheckNoError(t, err)
+			rc, err := l.Compressed()
+			testutil.CheckNoError(t, err)
+			blobs[d], err = io.ReadAll(rc)
+			testutil.CheckNoError(t, err)
+			rc.Close()
+		}
+	}
+
+	opts := &config.WarmerOptions{CacheOptions: config.CacheOptions{CacheDir: t.TempDir(), CacheTTL: time.Hour}, OCILayout: true}
+	store := &LocalStore{Dir: opts.CacheDir, Parallelism: 1, Retries: 1}
+	// A previous warming downloaded half of a layer.
+	var resumed v1.Hash
+	for d := range blobs {
+		resumed = d
+	}
+	half := int64(len(blobs[resumed]) / 2)
+	path := store.blobPath(resumed) + ".partial"
+	testutil.CheckNoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	testutil.CheckNoError(t, os.WriteFile(path, blobs[resumed][:half], 0644))
+
+	var mu sync.Mutex
+	requests := map[v1.Hash][]int64{}
+	var downloading, maxDownloading atomic.Int32
+	open := func(digest v1.Hash, size, offset int64) (io.ReadCloser, int64, error) {
+		mu.Lock()
+		requests[digest] = append(requests[digest], offset)
+		mu.Unlock()
+		maxDownloading.Store(max(maxDownloading.Load(), downloading.Add(1)))
+		return closeFunc{bytes.NewReader(blobs[digest][offset:]), func() { downloading.Add(-1) }}, offset, nil
+	}
+	w := &Warmer{
+		Descriptor: getDescriptor,
+		Blobs: func(string, config.RegistryOptions, string) (kremote.BlobOpener, error) {
+			return open, nil
+		},
+		Store: store,
+	}
+	_, err = w.WarmLayout(image, opts)
+	testutil.CheckNoError(t, err)
+
+	// Each layer is downloaded once through the opener, one at a time, the
+	// partial one from where it stopped.
+	testutil.CheckDeepEqual(t, len(blobs), len(requests))
+	for d, offsets := range requests {
+		want := []int64{0}
+		if d == resumed {
+			want = []int64{half}
+		}
+		testutil.CheckDeepEqual(t, want, offsets)
+		_, err := os.Stat(store.blobPath(d) + ".partial")
+		testutil.CheckDeepEqual(t, true, os.IsNotExist(err))
+	}
+	testutil.CheckDeepEqual(t, int32(1), maxDownloading.Load())
+	for _, img := range images {
+		digest, err := img.Digest()
+		testutil.CheckNoError(t, err)
+		_, err = LocalSource(&opts.CacheOptions, digest.String())
+		testutil.CheckNoError(t, err)
+	}
+}
+
+// closeFunc calls close when it is closed.
+type closeFunc struct {
+	io.Reader
+	close func()
+}
+
+func (c closeFunc) Close() error {
+	c.close()
+	return nil
+}
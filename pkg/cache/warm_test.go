@@ -17,13 +17,14 @@ limitations under the License.
 package cache
 
 import (
-	"bytes"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/fakes"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 )
 
 const (
@@ -31,36 +32,37 @@ const (
 )
 
 func Test_Warmer_Warm_not_in_cache(t *testing.T) {
-	tarBuf := new(bytes.Buffer)
-	manifestBuf := new(bytes.Buffer)
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &LocalStore{Dir: t.TempDir()}
 
 	cw := &Warmer{
 		Remote: func(_ string, _ config.RegistryOptions, _ string) (v1.Image, error) {
-			return fakes.FakeImage{}, nil
+			return img, nil
 		},
 		Local: func(_ *config.CacheOptions, _ string) (v1.Image, error) {
 			return nil, NotFoundErr{}
 		},
-		TarWriter:      tarBuf,
-		ManifestWriter: manifestBuf,
+		Store: store,
 	}
 
 	opts := &config.WarmerOptions{}
 
-	_, err := cw.Warm(image, opts)
+	digest, err := cw.Warm(image, opts)
 	if err != nil {
 		t.Errorf("expected error to be nil but was %v", err)
 		t.FailNow()
 	}
 
-	if len(tarBuf.Bytes()) == 0 {
-		t.Error("expected image to be written but buffer was empty")
+	if _, err := store.Image(digest.String(), time.Hour); err != nil {
+		t.Errorf("expected image to be written but was %v", err)
 	}
 }
 
 func Test_Warmer_Warm_in_cache_not_expired(t *testing.T) {
-	tarBuf := new(bytes.Buffer)
-	manifestBuf := new(bytes.Buffer)
+	store := &LocalStore{Dir: t.TempDir()}
 
 	cw := &Warmer{
 		Remote: func(_ string, _ config.RegistryOptions, _ string) (v1.Image, error) {
@@ -69,8 +71,7 @@ func Test_Warmer_Warm_in_cache_not_expired(t *testing.T) {
 		Local: func(_ *config.CacheOptions, _ string) (v1.Image, error) {
 			return fakes.FakeImage{}, nil
 		},
-		TarWriter:      tarBuf,
-		ManifestWriter: manifestBuf,
+		Store: store,
 	}
 
 	opts := &config.WarmerOptions{}
@@ -81,14 +82,13 @@ func Test_Warmer_Warm_in_cache_not_expired(t *testing.T) {
 		t.FailNow()
 	}
 
-	if len(tarBuf.Bytes()) != 0 {
+	if entries, _ := os.ReadDir(store.Dir); len(entries) != 0 {
 		t.Errorf("expected nothing to be written")
 	}
 }
 
 func Test_Warmer_Warm_in_cache_expired(t *testing.T) {
-	tarBuf := new(bytes.Buffer)
-	manifestBuf := new(bytes.Buffer)
+	store := &LocalStore{Dir: t.TempDir()}
 
 	cw := &Warmer{
 		Remote: func(_ string, _ config.RegistryOptions, _ string) (v1.Image, error) {
@@ -97,8 +97,7 @@ func Test_Warmer_Warm_in_cache_expired(t *testing.T) {
 		Local: func(_ *config.CacheOptions, _ string) (v1.Image, error) {
 			return fakes.FakeImage{}, ExpiredErr{}
 		},
-		TarWriter:      tarBuf,
-		ManifestWriter: manifestBuf,
+		Store: store,
 	}
 
 	opts := &config.WarmerOptions{}
@@ -109,7 +108,7 @@ func Test_Warmer_Warm_in_cache_expired(t *testing.T) {
 		t.FailNow()
 	}
 
-	if len(tarBuf.Bytes()) != 0 {
+	if entries, _ := os.ReadDir(store.Dir); len(entries) != 0 {
 		t.Errorf("expected nothing to be written")
 	}
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package commands
 
 import (
+	"fmt"
 	"io/fs"
 	"path/filepath"
 
@@ -24,6 +25,8 @@ import (
 	"github.com/moby/buildkit/frontend/dockerfile/instructions"
 	"github.com/pkg/errors"
 
+	"github.com/GoogleContainerTools/kaniko/pkg/buildcontext"
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
@@ -35,6 +38,8 @@ type AddCommand struct {
 	cmd           *instructions.AddCommand
 	fileContext   util.FileContext
 	snapshotFiles []string
+	shdCache      bool
+	gitCommit     string
 }
 
 // ExecuteCommand executes the ADD command
@@ -42,9 +47,12 @@ type AddCommand struct {
 //  1. If <src> is a remote file URL:
 //     - destination will have permissions of 0600
 //     - If remote file has HTTP Last-Modified header, we set the mtime of the file to that timestamp
+//     - If --checksum is given, the file is verified against it
 //     - If dest doesn't end with a slash, the filepath is inferred to be <dest>/<filename>
 //  2. If <src> is a local tar archive:
 //     - it is unpacked at the dest, as 'tar -x' would
+//  3. If <src> is a git repository:
+//     - it is cloned into dest at the given ref, including its submodules
 func (a *AddCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.BuildArgs) error {
 	replacementEnvs := buildArgs.ReplacementEnvs(config.Env)
 
@@ -52,20 +60,42 @@ func (a *AddCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.Bui
 	if err != nil {
 		return errors.Wrap(err, "getting permissions from chmod")
 	}
-	if useDefaultChmod {
-		chmod = fs.FileMode(0o600)
-	}
 
 	uid, gid, err := util.GetUserGroup(a.cmd.Chown, replacementEnvs)
 	if err != nil {
 		return errors.Wrap(err, "getting user group from chown")
 	}
 
+	source, isGit, err := addGitSource(a.cmd, replacementEnvs)
+	if err != nil {
+		return err
+	}
+	if isGit {
+		return a.addGit(config, source, replacementEnvs, uid, gid, chmod, useDefaultChmod)
+	}
+
+	if useDefaultChmod {
+		chmod = fs.FileMode(0o600)
+	}
+
 	srcs, dest, err := util.ResolveEnvAndWildcards(a.cmd.SourcesAndDest, a.fileContext, replacementEnvs)
 	if err != nil {
 		return err
 	}
 
+	checksum, err := a.Checksum(replacementEnvs)
+	if err != nil {
+		return err
+	}
+	if checksum != "" {
+		if len(srcs) != 1 {
+			return errors.New("checksum can't be specified for multiple sources")
+		}
+		if !util.IsSrcRemoteFileURL(srcs[0]) {
+			return errors.New("checksum can't be specified for non-HTTP(S) sources")
+		}
+	}
+
 	var unresolvedSrcs []string
 	// If any of the sources are local tar archives:
 	// 	1. Unpack them to the specified destination
@@ -80,7 +110,7 @@ func (a *AddCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.Bui
 				return err
 			}
 			logrus.Infof("Adding remote URL %s to %s", src, urlDest)
-			if err := util.DownloadFileToDest(src, urlDest, uid, gid, chmod); err != nil {
+			if err := util.DownloadFileToDest(src, urlDest, uid, gid, chmod, checksum); err != nil {
 				return errors.Wrap(err, "downloading remote source file")
 			}
 			a.snapshotFiles = append(a.snapshotFiles, urlDest)
@@ -107,9 +137,10 @@ func (a *AddCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.Bui
 
 	copyCmd := CopyCommand{
 		cmd: &instructions.CopyCommand{
-			SourcesAndDest: instructions.SourcesAndDest{SourcePaths: unresolvedSrcs, DestPath: dest},
-			Chown:          a.cmd.Chown,
-			Chmod:          a.cmd.Chmod,
+			SourcesAndDest:  instructions.SourcesAndDest{SourcePaths: unresolvedSrcs, DestPath: dest},
+			Chown:           a.cmd.Chown,
+			Chmod:           a.cmd.Chmod,
+			ExcludePatterns: a.cmd.ExcludePatterns,
 		},
 		fileContext: a.fileContext,
 	}
@@ -134,6 +165,10 @@ func (a *AddCommand) String() string {
 func (a *AddCommand) FilesUsedFromContext(config *v1.Config, buildArgs *dockerfile.BuildArgs) ([]string, error) {
 	replacementEnvs := buildArgs.ReplacementEnvs(config.Env)
 
+	if _, isGit, err := addGitSource(a.cmd, replacementEnvs); err != nil || isGit {
+		return []string{}, err
+	}
+
 	srcs, _, err := util.ResolveEnvAndWildcards(a.cmd.SourcesAndDest, a.fileContext, replacementEnvs)
 	if err != nil {
 		return nil, err
@@ -162,3 +197,131 @@ func (a *AddCommand) MetadataOnly() bool {
 func (a *AddCommand) RequiresUnpackedFS() bool {
 	return true
 }
+
+// ShouldCacheOutput returns true if the layer can be cached. Only a download
+// verified against a checksum or a git repository is, as the content of other
+// URLs may change.
+func (a *AddCommand) ShouldCacheOutput() bool {
+	if !a.shdCache {
+		return false
+	}
+	if len(a.cmd.SourcePaths) == 1 {
+		if _, ok := buildcontext.ParseGitSource(a.cmd.SourcePaths[0]); ok {
+			return true
+		}
+	}
+	return a.cmd.Checksum != ""
+}
+
+// Checksum returns the resolved checksum or git commit of the source, or an
+// empty string.
+func (a *AddCommand) Checksum(replacementEnvs []string) (string, error) {
+	return addSourceChecksum(a.cmd, &a.gitCommit, replacementEnvs)
+}
+
+// ExcludePatterns returns the resolved patterns of --exclude.
+func (a *AddCommand) ExcludePatterns(replacementEnvs []string) ([]string, error) {
+	return addExcludePatterns(a.cmd, replacementEnvs)
+}
+
+// CacheCommand returns a command which extracts the cached layer
+func (a *AddCommand) CacheCommand(img v1.Image) DockerCommand {
+	return &CachingAddCommand{
+		img:       img,
+		cmd:       a.cmd,
+		gitCommit: a.gitCommit,
+		extractFn: util.ExtractFile,
+	}
+}
+
+type CachingAddCommand struct {
+	BaseCommand
+	caching
+	img            v1.Image
+	extractedFiles []string
+	cmd            *instructions.AddCommand
+	gitCommit      string
+	extractFn      util.ExtractFunction
+}
+
+func (ca *CachingAddCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.BuildArgs) error {
+	logrus.Infof("Found cached layer, extracting to filesystem")
+	var err error
+
+	if ca.img == nil {
+		return errors.New(fmt.Sprintf("cached command image is nil %v", ca.String()))
+	}
+
+	layers, err := ca.img.Layers()
+	if err != nil {
+		return errors.Wrapf(err, "retrieve image layers")
+	}
+
+	if len(layers) != 1 {
+		return errors.New(fmt.Sprintf("expected %d layers but got %d", 1, len(layers)))
+	}
+
+	ca.layer = layers[0]
+	ca.extractedFiles, err = util.GetFSFromLayers(kConfig.RootDir, layers, util.ExtractFunc(ca.extractFn), util.IncludeWhiteout())
+
+	logrus.Debugf("ExtractedFiles: %s", ca.extractedFiles)
+	if err != nil {
+		return errors.Wrap(err, "extracting fs from image")
+	}
+
+	return nil
+}
+
+func (ca *CachingAddCommand) FilesToSnapshot() []string {
+	f := ca.extractedFiles
+	logrus.Debugf("%d files extracted by caching add command", len(f))
+	logrus.Tracef("Extracted files: %s", f)
+
+	return f
+}
+
+func (ca *CachingAddCommand) MetadataOnly() bool {
+	return false
+}
+
+func (ca *CachingAddCommand) String() string {
+	if ca.cmd == nil {
+		return "nil command"
+	}
+	return ca.cmd.String()
+}
+
+func (ca *CachingAddCommand) Checksum(replacementEnvs []string) (string, error) {
+	return addSourceChecksum(ca.cmd, &ca.gitCommit, replacementEnvs)
+}
+
+func (ca *CachingAddCommand) ExcludePatterns(replacementEnvs []string) ([]string, error) {
+	return addExcludePatterns(ca.cmd, replacementEnvs)
+}
+
+func addExcludePatterns(cmd *instructions.AddCommand, replacementEnvs []string) ([]string, error) {
+	excludes, err := util.ResolveEnvironmentReplacementList(cmd.ExcludePatterns, replacementEnvs, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving exclude patterns")
+	}
+	return excludes, nil
+}
+
+func addChecksum(cmd *instructions.AddCommand, replacementEnvs []string) (string, error) {
+	if cmd.Checksum == "" {
+		return "", nil
+	}
+	checksum, err := util.ResolveEnvironmentReplacement(cmd.Checksum, replacementEnvs, false)
+	if err != nil {
+		return "", errors.Wrap(err, "resolving checksum")
+	}
+	if err := util.ValidateChecksum(checksum); err != nil {
+		return "", err
+	}
+	return checksum, nil
+}
+
+// ChecksumCommand can either be an AddCommand or a CachingAddCommand.
+type ChecksumCommand interface {
+	Checksum(replacementEnvs []string) (string, error)
+}
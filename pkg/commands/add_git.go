@@ -0,0 +1,130 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/buildcontext"
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// addGitSource returns the git repository the ADD command adds, and false if
+// it doesn't add one.
+func addGitSource(cmd *instructions.AddCommand, replacementEnvs []string) (buildcontext.GitSource, bool, error) {
+	srcs, err := util.ResolveEnvironmentReplacementList(cmd.SourcePaths, replacementEnvs, false)
+	if err != nil {
+		return buildcontext.GitSource{}, false, errors.Wrap(err, "resolving sources")
+	}
+	for _, src := range srcs {
+		source, ok := buildcontext.ParseGitSource(src)
+		if !ok {
+			continue
+		}
+		if len(srcs) > 1 {
+			return buildcontext.GitSource{}, false, errors.New("git sources can't be combined with other sources")
+		}
+		return source, true, nil
+	}
+	return buildcontext.GitSource{}, false, nil
+}
+
+// addSourceChecksum returns the checksum identifying the remote source of the
+// ADD command: the commit of a git repository, or the declared checksum of a
+// URL. The commit is resolved once and kept in commit, so that the cache key
+// and the clone refer to the same commit.
+func addSourceChecksum(cmd *instructions.AddCommand, commit *string, replacementEnvs []string) (string, error) {
+	source, ok, err := addGitSource(cmd, replacementEnvs)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return addChecksum(cmd, replacementEnvs)
+	}
+	if *commit == "" {
+		resolved, err := source.Resolve()
+		if err != nil {
+			return "", errors.Wrap(err, "resolving git source")
+		}
+		*commit = resolved
+	}
+	if cmd.Checksum != "" {
+		expected, err := util.ResolveEnvironmentReplacement(cmd.Checksum, replacementEnvs, false)
+		if err != nil {
+			return "", errors.Wrap(err, "resolving checksum")
+		}
+		if !strings.HasPrefix(*commit, expected) {
+			return "", fmt.Errorf("checksum mismatch: expected commit %s, got %s", expected, *commit)
+		}
+	}
+	return *commit, nil
+}
+
+// addGit clones the git repository into dest. The .git directory is only
+// kept with --keep-git-dir.
+func (a *AddCommand) addGit(config *v1.Config, source buildcontext.GitSource, replacementEnvs []string,
+	uid, gid int64, chmod fs.FileMode, useDefaultChmod bool) error {
+	commit, err := addSourceChecksum(a.cmd, &a.gitCommit, replacementEnvs)
+	if err != nil {
+		return err
+	}
+
+	dest, err := util.ResolveEnvironmentReplacement(a.cmd.DestPath, replacementEnvs, true)
+	if err != nil {
+		return errors.Wrap(err, "resolving dest")
+	}
+	if !filepath.IsAbs(dest) {
+		cwd := config.WorkingDir
+		if cwd == "" {
+			cwd = kConfig.RootDir
+		}
+		dest = filepath.Join(cwd, dest)
+	}
+
+	dir, err := os.MkdirTemp(kConfig.KanikoDir, "git-")
+	if err != nil {
+		return errors.Wrap(err, "creating git dir")
+	}
+	defer os.RemoveAll(dir)
+
+	logrus.Infof("Cloning %s at %s", source.URL, commit)
+	if err := source.Clone(dir, commit); err != nil {
+		return errors.Wrapf(err, "cloning %s", source.URL)
+	}
+	if !a.cmd.KeepGitDir {
+		if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+			return errors.Wrap(err, "removing .git")
+		}
+	}
+
+	src := filepath.Join(dir, filepath.Join("/", source.Subdir))
+	copiedFiles, err := util.CopyDir(src, dest, util.FileContext{}, uid, gid, chmod, useDefaultChmod)
+	if err != nil {
+		return errors.Wrap(err, "copying git source")
+	}
+	a.snapshotFiles = append(a.snapshotFiles, copiedFiles...)
+	return nil
+}
@@ -18,11 +18,19 @@ package commands
 
 import (
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"testing"
 
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/GoogleContainerTools/kaniko/testutil"
@@ -174,3 +182,224 @@ func Test_AddCommand(t *testing.T) {
 		})
 	}
 }
+
+func Test_AddCommand_Checksum(t *testing.T) {
+	const content = "remote content\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte(content))
+	checksum := "sha256:" + hex.EncodeToString(sum[:])
+
+	kanikoDir := t.TempDir()
+	originalKanikoDir := kConfig.KanikoDir
+	kConfig.KanikoDir = kanikoDir
+	t.Cleanup(func() { kConfig.KanikoDir = originalKanikoDir })
+
+	tests := []struct {
+		name     string
+		checksum string
+		srcs     []string
+		env      []string
+		errMsg   string
+	}{
+		{
+			name:     "matching checksum",
+			checksum: checksum,
+			srcs:     []string{server.URL + "/file"},
+		},
+		{
+			name:     "checksum from env",
+			checksum: "$SUM",
+			srcs:     []string{server.URL + "/file"},
+			env:      []string{"SUM=" + checksum},
+		},
+		{
+			name:     "mismatching checksum",
+			checksum: "sha256:" + strings.Repeat("0", 64),
+			srcs:     []string{server.URL + "/file"},
+			errMsg:   "checksum mismatch: expected sha256:" + strings.Repeat("0", 64) + ", got " + checksum,
+		},
+		{
+			name:     "malformed checksum",
+			checksum: "sha256:abc",
+			srcs:     []string{server.URL + "/file"},
+			errMsg:   "malformed sha256 digest",
+		},
+		{
+			name:     "unsupported algorithm",
+			checksum: "md5:" + strings.Repeat("0", 32),
+			srcs:     []string{server.URL + "/file"},
+			errMsg:   "expected sha256, sha384 or sha512 digest",
+		},
+		{
+			name:     "local source",
+			checksum: checksum,
+			srcs:     []string{"text.txt"},
+			errMsg:   "checksum can't be specified for non-HTTP(S) sources",
+		},
+		{
+			name:     "multiple sources",
+			checksum: checksum,
+			srcs:     []string{server.URL + "/file", server.URL + "/other"},
+			errMsg:   "checksum can't be specified for multiple sources",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := setupAddTest(t)
+			dest := filepath.Join(tempDir, "dest") + "/"
+			c := AddCommand{
+				cmd: &instructions.AddCommand{
+					SourcesAndDest: instructions.SourcesAndDest{SourcePaths: test.srcs, DestPath: dest},
+					Checksum:       test.checksum,
+				},
+				fileContext: util.FileContext{Root: tempDir},
+			}
+			err := c.ExecuteCommand(&v1.Config{Env: test.env}, dockerfile.NewBuildArgs(nil))
+			if test.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+					t.Fatalf("expected error containing %q, got %v", test.errMsg, err)
+				}
+				// Nothing is written if the download doesn't match.
+				if _, err := os.Stat(filepath.Join(dest, "file")); !os.IsNotExist(err) {
+					t.Errorf("expected %s not to exist, got %v", filepath.Join(dest, "file"), err)
+				}
+				return
+			}
+			testutil.CheckNoError(t, err)
+			got, err := os.ReadFile(filepath.Join(dest, "file"))
+			testutil.CheckErrorAndDeepEqual(t, false, err, content, string(got))
+		})
+	}
+
+	// The verified download does not leave temporary files behind.
+	entries, err := os.ReadDir(kanikoDir)
+	testutil.CheckErrorAndDeepEqual(t, false, err, 0, len(entries))
+}
+
+func Test_AddCommand_ShouldCacheOutput(t *testing.T) {
+	withChecksum := &AddCommand{cmd: &instructions.AddCommand{Checksum: "sha256:abc"}, shdCache: true}
+	withoutChecksum := &AddCommand{cmd: &instructions.AddCommand{}, shdCache: true}
+	cacheDisabled := &AddCommand{cmd: &instructions.AddCommand{Checksum: "sha256:abc"}}
+
+	testutil.CheckDeepEqual(t, true, withChecksum.ShouldCacheOutput())
+	testutil.CheckDeepEqual(t, false, withoutChecksum.ShouldCacheOutput())
+	testutil.CheckDeepEqual(t, false, cacheDisabled.ShouldCacheOutput())
+}
+
+func Test_AddCommand_Git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required to serve file:// repositories")
+	}
+	git := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=kaniko", "-c", "user.email=kaniko@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	tmp := t.TempDir()
+	work := filepath.Join(tmp, "work")
+	git("init", "-b", "main", work)
+	if err := os.MkdirAll(filepath.Join(work, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, "docs", "index.md"), []byte("docs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("-C", work, "add", ".")
+	git("-C", work, "commit", "-m", "docs")
+	git("clone", "--bare", work, filepath.Join(tmp, "repo.git"))
+	commit := git("-C", work, "rev-parse", "HEAD")
+	url := "file://" + filepath.Join(tmp, "repo.git")
+
+	kanikoDir := t.TempDir()
+	originalKanikoDir := kConfig.KanikoDir
+	kConfig.KanikoDir = kanikoDir
+	t.Cleanup(func() { kConfig.KanikoDir = originalKanikoDir })
+
+	tests := []struct {
+		name       string
+		src        string
+		keepGitDir bool
+		checksum   string
+		wantFiles  []string
+		noFiles    []string
+		errMsg     string
+	}{
+		{
+			name:      "default branch",
+			src:       url,
+			wantFiles: []string{"docs/index.md"},
+			noFiles:   []string{".git"},
+		},
+		{
+			name:       "keep git dir",
+			src:        url + "#main",
+			keepGitDir: true,
+			wantFiles:  []string{"docs/index.md", ".git/HEAD"},
+		},
+		{
+			name:      "subdir at commit",
+			src:       url + "#" + commit + ":docs",
+			checksum:  commit[:12],
+			wantFiles: []string{"index.md"},
+			noFiles:   []string{"docs"},
+		},
+		{
+			name:     "checksum mismatch",
+			src:      url + "#main",
+			checksum: strings.Repeat("0", 40),
+			errMsg:   "checksum mismatch: expected commit " + strings.Repeat("0", 40) + ", got " + commit,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := setRootDir(t)
+			c := AddCommand{
+				cmd: &instructions.AddCommand{
+					SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{test.src}, DestPath: filepath.Join(root, "src")},
+					KeepGitDir:     test.keepGitDir,
+					Checksum:       test.checksum,
+				},
+				shdCache: true,
+			}
+			cfg := &v1.Config{}
+			buildArgs := dockerfile.NewBuildArgs(nil)
+
+			err := c.ExecuteCommand(cfg, buildArgs)
+			if test.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+					t.Fatalf("expected error containing %q, got %v", test.errMsg, err)
+				}
+				return
+			}
+			testutil.CheckNoError(t, err)
+			for _, f := range test.wantFiles {
+				path := filepath.Join(root, "src", f)
+				testutil.CheckDeepEqual(t, true, slices.Contains(c.FilesToSnapshot(), path))
+			}
+			for _, f := range test.noFiles {
+				if _, err := os.Stat(filepath.Join(root, "src", f)); !os.IsNotExist(err) {
+					t.Errorf("expected %s not to exist, got %v", f, err)
+				}
+			}
+
+			// The layer is cached by the commit the ref resolved to.
+			testutil.CheckDeepEqual(t, true, c.ShouldCacheOutput())
+			checksum, err := c.Checksum(nil)
+			testutil.CheckErrorAndDeepEqual(t, false, err, commit, checksum)
+			files, err := c.FilesUsedFromContext(cfg, buildArgs)
+			testutil.CheckErrorAndDeepEqual(t, false, err, 0, len(files))
+		})
+	}
+
+	// Clones are removed once they are copied.
+	entries, err := os.ReadDir(kanikoDir)
+	testutil.CheckErrorAndDeepEqual(t, false, err, 0, len(entries))
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package commands
 
 import (
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -27,6 +28,16 @@ import (
 
 type CurrentCacheKey func() (string, error)
 
+// RootfsCommand is implemented by the commands which can run against another
+// view of the root directory, like RUN does against an overlay mount of it
+// with --snapshot-mode=overlay.
+type RootfsCommand interface {
+	DockerCommand
+	// SetRootfs makes the command run in rootfs, or in the root directory if
+	// it is empty.
+	SetRootfs(rootfs string)
+}
+
 type DockerCommand interface {
 	// ExecuteCommand is responsible for:
 	// 	1. Making required changes to the filesystem (ex. copying files for ADD/COPY or setting ENV variables)
@@ -64,15 +75,16 @@ type DockerCommand interface {
 	IsArgsEnvsRequiredInCache() bool
 }
 
-func GetCommand(cmd instructions.Command, fileContext util.FileContext, useNewRun bool, cacheCopy bool, cacheRun bool) (DockerCommand, error) {
+func GetCommand(cmd instructions.Command, fileContext util.FileContext, opts *config.KanikoOptions, stageNameToIdx map[string]string) (DockerCommand, error) {
 	switch c := cmd.(type) {
 	case *instructions.RunCommand:
-		if useNewRun {
-			return &RunMarkerCommand{cmd: c, shdCache: cacheRun}, nil
+		mountCtx := newRunMountContext(opts, fileContext, stageNameToIdx)
+		if opts.RunV2 {
+			return &RunMarkerCommand{cmd: c, mountCtx: mountCtx, shdCache: opts.CacheRunLayers}, nil
 		}
-		return &RunCommand{cmd: c, shdCache: cacheRun}, nil
+		return &RunCommand{cmd: c, mountCtx: mountCtx, shdCache: opts.CacheRunLayers}, nil
 	case *instructions.CopyCommand:
-		return &CopyCommand{cmd: c, fileContext: fileContext, shdCache: cacheCopy}, nil
+		return &CopyCommand{cmd: c, fileContext: fileContext, shdCache: opts.CacheCopyLayers}, nil
 	case *instructions.ExposeCommand:
 		return &ExposeCommand{cmd: c}, nil
 	case *instructions.EnvCommand:
@@ -80,7 +92,7 @@ func GetCommand(cmd instructions.Command, fileContext util.FileContext, useNewRu
 	case *instructions.WorkdirCommand:
 		return &WorkdirCommand{cmd: c}, nil
 	case *instructions.AddCommand:
-		return &AddCommand{cmd: c, fileContext: fileContext}, nil
+		return &AddCommand{cmd: c, fileContext: fileContext, shdCache: opts.CacheCopyLayers}, nil
 	case *instructions.CmdCommand:
 		return &CmdCommand{cmd: c}, nil
 	case *instructions.EntrypointCommand:
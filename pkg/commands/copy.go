@@ -46,12 +46,15 @@ type CopyCommand struct {
 }
 
 func (c *CopyCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.BuildArgs) error {
-	// Resolve from
-	if c.cmd.From != "" {
-		c.fileContext = util.FileContext{Root: filepath.Join(kConfig.KanikoDir, c.cmd.From)}
+	replacementEnvs := buildArgs.ReplacementEnvs(config.Env)
+
+	// Resolve from and exclusions
+	fileContext, err := copyFileContext(c.cmd, c.fileContext, replacementEnvs)
+	if err != nil {
+		return err
 	}
+	c.fileContext = fileContext
 
-	replacementEnvs := buildArgs.ReplacementEnvs(config.Env)
 	uid, gid, err := getUserGroup(c.cmd.Chown, replacementEnvs)
 	logrus.Debugf("found uid %v and gid %v for chown string %v", uid, gid, c.cmd.Chown)
 	if err != nil {
@@ -59,7 +62,7 @@ func (c *CopyCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.Bu
 	}
 
 	// sources from the Copy command are resolved with wildcards {*?[}
-	srcs, dest, err := util.ResolveEnvAndWildcards(c.cmd.SourcesAndDest, c.fileContext, replacementEnvs)
+	srcs, dest, err := resolveCopySources(c.cmd, c.fileContext, replacementEnvs)
 	if err != nil {
 		return errors.Wrap(err, "resolving src")
 	}
@@ -69,6 +72,19 @@ func (c *CopyCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.Bu
 		return errors.Wrap(err, "getting permissions from chmod")
 	}
 
+	cwd := config.WorkingDir
+	if cwd == "" {
+		cwd = kConfig.RootDir
+	}
+
+	if len(c.cmd.SourceContents) > 0 {
+		files, err := copyHeredocs(c.cmd, dest, cwd, len(srcs), replacementEnvs, uid, gid, chmod, useDefaultChmod)
+		if err != nil {
+			return err
+		}
+		c.snapshotFiles = append(c.snapshotFiles, files...)
+	}
+
 	// For each source, iterate through and copy it over
 	for _, src := range srcs {
 		fullPath := filepath.Join(c.fileContext.Root, src)
@@ -80,12 +96,13 @@ func (c *CopyCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.Bu
 		if fi.IsDir() && !strings.HasSuffix(fullPath, string(os.PathSeparator)) {
 			fullPath += "/"
 		}
-		cwd := config.WorkingDir
-		if cwd == "" {
-			cwd = kConfig.RootDir
+
+		srcDest := dest
+		if c.cmd.Parents {
+			srcDest = copyParentsDest(src, dest, fi.IsDir())
 		}
 
-		destPath, err := util.DestinationFilepath(fullPath, dest, cwd)
+		destPath, err := util.DestinationFilepath(fullPath, srcDest, cwd)
 		if err != nil {
 			return errors.Wrap(err, "find destination path")
 		}
@@ -135,7 +152,30 @@ func (c *CopyCommand) FilesToSnapshot() []string {
 
 // String returns some information about the command for the image config
 func (c *CopyCommand) String() string {
-	return c.cmd.String()
+	return copyString(c.cmd)
+}
+
+// IsArgsEnvsRequiredInCache returns true if the content of a heredoc depends
+// on the build args and environment.
+func (c *CopyCommand) IsArgsEnvsRequiredInCache() bool {
+	return copyHeredocsExpand(c.cmd)
+}
+
+// ExcludePatterns returns the resolved patterns of --exclude.
+func (c *CopyCommand) ExcludePatterns(replacementEnvs []string) ([]string, error) {
+	return copyExcludePatterns(c.cmd, replacementEnvs)
+}
+
+// KeepsParents returns true if the sources are copied with their parent
+// directories.
+func (c *CopyCommand) KeepsParents() bool {
+	return c.cmd.Parents
+}
+
+// Linked returns true if the copied files form a layer independent of the
+// previous ones.
+func (c *CopyCommand) Linked() bool {
+	return c.cmd.Link
 }
 
 func (c *CopyCommand) FilesUsedFromContext(config *v1.Config, buildArgs *dockerfile.BuildArgs) ([]string, error) {
@@ -226,7 +266,23 @@ func (cr *CachingCopyCommand) String() string {
 	if cr.cmd == nil {
 		return "nil command"
 	}
-	return cr.cmd.String()
+	return copyString(cr.cmd)
+}
+
+func (cr *CachingCopyCommand) ExcludePatterns(replacementEnvs []string) ([]string, error) {
+	return copyExcludePatterns(cr.cmd, replacementEnvs)
+}
+
+func (cr *CachingCopyCommand) KeepsParents() bool {
+	return cr.cmd != nil && cr.cmd.Parents
+}
+
+func (cr *CachingCopyCommand) Linked() bool {
+	return cr.cmd != nil && cr.cmd.Link
+}
+
+func (cr *CachingCopyCommand) IsArgsEnvsRequiredInCache() bool {
+	return cr.cmd != nil && copyHeredocsExpand(cr.cmd)
 }
 
 func (cr *CachingCopyCommand) From() string {
@@ -276,15 +332,14 @@ func copyCmdFilesUsedFromContext(
 	config *v1.Config, buildArgs *dockerfile.BuildArgs, cmd *instructions.CopyCommand,
 	fileContext util.FileContext,
 ) ([]string, error) {
-	if cmd.From != "" {
-		fileContext = util.FileContext{Root: filepath.Join(kConfig.KanikoDir, cmd.From)}
-	}
-
 	replacementEnvs := buildArgs.ReplacementEnvs(config.Env)
 
-	srcs, _, err := util.ResolveEnvAndWildcards(
-		cmd.SourcesAndDest, fileContext, replacementEnvs,
-	)
+	fileContext, err := copyFileContext(cmd, fileContext, replacementEnvs)
+	if err != nil {
+		return nil, err
+	}
+
+	srcs, _, err := resolveCopySources(cmd, fileContext, replacementEnvs)
 	if err != nil {
 		return nil, err
 	}
@@ -300,6 +355,65 @@ func copyCmdFilesUsedFromContext(
 	return files, nil
 }
 
+// copyFileContext returns the file context a COPY command copies from: the
+// stage of --from or the build context, without the files matching --exclude.
+func copyFileContext(cmd *instructions.CopyCommand, fileContext util.FileContext, envs []string) (util.FileContext, error) {
+	if cmd.From != "" {
+		root, ok := fileContext.NamedContexts[cmd.From]
+		if !ok {
+			root = filepath.Join(kConfig.KanikoDir, cmd.From)
+		}
+		fileContext = util.FileContext{Root: root, NamedContexts: fileContext.NamedContexts}
+	}
+	excludes, err := copyExcludePatterns(cmd, envs)
+	if err != nil {
+		return util.FileContext{}, err
+	}
+	return fileContext.WithExcludes(excludes), nil
+}
+
+func copyExcludePatterns(cmd *instructions.CopyCommand, envs []string) ([]string, error) {
+	excludes, err := util.ResolveEnvironmentReplacementList(cmd.ExcludePatterns, envs, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving exclude patterns")
+	}
+	return excludes, nil
+}
+
+// resolveCopySources resolves the sources and destination of a COPY command.
+// Heredocs are not part of the sources, so a COPY of only heredocs has none.
+func resolveCopySources(cmd *instructions.CopyCommand, fileContext util.FileContext, envs []string) ([]string, string, error) {
+	if len(cmd.SourcePaths) == 0 && len(cmd.SourceContents) > 0 {
+		dest, err := util.ResolveEnvironmentReplacement(cmd.DestPath, envs, true)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to resolve environment for dest path")
+		}
+		return nil, dest, nil
+	}
+	if cmd.Parents {
+		return resolveCopyParentsSources(cmd, fileContext, envs)
+	}
+	return util.ResolveEnvAndWildcards(cmd.SourcesAndDest, fileContext, envs)
+}
+
+// ExcludePatternsCommand is a command which excludes the files matching its
+// patterns on top of the file context, like COPY --exclude.
+type ExcludePatternsCommand interface {
+	ExcludePatterns(replacementEnvs []string) ([]string, error)
+}
+
+// ParentsCommand is a command which copies its sources with their parent
+// directories, like COPY --parents.
+type ParentsCommand interface {
+	KeepsParents() bool
+}
+
+// LinkCommand is a command whose layer doesn't depend on the previous ones,
+// like COPY --link.
+type LinkCommand interface {
+	Linked() bool
+}
+
 // AbstractCopyCommand can either be a CopyCommand or a CachingCopyCommand.
 type AbstractCopyCommand interface {
 	From() string
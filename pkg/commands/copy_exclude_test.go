@@ -0,0 +1,61 @@
+//go:build dfexcludepatterns
+// +build dfexcludepatterns
+
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestCopyCommand_ExecuteCommand_ParsedExclude(t *testing.T) {
+	contextDir := t.TempDir()
+	for _, path := range []string{"main.go", "README.md", "tests/main_test.go", "docs/guide.md"} {
+		path = filepath.Join(contextDir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(path), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := setRootDir(t)
+
+	copyCmd := parseCopyCommand(t, "COPY --exclude=*.md --exclude=tests/ . "+filepath.Join(root, "app"))
+	testutil.CheckDeepEqual(t, []string{"*.md", "tests/"}, copyCmd.ExcludePatterns)
+
+	cmd := &CopyCommand{cmd: copyCmd, fileContext: util.FileContext{Root: contextDir}}
+	testutil.CheckNoError(t, cmd.ExecuteCommand(&v1.Config{}, dockerfile.NewBuildArgs(nil)))
+
+	for path, exists := range map[string]bool{
+		"main.go":       true,
+		"docs/guide.md": true,
+		"README.md":     false,
+		"tests":         false,
+	} {
+		_, err := os.Stat(filepath.Join(root, "app", path))
+		testutil.CheckDeepEqual(t, exists, err == nil)
+	}
+}
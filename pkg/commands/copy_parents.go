@@ -0,0 +1,90 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/pkg/errors"
+)
+
+// parentsPivot separates the parent directories of a COPY --parents source
+// which are left out at the destination from the ones which are kept.
+const parentsPivot = "/./"
+
+// resolveCopyParentsSources resolves the sources of COPY --parents like
+// util.ResolveEnvAndWildcards does. The destination is always a directory,
+// and each resolved source keeps the /./ pivot of the source it matched, so
+// that copyParentsDest knows which of its parents to keep.
+func resolveCopyParentsSources(cmd *instructions.CopyCommand, fileContext util.FileContext, envs []string) ([]string, string, error) {
+	destPath := cmd.DestPath
+	if !strings.HasSuffix(destPath, "/") {
+		destPath += "/"
+	}
+
+	var srcs []string
+	var dest string
+	for _, src := range cmd.SourcePaths {
+		resolved, err := util.ResolveEnvironmentReplacement(src, envs, false)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to resolve environment")
+		}
+		depth := 0
+		if pivot, _, ok := strings.Cut(resolved, parentsPivot); ok {
+			depth = len(splitPath(pivot))
+		}
+
+		sd := instructions.SourcesAndDest{SourcePaths: []string{src}, DestPath: destPath}
+		matches, resolvedDest, err := util.ResolveEnvAndWildcards(sd, fileContext, envs)
+		if err != nil {
+			return nil, "", err
+		}
+		dest = resolvedDest
+		for _, match := range matches {
+			if parts := splitPath(match); depth > 0 && len(parts) > depth {
+				match = filepath.Join(parts[:depth]...) + parentsPivot + filepath.Join(parts[depth:]...)
+			}
+			srcs = append(srcs, match)
+		}
+	}
+	return srcs, dest, nil
+}
+
+// copyParentsDest returns the directory a source resolved by
+// resolveCopyParentsSources is copied to: dest followed by the parents of the
+// source after its pivot, or all of them if it has none.
+func copyParentsDest(src, dest string, isDir bool) string {
+	rel := src
+	if _, after, ok := strings.Cut(src, parentsPivot); ok {
+		rel = after
+	}
+	if !isDir {
+		rel = filepath.Dir(rel)
+	}
+	return filepath.Join(dest, filepath.Join("/", rel)) + "/"
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(filepath.Clean(path), "/")
+	if path == "" || path == "." {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
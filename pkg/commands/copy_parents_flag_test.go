@@ -0,0 +1,47 @@
+//go:build dfparents
+// +build dfparents
+
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func TestCopyCommand_ExecuteCommand_ParsedParents(t *testing.T) {
+	contextDir := setupParentsContext(t)
+	root := setRootDir(t)
+
+	copyCmd := parseCopyCommand(t, "COPY --parents ./services/./*/package.json "+filepath.Join(root, "app"))
+	testutil.CheckDeepEqual(t, true, copyCmd.Parents)
+
+	cmd := &CopyCommand{cmd: copyCmd, fileContext: util.FileContext{Root: contextDir}}
+	testutil.CheckNoError(t, cmd.ExecuteCommand(&v1.Config{}, dockerfile.NewBuildArgs(nil)))
+
+	for _, path := range []string{"app/api/package.json", "app/web/package.json"} {
+		_, err := os.Stat(filepath.Join(root, path))
+		testutil.CheckNoError(t, err)
+	}
+}
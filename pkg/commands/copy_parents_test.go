@@ -0,0 +1,167 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+func setupParentsContext(t *testing.T) string {
+	t.Helper()
+	contextDir := t.TempDir()
+	for _, path := range []string{
+		"services/api/package.json",
+		"services/api/index.js",
+		"services/web/package.json",
+		"services/web/src/app.js",
+		"package.json",
+	} {
+		path = filepath.Join(contextDir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(path), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return contextDir
+}
+
+func TestCopyCommand_ExecuteCommand_Parents(t *testing.T) {
+	tests := []struct {
+		name      string
+		srcs      []string
+		dest      string
+		workdir   string
+		wantFiles []string
+	}{
+		{
+			name:      "wildcard",
+			srcs:      []string{"./services/*/package.json"},
+			dest:      "/app/",
+			wantFiles: []string{"app/services/api/package.json", "app/services/web/package.json"},
+		},
+		{
+			name:      "pivot",
+			srcs:      []string{"./services/./*/package.json"},
+			dest:      "/app",
+			wantFiles: []string{"app/api/package.json", "app/web/package.json"},
+		},
+		{
+			name:      "directory",
+			srcs:      []string{"services/web"},
+			dest:      "/app",
+			wantFiles: []string{"app/services/web/package.json", "app/services/web/src/app.js"},
+		},
+		{
+			name:      "directory after pivot",
+			srcs:      []string{"services/./web"},
+			dest:      "/app",
+			wantFiles: []string{"app/web/package.json", "app/web/src/app.js"},
+		},
+		{
+			name:      "multiple sources relative to the workdir",
+			srcs:      []string{"package.json", "services/api/package.json"},
+			dest:      ".",
+			workdir:   "/app",
+			wantFiles: []string{"app/package.json", "app/services/api/package.json"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contextDir := setupParentsContext(t)
+			root := setRootDir(t)
+			cfg := &v1.Config{}
+			if test.workdir != "" {
+				cfg.WorkingDir = filepath.Join(root, test.workdir)
+			}
+			dest := test.dest
+			if filepath.IsAbs(dest) {
+				dest = filepath.Join(root, dest)
+				if strings.HasSuffix(test.dest, "/") {
+					dest += "/"
+				}
+			}
+			cmd := &CopyCommand{
+				cmd: &instructions.CopyCommand{
+					SourcesAndDest: instructions.SourcesAndDest{SourcePaths: test.srcs, DestPath: dest},
+					Parents:        true,
+				},
+				fileContext: util.FileContext{Root: contextDir},
+			}
+			testutil.CheckNoError(t, cmd.ExecuteCommand(cfg, dockerfile.NewBuildArgs(nil)))
+
+			var got []string
+			err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+				if err == nil && !fi.IsDir() {
+					rel, _ := filepath.Rel(root, path)
+					got = append(got, rel)
+				}
+				return err
+			})
+			sort.Strings(got)
+			testutil.CheckErrorAndDeepEqual(t, false, err, test.wantFiles, got)
+		})
+	}
+}
+
+func TestCopyCommand_FilesUsedFromContext_Parents(t *testing.T) {
+	contextDir := setupParentsContext(t)
+	cmd := &CopyCommand{
+		cmd: &instructions.CopyCommand{
+			SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"services/./*/package.json"}, DestPath: "/app"},
+			Parents:        true,
+		},
+		fileContext: util.FileContext{Root: contextDir},
+	}
+	files, err := cmd.FilesUsedFromContext(&v1.Config{}, dockerfile.NewBuildArgs(nil))
+	testutil.CheckErrorAndDeepEqual(t, false, err, []string{
+		filepath.Join(contextDir, "services/api/package.json"),
+		filepath.Join(contextDir, "services/web/package.json"),
+	}, files)
+	testutil.CheckDeepEqual(t, true, cmd.KeepsParents())
+	testutil.CheckDeepEqual(t, true, cmd.CacheCommand(nil).(ParentsCommand).KeepsParents())
+}
+
+func Test_copyParentsDest(t *testing.T) {
+	tests := []struct {
+		src   string
+		isDir bool
+		want  string
+	}{
+		{src: "services/api/package.json", want: "/app/services/api/"},
+		{src: "services/./api/package.json", want: "/app/api/"},
+		{src: "services/api", isDir: true, want: "/app/services/api/"},
+		{src: "services/./api", isDir: true, want: "/app/api/"},
+		{src: "package.json", want: "/app/"},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			testutil.CheckDeepEqual(t, test.want, copyParentsDest(test.src, "/app/", test.isDir))
+		})
+	}
+}
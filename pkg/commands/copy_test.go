@@ -1147,4 +1147,59 @@ func TestCopyCommand_ExecuteCommand_Extended(t *testing.T) {
 		}
 		testutil.CheckDeepEqual(t, "../bam.txt", linkName)
 	})
+
+	t.Run("copy dir to another dir - with excluded patterns", func(t *testing.T) {
+		testDir, srcDir := setupDirs(t)
+
+		cmd := CopyCommand{
+			cmd: &instructions.CopyCommand{
+				SourcesAndDest:  instructions.SourcesAndDest{SourcePaths: []string{srcDir}, DestPath: "dest"},
+				ExcludePatterns: []string{"$SRC/*.txt", "!$SRC/dam.txt"},
+			},
+			fileContext: util.FileContext{Root: testDir},
+		}
+		cfg := &v1.Config{
+			Env:        []string{"SRC=" + srcDir},
+			WorkingDir: testDir,
+		}
+
+		err := cmd.ExecuteCommand(cfg, dockerfile.NewBuildArgs([]string{}))
+		testutil.CheckNoError(t, err)
+
+		actual, err := readDirectory(filepath.Join(testDir, "dest"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, f := range actual {
+			names = append(names, f.Name())
+		}
+		testutil.CheckDeepEqual(t, []string{"dam.txt", "sym.link"}, names)
+
+		files, err := cmd.FilesUsedFromContext(cfg, dockerfile.NewBuildArgs([]string{}))
+		testutil.CheckErrorAndDeepEqual(t, false, err, []string{filepath.Join(testDir, srcDir)}, files)
+	})
+
+	t.Run("copy from a named build context", func(t *testing.T) {
+		namedContext, srcDir := setupDirs(t)
+		testDir := t.TempDir()
+
+		cmd := CopyCommand{
+			cmd: &instructions.CopyCommand{
+				SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{srcDir + "/bam.txt"}, DestPath: "dest/"},
+				From:           "deps",
+			},
+			fileContext: util.FileContext{Root: testDir, NamedContexts: map[string]string{"deps": namedContext}},
+		}
+		cfg := &v1.Config{WorkingDir: testDir}
+
+		err := cmd.ExecuteCommand(cfg, dockerfile.NewBuildArgs([]string{}))
+		testutil.CheckNoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(testDir, "dest", "bam.txt"))
+		testutil.CheckErrorAndDeepEqual(t, false, err, "meow", string(content))
+
+		files, err := cmd.FilesUsedFromContext(cfg, dockerfile.NewBuildArgs([]string{}))
+		testutil.CheckErrorAndDeepEqual(t, false, err, []string{filepath.Join(namedContext, srcDir, "bam.txt")}, files)
+	})
 }
@@ -0,0 +1,163 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/pkg/errors"
+)
+
+const defaultHeredocMode = 0o644
+
+// runString returns the RUN instruction followed by the content of its
+// heredocs. cmd.String() only contains the first line of the instruction,
+// so without the content a changed heredoc would still hit the cache.
+func runString(cmd *instructions.RunCommand) string {
+	var b strings.Builder
+	b.WriteString(cmd.String())
+	for _, f := range cmd.Files {
+		b.WriteString("\n" + f.Data + f.Name)
+	}
+	return b.String()
+}
+
+// copyString returns the COPY instruction followed by the content of its
+// heredocs, for the same reason as runString.
+func copyString(cmd *instructions.CopyCommand) string {
+	var b strings.Builder
+	b.WriteString(cmd.String())
+	for _, c := range cmd.SourceContents {
+		b.WriteString("\n" + c.Data + c.Path)
+	}
+	return b.String()
+}
+
+// copyHeredocsExpand returns true if the content of any heredoc of the COPY
+// instruction has to be expanded with the build args and environment.
+func copyHeredocsExpand(cmd *instructions.CopyCommand) bool {
+	for _, c := range cmd.SourceContents {
+		if c.Expand {
+			return true
+		}
+	}
+	return false
+}
+
+// heredocShell returns the script passed to the shell for a RUN instruction
+// with heredocs. A single heredoc is the script itself, otherwise the
+// heredocs are appended to the command line for the shell to handle.
+func heredocShell(cmd *instructions.RunCommand) string {
+	if len(cmd.Files) == 1 && parser.MustParseHeredoc(cmd.CmdLine[0]) != nil {
+		return heredocData(cmd.Files[0])
+	}
+	script := strings.Join(cmd.CmdLine, " ")
+	for _, f := range cmd.Files {
+		script += "\n" + f.Data + f.Name
+	}
+	return script
+}
+
+// heredocScript writes a single heredoc starting with a shebang to an
+// executable file, so that it is run by its interpreter instead of the shell.
+// It returns an empty path if the RUN instruction is not such a script.
+func heredocScript(cmd *instructions.RunCommand) (string, func(), error) {
+	if len(cmd.Files) != 1 || parser.MustParseHeredoc(cmd.CmdLine[0]) == nil {
+		return "", func() {}, nil
+	}
+	data := heredocData(cmd.Files[0])
+	if !strings.HasPrefix(data, "#!") {
+		return "", func() {}, nil
+	}
+
+	base := filepath.Join(kConfig.KanikoDir, "heredocs")
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		return "", func() {}, errors.Wrap(err, "creating heredoc dir")
+	}
+	dir, err := os.MkdirTemp(base, "run-")
+	if err != nil {
+		return "", func() {}, errors.Wrap(err, "creating heredoc dir")
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	// The command may run as another user, who still has to read the script.
+	if err := os.Chmod(dir, 0o755); err != nil {
+		cleanup()
+		return "", func() {}, errors.Wrap(err, "setting heredoc dir permissions")
+	}
+	path := filepath.Join(dir, cmd.Files[0].Name)
+	if err := os.WriteFile(path, []byte(data), 0o755); err != nil {
+		cleanup()
+		return "", func() {}, errors.Wrap(err, "writing heredoc script")
+	}
+	return path, cleanup, nil
+}
+
+func heredocData(f instructions.ShellInlineFile) string {
+	if f.Chomp {
+		return parser.ChompHeredocContent(f.Data)
+	}
+	return f.Data
+}
+
+// copyHeredocs creates the files of the heredocs of a COPY instruction at
+// dest and returns their paths. The content of unquoted heredocs is expanded
+// like BuildKit does, without removing quotes.
+func copyHeredocs(cmd *instructions.CopyCommand, dest, cwd string, srcCount int, envs []string,
+	uid, gid int64, chmod fs.FileMode, useDefaultChmod bool) ([]string, error) {
+	if len(cmd.SourceContents)+srcCount > 1 && !util.IsDestDir(dest) {
+		return nil, errors.New("when specifying multiple sources in a COPY command, destination must be a directory and end in '/'")
+	}
+	if useDefaultChmod {
+		chmod = defaultHeredocMode
+	}
+	lex := shell.NewLex(parser.DefaultEscapeToken)
+	lex.SkipProcessQuotes = true
+
+	var files []string
+	for _, c := range cmd.SourceContents {
+		data := c.Data
+		if c.Expand {
+			var err error
+			data, _, err = lex.ProcessWord(data, shell.EnvsFromSlice(envs))
+			if err != nil {
+				return nil, errors.Wrapf(err, "expanding heredoc %s", c.Path)
+			}
+		}
+
+		destPath, err := util.DestinationFilepath(c.Path, dest, cwd)
+		if err != nil {
+			return nil, errors.Wrap(err, "find destination path")
+		}
+		destPath, err = resolveIfSymlink(destPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "resolving dest symlink")
+		}
+		if err := util.CreateFile(destPath, strings.NewReader(data), chmod, uint32(uid), uint32(gid)); err != nil {
+			return nil, errors.Wrapf(err, "creating heredoc %s", c.Path)
+		}
+		files = append(files, destPath)
+	}
+	return files, nil
+}
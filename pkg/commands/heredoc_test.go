@@ -0,0 +1,227 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+func Test_heredocShell(t *testing.T) {
+	tests := []struct {
+		name string
+		run  string
+		want string
+	}{
+		{
+			name: "single heredoc",
+			run:  "RUN <<EOF\necho a\necho b\nEOF",
+			want: "echo a\necho b\n",
+		},
+		{
+			name: "chomped heredoc",
+			run:  "RUN <<-EOF\n\techo a\nEOF",
+			want: "echo a\n",
+		},
+		{
+			name: "heredoc as input of a command",
+			run:  "RUN cat <<A > /a && cat <<B > /b\nfoo\nA\nbar\nB",
+			want: "cat <<A > /a && cat <<B > /b\nfoo\nA\nbar\nB",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testutil.CheckDeepEqual(t, test.want, heredocShell(parseRunCommand(t, test.run)))
+		})
+	}
+}
+
+func Test_RunCommand_ExecuteCommand_Heredoc(t *testing.T) {
+	kanikoDir := t.TempDir()
+	originalKanikoDir := kConfig.KanikoDir
+	kConfig.KanikoDir = kanikoDir
+	t.Cleanup(func() { kConfig.KanikoDir = originalKanikoDir })
+
+	tests := []struct {
+		name string
+		run  string
+		want string
+	}{
+		{
+			name: "shell script",
+			run:  "RUN <<EOF\nprintf one > $OUT\nprintf ' two' >> $OUT\nEOF",
+			want: "one two",
+		},
+		{
+			name: "shebang script",
+			run:  "RUN <<EOF\n#!/bin/sh -e\nprintf '%s' \"$0\" | grep -q heredocs/\nprintf shebang > $OUT\nEOF",
+			want: "shebang",
+		},
+		{
+			name: "heredoc as input of a command",
+			run:  "RUN cat <<EOF > $OUT\nfile content\nEOF",
+			want: "file content\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			cfg := &v1.Config{Env: []string{"PATH=" + os.Getenv("PATH"), "OUT=" + out}}
+			cmd := &RunCommand{cmd: parseRunCommand(t, test.run)}
+			testutil.CheckNoError(t, cmd.ExecuteCommand(cfg, dockerfile.NewBuildArgs(nil)))
+
+			content, err := os.ReadFile(out)
+			testutil.CheckErrorAndDeepEqual(t, false, err, test.want, string(content))
+		})
+	}
+
+	// The shebang script is removed once it ran.
+	entries, err := os.ReadDir(filepath.Join(kanikoDir, "heredocs"))
+	testutil.CheckErrorAndDeepEqual(t, false, err, 0, len(entries))
+}
+
+func Test_RunCommand_String_Heredoc(t *testing.T) {
+	cmd1 := &RunCommand{cmd: parseRunCommand(t, "RUN <<EOF\necho a\nEOF")}
+	cmd2 := &RunCommand{cmd: parseRunCommand(t, "RUN <<EOF\necho b\nEOF")}
+	testutil.CheckDeepEqual(t, "RUN <<EOF\necho a\nEOF", cmd1.String())
+	if cmd1.String() == cmd2.String() {
+		t.Errorf("expected heredocs with different content to differ, got %q", cmd1.String())
+	}
+	testutil.CheckDeepEqual(t, cmd1.String(), cmd1.CacheCommand(nil).String())
+}
+
+func parseCopyCommand(t *testing.T, line string) *instructions.CopyCommand {
+	t.Helper()
+	cmds, err := dockerfile.ParseCommands([]string{line})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmds[0].(*instructions.CopyCommand)
+}
+
+func Test_CopyCommand_ExecuteCommand_Heredoc(t *testing.T) {
+	tests := []struct {
+		name      string
+		copy      string
+		env       []string
+		workdir   string
+		wantFiles map[string]string
+		wantMode  os.FileMode
+		shdErr    bool
+	}{
+		{
+			name:      "file",
+			copy:      "COPY <<EOF /etc/config\nkey=value\nEOF",
+			wantFiles: map[string]string{"/etc/config": "key=value\n"},
+			wantMode:  0o644,
+		},
+		{
+			name:      "expanded",
+			copy:      "COPY <<EOF /etc/config\nkey=$VALUE \"quoted\"\nEOF",
+			env:       []string{"VALUE=value"},
+			wantFiles: map[string]string{"/etc/config": "key=value \"quoted\"\n"},
+			wantMode:  0o644,
+		},
+		{
+			name:      "quoted delimiter is not expanded",
+			copy:      "COPY <<'EOF' /etc/config\nkey=$VALUE\nEOF",
+			env:       []string{"VALUE=value"},
+			wantFiles: map[string]string{"/etc/config": "key=$VALUE\n"},
+			wantMode:  0o644,
+		},
+		{
+			name:      "chmod",
+			copy:      "COPY --chmod=755 <<EOF /usr/local/bin/hello\n#!/bin/sh\necho hello\nEOF",
+			wantFiles: map[string]string{"/usr/local/bin/hello": "#!/bin/sh\necho hello\n"},
+			wantMode:  0o755,
+		},
+		{
+			name:      "multiple heredocs into a directory relative to the workdir",
+			copy:      "COPY <<A <<B conf/\na\nA\nb\nB",
+			workdir:   "/app",
+			wantFiles: map[string]string{"/app/conf/A": "a\n", "/app/conf/B": "b\n"},
+			wantMode:  0o644,
+		},
+		{
+			name:   "multiple heredocs into a file",
+			copy:   "COPY <<A <<B /conf\na\nA\nb\nB",
+			shdErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := setRootDir(t)
+			cfg := &v1.Config{Env: test.env}
+			if test.workdir != "" {
+				cfg.WorkingDir = filepath.Join(root, test.workdir)
+			}
+			// Destinations are absolute, so resolve them below the test root.
+			copyCmd := parseCopyCommand(t, test.copy)
+			if filepath.IsAbs(copyCmd.DestPath) {
+				copyCmd.DestPath = filepath.Join(root, copyCmd.DestPath)
+			}
+			cmd := &CopyCommand{cmd: copyCmd, fileContext: util.FileContext{Root: t.TempDir()}}
+
+			err := cmd.ExecuteCommand(cfg, dockerfile.NewBuildArgs(nil))
+			testutil.CheckError(t, test.shdErr, err)
+			if test.shdErr {
+				return
+			}
+
+			var wantSnapshot []string
+			for path, want := range test.wantFiles {
+				path = filepath.Join(root, path)
+				wantSnapshot = append(wantSnapshot, path)
+				content, err := os.ReadFile(path)
+				testutil.CheckErrorAndDeepEqual(t, false, err, want, string(content))
+				fi, err := os.Stat(path)
+				testutil.CheckErrorAndDeepEqual(t, false, err, test.wantMode, fi.Mode().Perm())
+			}
+			testutil.CheckDeepEqual(t, len(wantSnapshot), len(cmd.FilesToSnapshot()))
+			for _, path := range wantSnapshot {
+				testutil.CheckDeepEqual(t, true, slices.Contains(cmd.FilesToSnapshot(), path))
+			}
+
+			files, err := cmd.FilesUsedFromContext(cfg, dockerfile.NewBuildArgs(nil))
+			testutil.CheckErrorAndDeepEqual(t, false, err, 0, len(files))
+		})
+	}
+}
+
+func Test_CopyCommand_Heredoc_CacheKey(t *testing.T) {
+	unquoted := &CopyCommand{cmd: parseCopyCommand(t, "COPY <<EOF /etc/config\n$VALUE\nEOF")}
+	quoted := &CopyCommand{cmd: parseCopyCommand(t, "COPY <<'EOF' /etc/config\n$VALUE\nEOF")}
+	plain := &CopyCommand{cmd: parseCopyCommand(t, "COPY foo /etc/config")}
+
+	testutil.CheckDeepEqual(t, "COPY <<EOF /etc/config\n$VALUE\nEOF", unquoted.String())
+	testutil.CheckDeepEqual(t, true, unquoted.IsArgsEnvsRequiredInCache())
+	testutil.CheckDeepEqual(t, false, quoted.IsArgsEnvsRequiredInCache())
+	testutil.CheckDeepEqual(t, false, plain.IsArgsEnvsRequiredInCache())
+
+	cached := unquoted.CacheCommand(nil)
+	testutil.CheckDeepEqual(t, unquoted.String(), cached.String())
+	testutil.CheckDeepEqual(t, true, cached.IsArgsEnvsRequiredInCache())
+}
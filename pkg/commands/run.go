@@ -36,6 +36,7 @@ import (
 type RunCommand struct {
 	BaseCommand
 	cmd      *instructions.RunCommand
+	mountCtx runMountContext
 	shdCache bool
 }
 
@@ -49,21 +50,29 @@ func (r *RunCommand) IsArgsEnvsRequiredInCache() bool {
 }
 
 func (r *RunCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.BuildArgs) error {
-	return runCommandInExec(config, buildArgs, r.cmd)
+	return runCommandInExec(config, buildArgs, r.cmd, r.mountCtx)
 }
 
-func runCommandInExec(config *v1.Config, buildArgs *dockerfile.BuildArgs, cmdRun *instructions.RunCommand) error {
-	var newCommand []string
-	if cmdRun.PrependShell {
-		// This is the default shell on Linux
-		var shell []string
-		if len(config.Shell) > 0 {
-			shell = config.Shell
-		} else {
-			shell = append(shell, "/bin/sh", "-c")
-		}
+// SetRootfs makes the command run in rootfs, or in the root directory if it
+// is empty.
+func (r *RunCommand) SetRootfs(rootfs string) {
+	r.mountCtx.root = rootfs
+}
+
+func runCommandInExec(config *v1.Config, buildArgs *dockerfile.BuildArgs, cmdRun *instructions.RunCommand, mountCtx runMountContext) error {
+	script, cleanup, err := heredocScript(cmdRun)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 
-		newCommand = append(shell, strings.Join(cmdRun.CmdLine, " "))
+	var newCommand []string
+	if script != "" {
+		newCommand = []string{script}
+	} else if len(cmdRun.Files) > 0 {
+		newCommand = append(runShell(config), heredocShell(cmdRun))
+	} else if cmdRun.PrependShell {
+		newCommand = append(runShell(config), strings.Join(cmdRun.CmdLine, " "))
 	} else {
 		newCommand = cmdRun.CmdLine
 		// Find and set absolute path of executable by setting PATH temporary
@@ -93,6 +102,13 @@ func runCommandInExec(config *v1.Config, buildArgs *dockerfile.BuildArgs, cmdRun
 	cmd.Stderr = os.Stderr
 	replacementEnvs := buildArgs.ReplacementEnvs(config.Env)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if mountCtx.root != "" {
+		cmd.SysProcAttr.Chroot = mountCtx.root
+		// The working directory is resolved in the new root.
+		if cmd.Dir == "" {
+			cmd.Dir = "/"
+		}
+	}
 
 	u := config.User
 	userAndGroup := strings.Split(u, ":")
@@ -114,10 +130,32 @@ func runCommandInExec(config *v1.Config, buildArgs *dockerfile.BuildArgs, cmdRun
 		return errors.Wrap(err, "adding default HOME variable")
 	}
 
-	cmd.Env = env
+	// Mounts only exist while the command runs, they are released before
+	// the filesystem is snapshotted.
+	mounts, err := setupRunMounts(cmdRun, config.WorkingDir, replacementEnvs, mountCtx)
+	if err != nil {
+		if rerr := mounts.release(); rerr != nil {
+			logrus.Warnf("Failed to release mounts: %v", rerr)
+		}
+		return errors.Wrap(err, "setting up mounts")
+	}
+	cmd.Env = append(env, mounts.env...)
+
+	err = startAndWait(cmd, runNetwork(cmdRun))
+	if rerr := mounts.release(); rerr != nil && err == nil {
+		err = errors.Wrap(rerr, "releasing mounts")
+	}
+	return err
+}
 
+// startAndWait runs cmd and kills any processes it left behind.
+func startAndWait(cmd *exec.Cmd, network instructions.NetworkMode) error {
 	logrus.Infof("Running: %s", cmd.Args)
-	if err := cmd.Start(); err != nil {
+	start := cmd.Start
+	if network == instructions.NetworkNone {
+		start = func() error { return startWithoutNetwork(cmd) }
+	}
+	if err := start(); err != nil {
 		return errors.Wrap(err, "starting command")
 	}
 
@@ -160,9 +198,18 @@ func addDefaultHOME(u string, envs []string) ([]string, error) {
 	return append(envs, fmt.Sprintf("%s=%s", constants.HOME, userObj.HomeDir)), nil
 }
 
+// runShell returns the shell that RUN commands in shell form are passed to.
+func runShell(config *v1.Config) []string {
+	if len(config.Shell) > 0 {
+		return config.Shell
+	}
+	// This is the default shell on Linux
+	return []string{"/bin/sh", "-c"}
+}
+
 // String returns some information about the command for the image config
 func (r *RunCommand) String() string {
-	return r.cmd.String()
+	return runString(r.cmd)
 }
 
 func (r *RunCommand) FilesToSnapshot() []string {
@@ -173,12 +220,17 @@ func (r *RunCommand) ProvidesFilesToSnapshot() bool {
 	return false
 }
 
+func (r *RunCommand) FilesUsedFromContext(config *v1.Config, buildArgs *dockerfile.BuildArgs) ([]string, error) {
+	return runMountFilesUsedFromContext(config, buildArgs, r.cmd, r.mountCtx)
+}
+
 // CacheCommand returns true since this command should be cached
 func (r *RunCommand) CacheCommand(img v1.Image) DockerCommand {
 
 	return &CachingRunCommand{
 		img:       img,
 		cmd:       r.cmd,
+		mountCtx:  r.mountCtx,
 		extractFn: util.ExtractFile,
 	}
 }
@@ -201,6 +253,7 @@ type CachingRunCommand struct {
 	img            v1.Image
 	extractedFiles []string
 	cmd            *instructions.RunCommand
+	mountCtx       runMountContext
 	extractFn      util.ExtractFunction
 }
 
@@ -208,6 +261,10 @@ func (cr *CachingRunCommand) IsArgsEnvsRequiredInCache() bool {
 	return true
 }
 
+func (cr *CachingRunCommand) FilesUsedFromContext(config *v1.Config, buildArgs *dockerfile.BuildArgs) ([]string, error) {
+	return runMountFilesUsedFromContext(config, buildArgs, cr.cmd, cr.mountCtx)
+}
+
 func (cr *CachingRunCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfile.BuildArgs) error {
 	logrus.Infof("Found cached layer, extracting to filesystem")
 	var err error
@@ -252,7 +309,7 @@ func (cr *CachingRunCommand) String() string {
 	if cr.cmd == nil {
 		return "nil command"
 	}
-	return cr.cmd.String()
+	return runString(cr.cmd)
 }
 
 func (cr *CachingRunCommand) MetadataOnly() bool {
@@ -29,6 +29,7 @@ import (
 type RunMarkerCommand struct {
 	BaseCommand
 	cmd      *instructions.RunCommand
+	mountCtx runMountContext
 	Files    []string
 	shdCache bool
 }
@@ -37,7 +38,7 @@ func (r *RunMarkerCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfi
 	// run command `touch filemarker`
 	logrus.Debugf("Using new RunMarker command")
 	prevFilesMap, _ := util.GetFSInfoMap("/", map[string]os.FileInfo{})
-	if err := runCommandInExec(config, buildArgs, r.cmd); err != nil {
+	if err := runCommandInExec(config, buildArgs, r.cmd, r.mountCtx); err != nil {
 		return err
 	}
 	_, r.Files = util.GetFSInfoMap("/", prevFilesMap)
@@ -48,7 +49,11 @@ func (r *RunMarkerCommand) ExecuteCommand(config *v1.Config, buildArgs *dockerfi
 
 // String returns some information about the command for the image config
 func (r *RunMarkerCommand) String() string {
-	return r.cmd.String()
+	return runString(r.cmd)
+}
+
+func (r *RunMarkerCommand) FilesUsedFromContext(config *v1.Config, buildArgs *dockerfile.BuildArgs) ([]string, error) {
+	return runMountFilesUsedFromContext(config, buildArgs, r.cmd, r.mountCtx)
 }
 
 func (r *RunMarkerCommand) FilesToSnapshot() []string {
@@ -69,6 +74,7 @@ func (r *RunMarkerCommand) CacheCommand(img v1.Image) DockerCommand {
 	return &CachingRunCommand{
 		img:       img,
 		cmd:       r.cmd,
+		mountCtx:  r.mountCtx,
 		extractFn: util.ExtractFile,
 	}
 }
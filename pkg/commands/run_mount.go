@@ -0,0 +1,616 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	otiai10Cpy "github.com/otiai10/copy"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultSecretDir      = "/run/secrets"
+	defaultSecretMode     = 0o400
+	defaultCacheMountMode = 0o755
+)
+
+// runMountContext holds the build wide state the --mount options of RUN
+// instructions are resolved against.
+type runMountContext struct {
+	secrets map[string]kConfig.Secret
+	// cacheDir is the directory backing the --mount=type=cache directories.
+	cacheDir string
+	// fileContext is the build context bind mounts without from= refer to.
+	fileContext util.FileContext
+	// stages maps stage names to their index, for bind mounts from a stage.
+	stages map[string]string
+	// root is the root directory the command runs in, the root directory of
+	// the build if it is empty.
+	root string
+}
+
+func newRunMountContext(opts *kConfig.KanikoOptions, fileContext util.FileContext, stageNameToIdx map[string]string) runMountContext {
+	ctx := runMountContext{
+		fileContext: fileContext,
+		stages:      stageNameToIdx,
+	}
+	if opts != nil {
+		ctx.secrets = opts.Secrets
+		ctx.cacheDir = CacheMountDir(opts)
+	}
+	return ctx
+}
+
+// CacheMountDir returns the directory the --mount=type=cache directories are
+// kept in, which defaults to a directory inside the kaniko directory.
+func CacheMountDir(opts *kConfig.KanikoOptions) string {
+	if opts.CacheMountDir != "" {
+		return opts.CacheMountDir
+	}
+	return filepath.Join(kConfig.KanikoDir, "mounts", "cache")
+}
+
+// runMounts keeps track of everything which was set up for the mounts of a
+// single RUN instruction, so it can be torn down before the filesystem is
+// snapshotted.
+type runMounts struct {
+	// env holds additional environment variables for the command, e.g. secrets
+	// exposed with --mount=type=secret,env=<name>.
+	env      []string
+	cleanups []func() error
+}
+
+// setupRunMounts prepares the mounts requested by cmd. The returned runMounts
+// must be released once the command exited, also if the command failed.
+func setupRunMounts(cmd *instructions.RunCommand, workdir string, envs []string, ctx runMountContext) (*runMounts, error) {
+	m := &runMounts{}
+	mounts, err := dockerfile.ExpandRunMounts(cmd, envs)
+	if err != nil {
+		return m, err
+	}
+	for _, mount := range mounts {
+		switch mount.Type {
+		case instructions.MountTypeSecret:
+			err = m.mountSecret(mount, workdir, ctx)
+		case instructions.MountTypeCache:
+			err = m.mountCache(mount, workdir, ctx)
+		case instructions.MountTypeBind:
+			err = m.mountBind(mount, workdir, ctx)
+		case instructions.MountTypeTmpfs:
+			err = m.mountTmpfs(mount, workdir, ctx)
+		default:
+			logrus.Warnf("Mount type %s is not supported, ignoring %s", mount.Type, mount.Target)
+		}
+		if err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// release undoes all mounts in reverse order.
+func (m *runMounts) release() error {
+	var firstErr error
+	for i := len(m.cleanups) - 1; i >= 0; i-- {
+		if err := m.cleanups[i](); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	m.cleanups = nil
+	return firstErr
+}
+
+// mountSecret exposes a secret given with --secret to the command, either as a
+// file or as an environment variable. Secret files are written with mode 0400
+// unless specified otherwise and removed again before the snapshot is taken.
+func (m *runMounts) mountSecret(mount *instructions.Mount, workdir string, ctx runMountContext) error {
+	id := mount.CacheID
+	if mount.Source != "" {
+		id = mount.Source
+	}
+	if id == "" {
+		id = filepath.Base(mount.Target)
+	}
+
+	secret, ok := ctx.secrets[id]
+	if !ok {
+		if mount.Required {
+			return fmt.Errorf("secret %s is required but was not provided, use --secret id=%s,...", id, id)
+		}
+		logrus.Infof("Secret %s was not provided, skipping its mount", id)
+		return nil
+	}
+	value, err := secret.Value()
+	if err != nil {
+		return errors.Wrapf(err, "reading secret %s", id)
+	}
+
+	if mount.Env != nil {
+		name := *mount.Env
+		if name == "" {
+			name = id
+		}
+		m.env = append(m.env, fmt.Sprintf("%s=%s", name, value))
+		if mount.Target == "" {
+			return nil
+		}
+	}
+
+	target := mount.Target
+	if target == "" {
+		target = filepath.Join(defaultSecretDir, id)
+	}
+	path := ctx.targetPath(target, workdir)
+
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.IsDir() {
+			return fmt.Errorf("cannot mount secret %s over directory %s", id, target)
+		}
+		if err := m.moveAside(path); err != nil {
+			return errors.Wrapf(err, "moving %s aside for secret %s", target, id)
+		}
+	}
+
+	removeMountpoint, err := createMountpoint(path, false)
+	if err != nil {
+		return errors.Wrapf(err, "creating mountpoint for secret %s", id)
+	}
+	m.cleanups = append(m.cleanups, removeMountpoint)
+
+	mode := os.FileMode(defaultSecretMode)
+	if mount.Mode != nil {
+		mode = os.FileMode(*mount.Mode)
+	}
+	uid, gid := 0, 0
+	if mount.UID != nil {
+		uid = int(*mount.UID)
+	}
+	if mount.GID != nil {
+		gid = int(*mount.GID)
+	}
+	logrus.Debugf("Mounting secret %s at %s", id, target)
+	if err := os.WriteFile(path, value, mode); err != nil {
+		return errors.Wrapf(err, "writing secret %s", id)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	return os.Lchown(path, uid, gid)
+}
+
+// mountCache bind mounts a persistent directory from the cache mount directory
+// over the target. Cache directories are keyed by their id, which defaults to
+// the target. Concurrent builds sharing the cache mount directory coordinate
+// through a lock file next to each cache directory, according to the sharing
+// mode of the mount.
+func (m *runMounts) mountCache(mount *instructions.Mount, workdir string, ctx runMountContext) error {
+	if mount.From != "" {
+		logrus.Warnf("Seeding cache mounts with from=%s is not supported, mounting %s empty on first use", mount.From, mount.Target)
+	}
+	id := mount.CacheID
+	if id == "" {
+		id = mount.Target
+	}
+	key, err := util.SHA256(strings.NewReader(id))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ctx.cacheDir, 0o755); err != nil {
+		return errors.Wrap(err, "creating cache mount directory")
+	}
+	dir := filepath.Join(ctx.cacheDir, key)
+
+	unlock, locked, err := lockCacheMount(dir+".lock", mount.CacheSharing)
+	if err != nil {
+		return errors.Wrapf(err, "locking cache mount %s", id)
+	}
+	m.cleanups = append(m.cleanups, unlock)
+	if !locked {
+		// A private cache which is in use gets a fresh directory for this run.
+		logrus.Infof("Cache mount %s is in use, using a private directory", id)
+		if dir, err = os.MkdirTemp(ctx.cacheDir, key+"-private-"); err != nil {
+			return err
+		}
+		private := dir
+		m.cleanups = append(m.cleanups, func() error { return os.RemoveAll(private) })
+		if err := prepareCacheMount(dir, mount); err != nil {
+			return errors.Wrapf(err, "creating cache mount %s", id)
+		}
+	} else if _, err := os.Lstat(dir); os.IsNotExist(err) {
+		if err := prepareCacheMount(dir, mount); err != nil {
+			return errors.Wrapf(err, "creating cache mount %s", id)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	logrus.Debugf("Mounting cache %s from %s at %s", id, dir, mount.Target)
+	if err := m.bindAt(dir, ctx.targetPath(mount.Target, workdir), true, mount.ReadOnly, !mount.ReadOnly); err != nil {
+		return errors.Wrapf(err, "mounting cache %s", id)
+	}
+	return nil
+}
+
+// mountBind bind mounts a file or directory from the build context, or from
+// the files saved from another stage or image, over the target. Read-write
+// mounts get a copy of the source, so writes are discarded after the command.
+func (m *runMounts) mountBind(mount *instructions.Mount, workdir string, ctx runMountContext) error {
+	src := ctx.bindMountSource(mount)
+	fi, err := os.Stat(src)
+	if err != nil {
+		return errors.Wrapf(err, "bind mount source %s", mount.Source)
+	}
+
+	// Files excluded from the build context must not be visible to the command.
+	excludes := mount.From == "" && len(ctx.fileContext.ExcludedFiles) > 0
+	if !mount.ReadOnly || excludes {
+		dir := filepath.Join(kConfig.KanikoDir, "mounts")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		tmp, err := os.MkdirTemp(dir, "bind-")
+		if err != nil {
+			return err
+		}
+		m.cleanups = append(m.cleanups, func() error { return os.RemoveAll(tmp) })
+		dst := filepath.Join(tmp, filepath.Base(src))
+		if err := otiai10Cpy.Copy(src, dst, otiai10Cpy.Options{
+			Skip: func(_ os.FileInfo, path, _ string) (bool, error) {
+				return excludes && ctx.fileContext.ExcludesFile(path), nil
+			},
+			PreserveTimes: true,
+			PreserveOwner: true,
+		}); err != nil {
+			return errors.Wrapf(err, "copying bind mount source %s", mount.Source)
+		}
+		src = dst
+	}
+
+	logrus.Debugf("Mounting %s at %s", src, mount.Target)
+	if err := m.bindAt(src, ctx.targetPath(mount.Target, workdir), fi.IsDir(), mount.ReadOnly, false); err != nil {
+		return errors.Wrapf(err, "bind mounting %s", mount.Source)
+	}
+	return nil
+}
+
+// mountTmpfs mounts an empty tmpfs over the target. Without the privileges to
+// mount, an empty directory takes its place instead.
+func (m *runMounts) mountTmpfs(mount *instructions.Mount, workdir string, ctx runMountContext) error {
+	path := ctx.targetPath(mount.Target, workdir)
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		return fmt.Errorf("cannot mount tmpfs over file %s", mount.Target)
+	}
+	removeMountpoint, err := createMountpoint(path, true)
+	if err != nil {
+		return errors.Wrapf(err, "creating mountpoint for tmpfs %s", mount.Target)
+	}
+	logrus.Debugf("Mounting tmpfs at %s", mount.Target)
+	if err := tmpfsMount(path, mount.SizeLimit); err != nil {
+		removeMountpoint()
+		if !errors.Is(err, unix.EPERM) {
+			return errors.Wrapf(err, "mounting tmpfs %s", mount.Target)
+		}
+		logrus.Debugf("Not permitted to mount, using an empty directory at %s instead", path)
+		return m.replaceAt(path, true)
+	}
+	m.cleanups = append(m.cleanups, func() error {
+		if err := unmount(path); err != nil {
+			return err
+		}
+		return removeMountpoint()
+	})
+	return nil
+}
+
+// bindMountSource returns the path a bind mount refers to. Mounts from other
+// stages or images use the files saved to the kaniko directory, mounts from a
+// named build context its directory.
+func (ctx runMountContext) bindMountSource(mount *instructions.Mount) string {
+	root := ctx.fileContext.Root
+	if dir, ok := ctx.fileContext.NamedContexts[mount.From]; ok {
+		root = dir
+	} else if mount.From != "" {
+		from := mount.From
+		if idx, ok := ctx.stages[strings.ToLower(from)]; ok {
+			from = idx
+		}
+		root = filepath.Join(kConfig.KanikoDir, from)
+	}
+	return filepath.Join(root, filepath.Join("/", mount.Source))
+}
+
+// bindAt bind mounts src at path, creating the mountpoint if needed. Without
+// the privileges to mount, like in an unprivileged container, src is copied to
+// path instead and, if persist is set, copied back once the command exited.
+func (m *runMounts) bindAt(src, path string, dir, readOnly, persist bool) error {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() != dir {
+		return fmt.Errorf("cannot mount over %s, it is of a different type", path)
+	}
+	removeMountpoint, err := createMountpoint(path, dir)
+	if err != nil {
+		return errors.Wrap(err, "creating mountpoint")
+	}
+	if err := bindMount(src, path, readOnly); err != nil {
+		removeMountpoint()
+		if !errors.Is(err, unix.EPERM) {
+			return err
+		}
+		logrus.Debugf("Not permitted to mount, copying %s to %s instead", src, path)
+		return m.copyAt(src, path, dir, persist)
+	}
+	m.cleanups = append(m.cleanups, func() error {
+		// Never remove the mountpoint while something might still be mounted.
+		if err := unmount(path); err != nil {
+			return err
+		}
+		return removeMountpoint()
+	})
+	return nil
+}
+
+// copyAt puts a copy of src at path, hiding whatever is there, and removes it
+// again once the command exited.
+func (m *runMounts) copyAt(src, path string, dir, persist bool) error {
+	if err := m.replaceAt(path, dir); err != nil {
+		return err
+	}
+	if err := otiai10Cpy.Copy(src, path, otiai10Cpy.Options{PreserveTimes: true, PreserveOwner: true}); err != nil {
+		return errors.Wrapf(err, "copying %s", src)
+	}
+	if persist {
+		m.cleanups = append(m.cleanups, func() error {
+			tmp := fmt.Sprintf("%s.kaniko-%d", src, time.Now().UnixNano())
+			if err := otiai10Cpy.Copy(path, tmp, otiai10Cpy.Options{PreserveTimes: true, PreserveOwner: true}); err != nil {
+				os.RemoveAll(tmp)
+				return errors.Wrapf(err, "copying %s back", path)
+			}
+			if err := os.RemoveAll(src); err != nil {
+				return err
+			}
+			return os.Rename(tmp, src)
+		})
+	}
+	return nil
+}
+
+// replaceAt hides whatever is at path behind a new, empty file or directory
+// until the command exited.
+func (m *runMounts) replaceAt(path string, dir bool) error {
+	if _, err := os.Lstat(path); err == nil {
+		if err := m.moveAside(path); err != nil {
+			return errors.Wrapf(err, "moving %s aside", path)
+		}
+	}
+	remove, err := createMountpoint(path, dir)
+	if err != nil {
+		return errors.Wrap(err, "creating mountpoint")
+	}
+	m.cleanups = append(m.cleanups, remove)
+	return nil
+}
+
+// moveAside moves an existing file out of the way until the command exited,
+// like a mount would hide it.
+func (m *runMounts) moveAside(path string) error {
+	restoreTimes, err := keepTimes(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	backup := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.kaniko-%d", filepath.Base(path), time.Now().UnixNano()))
+	if err := os.Rename(path, backup); err != nil {
+		return err
+	}
+	m.cleanups = append(m.cleanups, func() error {
+		if err := os.Rename(backup, path); err != nil {
+			return err
+		}
+		return restoreTimes()
+	})
+	return nil
+}
+
+// runMountFilesUsedFromContext returns the sources of the bind mounts of cmd,
+// so their content is part of the cache key.
+func runMountFilesUsedFromContext(config *v1.Config, buildArgs *dockerfile.BuildArgs, cmd *instructions.RunCommand, ctx runMountContext) ([]string, error) {
+	if cmd == nil {
+		return []string{}, nil
+	}
+	mounts, err := dockerfile.ExpandRunMounts(cmd, buildArgs.ReplacementEnvs(config.Env))
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, mount := range mounts {
+		if mount.Type == instructions.MountTypeBind {
+			files = append(files, ctx.bindMountSource(mount))
+		}
+	}
+	return files, nil
+}
+
+// prepareCacheMount creates a new cache directory with the ownership and
+// mode requested by the mount.
+func prepareCacheMount(dir string, mount *instructions.Mount) error {
+	mode := os.FileMode(defaultCacheMountMode)
+	if mount.Mode != nil {
+		mode = os.FileMode(*mount.Mode)
+	}
+	uid, gid := 0, 0
+	if mount.UID != nil {
+		uid = int(*mount.UID)
+	}
+	if mount.GID != nil {
+		gid = int(*mount.GID)
+	}
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, mode); err != nil {
+		return err
+	}
+	return os.Lchown(dir, uid, gid)
+}
+
+// lockCacheMount takes the lock for a cache directory according to its
+// sharing mode. Shared caches take a shared lock, so locked and private users
+// can tell they are in use. Locked caches wait for an exclusive lock. Private
+// caches try to take an exclusive lock and report whether they got it.
+func lockCacheMount(path string, sharing instructions.ShareMode) (func() error, bool, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, false, err
+	}
+	how := unix.LOCK_SH
+	switch sharing {
+	case instructions.MountSharingLocked:
+		how = unix.LOCK_EX
+	case instructions.MountSharingPrivate:
+		how = unix.LOCK_EX | unix.LOCK_NB
+	}
+	if err := unix.Flock(int(f.Fd()), how); err != nil {
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return f.Close, false, nil
+		}
+		f.Close()
+		return nil, false, err
+	}
+	return func() error {
+		unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		return f.Close()
+	}, true, nil
+}
+
+// bindMount mounts src at target, read-only if requested.
+var bindMount = func(src, target string, readOnly bool) error {
+	if err := unix.Mount(src, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return err
+	}
+	if readOnly {
+		if err := unix.Mount("", target, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+			unmount(target)
+			return err
+		}
+	}
+	return nil
+}
+
+// tmpfsMount mounts a new tmpfs at target, limited to size bytes if set.
+var tmpfsMount = func(target string, size int64) error {
+	data := ""
+	if size > 0 {
+		data = fmt.Sprintf("size=%d", size)
+	}
+	return unix.Mount("tmpfs", target, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, data)
+}
+
+// unmount detaches whatever is mounted at target.
+func unmount(target string) error {
+	if err := unix.Unmount(target, unix.MNT_DETACH); err != nil {
+		return errors.Wrapf(err, "unmounting %s", target)
+	}
+	return nil
+}
+
+// targetPath returns the absolute path of a mount target, which may be
+// relative to the working directory.
+func (ctx runMountContext) targetPath(target, workdir string) string {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join("/", workdir, target)
+	}
+	root := ctx.root
+	if root == "" {
+		root = kConfig.RootDir
+	}
+	return filepath.Join(root, target)
+}
+
+// createMountpoint makes sure path exists so something can be mounted over it,
+// creating it and any missing parent directories. The returned func removes
+// whatever was created and restores the timestamps of the closest existing
+// parent, so the mountpoint doesn't end up in the next snapshot.
+func createMountpoint(path string, dir bool) (func() error, error) {
+	anchor := path
+	for {
+		if _, err := os.Lstat(anchor); err == nil {
+			break
+		}
+		parent := filepath.Dir(anchor)
+		if parent == anchor {
+			break
+		}
+		anchor = parent
+	}
+	if anchor == path {
+		return func() error { return nil }, nil
+	}
+
+	restoreTimes, err := keepTimes(anchor)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(anchor, path)
+	if err != nil {
+		return nil, err
+	}
+	created := filepath.Join(anchor, strings.Split(rel, string(filepath.Separator))[0])
+	cleanup := func() error {
+		if err := os.RemoveAll(created); err != nil {
+			return errors.Wrapf(err, "removing mountpoint %s", path)
+		}
+		return restoreTimes()
+	}
+
+	if dir {
+		err = os.MkdirAll(path, 0o755)
+	} else if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600); err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	return cleanup, nil
+}
+
+// keepTimes records the modification time of path and returns a func which
+// restores it. Creating and removing mountpoints changes the modification time
+// of their parent directory, which would otherwise be picked up by the snapshot.
+func keepTimes(path string) (func() error, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	mtime := fi.ModTime()
+	return func() error {
+		return os.Chtimes(path, time.Time{}, mtime)
+	}, nil
+}
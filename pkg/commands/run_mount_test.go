@@ -0,0 +1,515 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kConfig "github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"golang.org/x/sys/unix"
+)
+
+func parseRunCommand(t *testing.T, line string) *instructions.RunCommand {
+	t.Helper()
+	cmds, err := dockerfile.ParseCommands([]string{line})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmds[0].(*instructions.RunCommand)
+}
+
+func setRootDir(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	original := kConfig.RootDir
+	kConfig.RootDir = root
+	t.Cleanup(func() { kConfig.RootDir = original })
+	return root
+}
+
+func Test_setupRunMounts_Secret(t *testing.T) {
+	root := setRootDir(t)
+	secretFile := filepath.Join(t.TempDir(), "npmrc")
+	if err := os.WriteFile(secretFile, []byte("token"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KANIKO_TEST_TOKEN", "env-token")
+	ctx := runMountContext{secrets: map[string]kConfig.Secret{
+		"npmrc": {ID: "npmrc", Source: secretFile},
+		"token": {ID: "token", Env: "KANIKO_TEST_TOKEN"},
+	}}
+
+	tests := []struct {
+		name      string
+		run       string
+		path      string
+		content   string
+		mode      os.FileMode
+		env       []string
+		shouldErr bool
+	}{
+		{
+			name:    "default target",
+			run:     "RUN --mount=type=secret,id=npmrc cat /run/secrets/npmrc",
+			path:    "/run/secrets/npmrc",
+			content: "token",
+			mode:    0o400,
+		},
+		{
+			name:    "custom target and mode",
+			run:     "RUN --mount=type=secret,id=npmrc,target=/root/.npmrc,mode=0440 npm ci",
+			path:    "/root/.npmrc",
+			content: "token",
+			mode:    0o440,
+		},
+		{
+			name:    "relative target",
+			run:     "RUN --mount=type=secret,id=npmrc,target=.npmrc npm ci",
+			path:    "/app/.npmrc",
+			content: "token",
+			mode:    0o400,
+		},
+		{
+			name:    "id defaults to target base name",
+			run:     "RUN --mount=type=secret,target=/etc/npmrc npm ci",
+			path:    "/etc/npmrc",
+			content: "token",
+			mode:    0o400,
+		},
+		{
+			name: "environment variable",
+			run:  "RUN --mount=type=secret,id=token,env=NPM_TOKEN npm ci",
+			env:  []string{"NPM_TOKEN=env-token"},
+		},
+		{
+			name: "missing optional secret",
+			run:  "RUN --mount=type=secret,id=missing cat /run/secrets/missing",
+		},
+		{
+			name:      "missing required secret",
+			run:       "RUN --mount=type=secret,id=missing,required cat /run/secrets/missing",
+			shouldErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := os.MkdirAll(filepath.Join(root, "app"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			past := time.Now().Add(-time.Hour).Truncate(time.Second)
+			if err := os.Chtimes(root, past, past); err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := setupRunMounts(parseRunCommand(t, test.run), "/app", nil, ctx)
+			if test.shouldErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				testutil.CheckNoError(t, m.release())
+				return
+			}
+			testutil.CheckNoError(t, err)
+			testutil.CheckDeepEqual(t, test.env, m.env)
+
+			if test.path != "" && test.content != "" {
+				path := filepath.Join(root, test.path)
+				b, err := os.ReadFile(path)
+				testutil.CheckNoError(t, err)
+				testutil.CheckDeepEqual(t, test.content, string(b))
+				fi, err := os.Stat(path)
+				testutil.CheckNoError(t, err)
+				testutil.CheckDeepEqual(t, test.mode, fi.Mode().Perm())
+			}
+
+			testutil.CheckNoError(t, m.release())
+			if test.path != "" {
+				if _, err := os.Lstat(filepath.Join(root, test.path)); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed, got %v", test.path, err)
+				}
+			}
+			fi, err := os.Stat(root)
+			testutil.CheckNoError(t, err)
+			if !fi.ModTime().Equal(past) {
+				t.Errorf("expected mtime of %s to be restored to %v, got %v", root, past, fi.ModTime())
+			}
+		})
+	}
+}
+
+func Test_setupRunMounts_SecretShadowsExistingFile(t *testing.T) {
+	root := setRootDir(t)
+	existing := filepath.Join(root, "etc", "npmrc")
+	if err := os.MkdirAll(filepath.Dir(existing), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KANIKO_TEST_TOKEN", "secret")
+	ctx := runMountContext{secrets: map[string]kConfig.Secret{
+		"npmrc": {ID: "npmrc", Env: "KANIKO_TEST_TOKEN"},
+	}}
+
+	m, err := setupRunMounts(parseRunCommand(t, "RUN --mount=type=secret,id=npmrc,target=/etc/npmrc npm ci"), "", nil, ctx)
+	testutil.CheckNoError(t, err)
+	b, err := os.ReadFile(existing)
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, "secret", string(b))
+
+	testutil.CheckNoError(t, m.release())
+	b, err = os.ReadFile(existing)
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, "original", string(b))
+	entries, err := os.ReadDir(filepath.Dir(existing))
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, 1, len(entries))
+}
+
+func Test_RunCommand_ExecuteCommand_SecretEnv(t *testing.T) {
+	setRootDir(t)
+	t.Setenv("KANIKO_TEST_TOKEN", "s3cr3t")
+	cmd := &RunCommand{
+		cmd: parseRunCommand(t, `RUN --mount=type=secret,id=token,env=TOKEN test "$TOKEN" = s3cr3t`),
+		mountCtx: runMountContext{secrets: map[string]kConfig.Secret{
+			"token": {ID: "token", Env: "KANIKO_TEST_TOKEN"},
+		}},
+	}
+	cfg := &v1.Config{Env: []string{"PATH=" + os.Getenv("PATH")}}
+	testutil.CheckNoError(t, cmd.ExecuteCommand(cfg, dockerfile.NewBuildArgs(nil)))
+	for _, env := range cfg.Env {
+		if env == "TOKEN=s3cr3t" {
+			t.Error("secret leaked into the image config")
+		}
+	}
+}
+
+func Test_setupRunMounts_Cache(t *testing.T) {
+	root := setRootDir(t)
+	ctx := runMountContext{cacheDir: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(root, "var", "cache", "apt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "var", "cache", "apt", "base"), []byte("base"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(line, workdir string, fn func()) {
+		t.Helper()
+		m, err := setupRunMounts(parseRunCommand(t, line), workdir, nil, ctx)
+		if err != nil {
+			m.release()
+			t.Fatal(err)
+		}
+		fn()
+		testutil.CheckNoError(t, m.release())
+	}
+
+	run("RUN --mount=type=cache,target=/var/cache/apt apt-get update", "", func() {
+		if _, err := os.Stat(filepath.Join(root, "var", "cache", "apt", "base")); !os.IsNotExist(err) {
+			t.Errorf("expected the cache to hide the existing directory, got %v", err)
+		}
+		testutil.CheckNoError(t, os.WriteFile(filepath.Join(root, "var", "cache", "apt", "pkg"), []byte("pkg"), 0o644))
+	})
+	b, err := os.ReadFile(filepath.Join(root, "var", "cache", "apt", "base"))
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, "base", string(b))
+	if _, err := os.Stat(filepath.Join(root, "var", "cache", "apt", "pkg")); !os.IsNotExist(err) {
+		t.Errorf("expected cached files to stay out of the filesystem, got %v", err)
+	}
+
+	// The same cache is mounted again on the next run.
+	run("RUN --mount=type=cache,target=/var/cache/apt,sharing=locked apt-get install", "", func() {
+		b, err := os.ReadFile(filepath.Join(root, "var", "cache", "apt", "pkg"))
+		testutil.CheckNoError(t, err)
+		testutil.CheckDeepEqual(t, "pkg", string(b))
+	})
+
+	// Caches with an id can be mounted anywhere, missing mountpoints are removed again.
+	run("RUN --mount=type=cache,id=gocache,target=.cache/go-build,uid=1000,mode=0700 go build", "/src", func() {
+		fi, err := os.Stat(filepath.Join(root, "src", ".cache", "go-build"))
+		testutil.CheckNoError(t, err)
+		testutil.CheckDeepEqual(t, os.FileMode(0o700), fi.Mode().Perm())
+		testutil.CheckNoError(t, os.WriteFile(filepath.Join(root, "src", ".cache", "go-build", "obj"), nil, 0o644))
+	})
+	if _, err := os.Stat(filepath.Join(root, "src")); !os.IsNotExist(err) {
+		t.Errorf("expected the mountpoint to be removed, got %v", err)
+	}
+	run("RUN --mount=type=cache,id=gocache,target=/root/.cache/go-build,readonly go build", "", func() {
+		_, err := os.Stat(filepath.Join(root, "root", ".cache", "go-build", "obj"))
+		testutil.CheckNoError(t, err)
+		if err := os.WriteFile(filepath.Join(root, "root", ".cache", "go-build", "new"), nil, 0o644); err == nil {
+			t.Error("expected a read-only cache mount")
+		}
+	})
+}
+
+func Test_lockCacheMount(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "cache.lock")
+	unlockShared, locked, err := lockCacheMount(lock, instructions.MountSharingShared)
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, true, locked)
+
+	unlockPrivate, locked, err := lockCacheMount(lock, instructions.MountSharingPrivate)
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, false, locked)
+	testutil.CheckNoError(t, unlockPrivate())
+
+	testutil.CheckNoError(t, unlockShared())
+	unlockPrivate, locked, err = lockCacheMount(lock, instructions.MountSharingPrivate)
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, true, locked)
+	testutil.CheckNoError(t, unlockPrivate())
+}
+
+func Test_setupRunMounts_Bind(t *testing.T) {
+	root := setRootDir(t)
+	kanikoDir := t.TempDir()
+	originalKanikoDir := kConfig.KanikoDir
+	kConfig.KanikoDir = kanikoDir
+	t.Cleanup(func() { kConfig.KanikoDir = originalKanikoDir })
+
+	buildContext := t.TempDir()
+	namedContext := t.TempDir()
+	for path, content := range map[string]string{
+		filepath.Join(namedContext, "lib", "dep.so"):      "dependency",
+		filepath.Join(buildContext, "src", "main.c"):      "int main() {}",
+		filepath.Join(buildContext, "src", "secret.key"):  "key",
+		filepath.Join(kanikoDir, "1", "out", "app"):       "binary",
+		filepath.Join(kanikoDir, "alpine", "etc", "motd"): "welcome",
+	} {
+		testutil.CheckNoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		testutil.CheckNoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	ctx := runMountContext{
+		fileContext: util.FileContext{Root: buildContext, NamedContexts: map[string]string{"deps": namedContext}},
+		stages:      map[string]string{"builder": "1"},
+	}
+
+	tests := []struct {
+		name      string
+		run       string
+		ctx       runMountContext
+		path      string
+		content   string
+		readOnly  bool
+		excluded  string
+		shouldErr bool
+	}{
+		{
+			name:     "build context",
+			run:      "RUN --mount=type=bind,source=src,target=/src make",
+			ctx:      ctx,
+			path:     "/src/main.c",
+			content:  "int main() {}",
+			readOnly: true,
+		},
+		{
+			name:    "build context read-write",
+			run:     "RUN --mount=type=bind,source=src,target=src,rw make",
+			ctx:     ctx,
+			path:    "/app/src/main.c",
+			content: "int main() {}",
+		},
+		{
+			name: "build context with excluded files",
+			run:  "RUN --mount=type=bind,source=src,target=/src make",
+			ctx: runMountContext{
+				fileContext: util.FileContext{Root: buildContext, ExcludedFiles: []string{"src/*.key"}},
+			},
+			path:     "/src/main.c",
+			content:  "int main() {}",
+			readOnly: true,
+			excluded: "/src/secret.key",
+		},
+		{
+			name:     "stage by name",
+			run:      "RUN --mount=type=bind,from=builder,source=/out/app,target=/usr/bin/app app",
+			ctx:      ctx,
+			path:     "/usr/bin/app",
+			content:  "binary",
+			readOnly: true,
+		},
+		{
+			name:     "stage by index",
+			run:      "RUN --mount=type=bind,from=1,target=/builder ls",
+			ctx:      ctx,
+			path:     "/builder/out/app",
+			content:  "binary",
+			readOnly: true,
+		},
+		{
+			name:     "image",
+			run:      "RUN --mount=type=bind,from=alpine,source=etc,target=/alpine ls",
+			ctx:      ctx,
+			path:     "/alpine/motd",
+			content:  "welcome",
+			readOnly: true,
+		},
+		{
+			name:     "named build context",
+			run:      "RUN --mount=type=bind,from=deps,source=lib,target=/deps ls",
+			ctx:      ctx,
+			path:     "/deps/dep.so",
+			content:  "dependency",
+			readOnly: true,
+		},
+		{
+			name:      "missing source",
+			run:       "RUN --mount=type=bind,source=missing,target=/src make",
+			ctx:       ctx,
+			shouldErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := setupRunMounts(parseRunCommand(t, test.run), "/app", nil, test.ctx)
+			if test.shouldErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				testutil.CheckNoError(t, m.release())
+				return
+			}
+			testutil.CheckNoError(t, err)
+
+			path := filepath.Join(root, test.path)
+			b, err := os.ReadFile(path)
+			testutil.CheckNoError(t, err)
+			testutil.CheckDeepEqual(t, test.content, string(b))
+			err = os.WriteFile(path, []byte("changed"), 0o644)
+			if test.readOnly && err == nil {
+				t.Error("expected a read-only mount")
+			} else if !test.readOnly {
+				testutil.CheckNoError(t, err)
+			}
+			if test.excluded != "" {
+				if _, err := os.Stat(filepath.Join(root, test.excluded)); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be excluded, got %v", test.excluded, err)
+				}
+			}
+
+			testutil.CheckNoError(t, m.release())
+			entries, err := os.ReadDir(root)
+			testutil.CheckNoError(t, err)
+			for _, e := range entries {
+				if e.Name() != "app" {
+					t.Errorf("expected mountpoints to be removed, found %s", e.Name())
+				}
+			}
+		})
+	}
+
+	b, err := os.ReadFile(filepath.Join(buildContext, "src", "main.c"))
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, "int main() {}", string(b))
+}
+
+func Test_runMountFilesUsedFromContext(t *testing.T) {
+	kanikoDir := t.TempDir()
+	originalKanikoDir := kConfig.KanikoDir
+	kConfig.KanikoDir = kanikoDir
+	t.Cleanup(func() { kConfig.KanikoDir = originalKanikoDir })
+
+	cmd := parseRunCommand(t, "RUN --mount=type=bind,source=$SRC,target=/src --mount=type=bind,from=builder,source=/out,target=/out --mount=type=cache,target=/root/.cache make")
+	ctx := runMountContext{
+		fileContext: util.FileContext{Root: "/workspace"},
+		stages:      map[string]string{"builder": "0"},
+	}
+	cfg := &v1.Config{Env: []string{"SRC=../src"}}
+
+	files, err := runMountFilesUsedFromContext(cfg, dockerfile.NewBuildArgs(nil), cmd, ctx)
+	testutil.CheckErrorAndDeepEqual(t, false, err, []string{"/workspace/src", filepath.Join(kanikoDir, "0", "out")}, files)
+}
+
+func Test_setupRunMounts_CopyWithoutMountPrivileges(t *testing.T) {
+	root := setRootDir(t)
+	original := bindMount
+	bindMount = func(string, string, bool) error { return unix.EPERM }
+	t.Cleanup(func() { bindMount = original })
+
+	buildContext := t.TempDir()
+	testutil.CheckNoError(t, os.WriteFile(filepath.Join(buildContext, "go.mod"), []byte("module foo"), 0o644))
+	testutil.CheckNoError(t, os.MkdirAll(filepath.Join(root, "src"), 0o755))
+	testutil.CheckNoError(t, os.WriteFile(filepath.Join(root, "src", "go.mod"), []byte("module bar"), 0o644))
+	ctx := runMountContext{
+		cacheDir:    t.TempDir(),
+		fileContext: util.FileContext{Root: buildContext},
+	}
+	run := "RUN --mount=type=bind,source=go.mod,target=/src/go.mod --mount=type=cache,target=/root/.cache/go-build go build"
+
+	m, err := setupRunMounts(parseRunCommand(t, run), "", nil, ctx)
+	testutil.CheckNoError(t, err)
+	b, err := os.ReadFile(filepath.Join(root, "src", "go.mod"))
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, "module foo", string(b))
+	testutil.CheckNoError(t, os.WriteFile(filepath.Join(root, "root", ".cache", "go-build", "obj"), []byte("obj"), 0o644))
+	testutil.CheckNoError(t, m.release())
+
+	b, err = os.ReadFile(filepath.Join(root, "src", "go.mod"))
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, "module bar", string(b))
+	if _, err := os.Stat(filepath.Join(root, "root")); !os.IsNotExist(err) {
+		t.Errorf("expected the cache to be removed, got %v", err)
+	}
+
+	// Writes to the cache are kept for the next run.
+	m, err = setupRunMounts(parseRunCommand(t, run), "", nil, ctx)
+	testutil.CheckNoError(t, err)
+	b, err = os.ReadFile(filepath.Join(root, "root", ".cache", "go-build", "obj"))
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, "obj", string(b))
+	testutil.CheckNoError(t, m.release())
+}
+
+func Test_setupRunMounts_Tmpfs(t *testing.T) {
+	for _, privileged := range []bool{true, false} {
+		t.Run(fmt.Sprintf("privileged=%v", privileged), func(t *testing.T) {
+			root := setRootDir(t)
+			if !privileged {
+				original := tmpfsMount
+				tmpfsMount = func(string, int64) error { return unix.EPERM }
+				t.Cleanup(func() { tmpfsMount = original })
+			}
+			testutil.CheckNoError(t, os.MkdirAll(filepath.Join(root, "tmp"), 0o755))
+			testutil.CheckNoError(t, os.WriteFile(filepath.Join(root, "tmp", "keep"), nil, 0o644))
+
+			m, err := setupRunMounts(parseRunCommand(t, "RUN --mount=type=tmpfs,target=/tmp,size=1m --mount=type=tmpfs,target=/scratch make"), "", nil, runMountContext{})
+			testutil.CheckNoError(t, err)
+			if _, err := os.Stat(filepath.Join(root, "tmp", "keep")); !os.IsNotExist(err) {
+				t.Errorf("expected the tmpfs to hide existing files, got %v", err)
+			}
+			testutil.CheckNoError(t, os.WriteFile(filepath.Join(root, "scratch", "out"), nil, 0o644))
+			testutil.CheckNoError(t, m.release())
+
+			_, err = os.Stat(filepath.Join(root, "tmp", "keep"))
+			testutil.CheckNoError(t, err)
+			if _, err := os.Stat(filepath.Join(root, "scratch")); !os.IsNotExist(err) {
+				t.Errorf("expected the tmpfs mountpoint to be removed, got %v", err)
+			}
+		})
+	}
+}
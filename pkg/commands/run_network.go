@@ -0,0 +1,77 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"os/exec"
+	"runtime"
+	"slices"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// runNetwork returns the --network mode of a RUN instruction.
+func runNetwork(cmd *instructions.RunCommand) instructions.NetworkMode {
+	// Commands which weren't created by the parser carry no network state.
+	if !slices.Contains(cmd.FlagsUsed, "network") {
+		return instructions.NetworkDefault
+	}
+	return instructions.GetNetwork(cmd)
+}
+
+// startWithoutNetwork starts cmd in a new network namespace, in which only the
+// loopback interface exists.
+func startWithoutNetwork(cmd *exec.Cmd) error {
+	errs := make(chan error, 1)
+	go func() {
+		// Namespaces belong to threads, the child inherits the one of the
+		// thread starting it. The thread is never unlocked, so it exits with
+		// this goroutine instead of running other goroutines without network.
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			errs <- errors.Wrap(err, "creating network namespace for --network=none")
+			return
+		}
+		if err := loopbackUp(); err != nil {
+			errs <- errors.Wrap(err, "bringing up loopback interface")
+			return
+		}
+		errs <- cmd.Start()
+	}()
+	return <-errs
+}
+
+// loopbackUp brings up the loopback interface of the current network namespace.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"golang.org/x/sys/unix"
+)
+
+func Test_runNetwork(t *testing.T) {
+	tests := []struct {
+		run  string
+		want instructions.NetworkMode
+	}{
+		{run: "RUN make", want: instructions.NetworkDefault},
+		{run: "RUN --network=none make", want: instructions.NetworkNone},
+		{run: "RUN --network=host make", want: instructions.NetworkHost},
+	}
+	for _, test := range tests {
+		t.Run(test.run, func(t *testing.T) {
+			testutil.CheckDeepEqual(t, test.want, runNetwork(parseRunCommand(t, test.run)))
+		})
+	}
+	testutil.CheckDeepEqual(t, instructions.NetworkDefault, runNetwork(&instructions.RunCommand{}))
+}
+
+func Test_RunCommand_ExecuteCommand_NetworkNone(t *testing.T) {
+	cfg := &v1.Config{Env: []string{"PATH=" + os.Getenv("PATH")}}
+	// Only the loopback interface is listed, after the two header lines.
+	cmd := &RunCommand{
+		cmd: parseRunCommand(t, `RUN --network=none test "$(tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' ')" = lo`),
+	}
+	testutil.CheckNoError(t, cmd.ExecuteCommand(cfg, dockerfile.NewBuildArgs(nil)))
+}
+
+func Test_loopbackUp(t *testing.T) {
+	errs := make(chan error, 1)
+	go func() {
+		// Never unlocked, so the thread in the new namespace exits with the goroutine.
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			errs <- err
+			return
+		}
+		if err := loopbackUp(); err != nil {
+			errs <- err
+			return
+		}
+		fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer unix.Close(fd)
+		errs <- unix.Connect(fd, &unix.SockaddrInet4{Port: 53, Addr: [4]byte{127, 0, 0, 1}})
+	}()
+	testutil.CheckNoError(t, <-errs)
+}
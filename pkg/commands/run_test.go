@@ -286,9 +286,8 @@ func Test_CachingRunCommand_ExecuteCommand(t *testing.T) {
 					}
 				}
 
-				// CachingRunCommand does not override BaseCommand
-				// FilesUseFromContext so this will always return an empty slice and no error
-				// This seems like it might be a bug as it results in RunCommands and CachingRunCommands generating different cache keys - cvgw - 2019-11-27
+				// CachingRunCommand uses the same files from context as RunCommand,
+				// which are the sources of bind mounts, so this returns an empty slice
 				cmdFiles, err := c.FilesUsedFromContext(
 					config, buildArgs,
 				)
@@ -18,6 +18,8 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -125,3 +127,103 @@ func (c *multiKeyMultiValueArg) Set(value string) error {
 func (c *multiKeyMultiValueArg) Type() string {
 	return "key-multi-value-arg type"
 }
+
+// Secret is a build secret which can be exposed to RUN instructions with
+// --mount=type=secret. Its value is read either from a file or from an
+// environment variable of the executor.
+type Secret struct {
+	ID     string
+	Source string
+	Env    string
+}
+
+// Value returns the content of the secret.
+func (s Secret) Value() ([]byte, error) {
+	if s.Env != "" {
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s for secret %s is not set", s.Env, s.ID)
+		}
+		return []byte(v), nil
+	}
+	return os.ReadFile(s.Source)
+}
+
+// This type is used to supported passing in multiple secrets in the format
+// id=<id>,src=<path> or id=<id>,env=<variable>
+type secretArg map[string]Secret
+
+func (a *secretArg) String() string {
+	var result []string
+	for id, s := range *a {
+		if s.Env != "" {
+			result = append(result, fmt.Sprintf("id=%s,env=%s", id, s.Env))
+		} else {
+			result = append(result, fmt.Sprintf("id=%s,src=%s", id, s.Source))
+		}
+	}
+	return strings.Join(result, ";")
+}
+
+func (a *secretArg) Set(value string) error {
+	var s Secret
+	typ := ""
+	for _, field := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("invalid secret %s, expect key=value pairs", value)
+		}
+		switch strings.ToLower(key) {
+		case "id":
+			s.ID = val
+		case "type":
+			typ = val
+		case "src", "source":
+			s.Source = val
+		case "env":
+			s.Env = val
+		default:
+			return fmt.Errorf("unexpected key %q in secret %s", key, value)
+		}
+	}
+	if s.ID == "" {
+		return fmt.Errorf("invalid secret %s, id is required", value)
+	}
+	switch typ {
+	case "":
+		// Like docker buildx, fall back to an environment variable named after the id.
+		if s.Source == "" && s.Env == "" {
+			s.Env = s.ID
+		}
+	case "file":
+		if s.Source == "" {
+			return fmt.Errorf("invalid secret %s, src is required for file secrets", value)
+		}
+	case "env":
+		if s.Env == "" {
+			s.Env = s.Source
+		}
+		if s.Env == "" {
+			s.Env = s.ID
+		}
+		s.Source = ""
+	default:
+		return fmt.Errorf("unsupported secret type %q, must be file or env", typ)
+	}
+	if s.Source != "" && s.Env != "" {
+		return fmt.Errorf("invalid secret %s, src and env are mutually exclusive", value)
+	}
+	if s.Source != "" {
+		abs, err := filepath.Abs(s.Source)
+		if err != nil {
+			return err
+		}
+		s.Source = abs
+	}
+	(*a)[s.ID] = s
+	return nil
+}
+
+func (a *secretArg) Type() string {
+	return "secret-arg type"
+}
@@ -16,7 +16,11 @@ limitations under the License.
 
 package config
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestMultiArg_Set_shouldAppendValue(t *testing.T) {
 	var arg multiArg
@@ -78,3 +82,86 @@ func Test_multiKeyMultiValueArg_Set_WithEmptyValueShouldWork(t *testing.T) {
 		t.Error("multiKeyMultiValueArg must handle empty value")
 	}
 }
+
+func Test_secretArg_Set(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		expected  Secret
+		shouldErr bool
+	}{
+		{
+			name:     "file secret",
+			value:    "id=npmrc,src=/run/npmrc",
+			expected: Secret{ID: "npmrc", Source: "/run/npmrc"},
+		},
+		{
+			name:     "env secret",
+			value:    "id=token,env=NPM_TOKEN",
+			expected: Secret{ID: "token", Env: "NPM_TOKEN"},
+		},
+		{
+			name:     "env secret defaults to the id",
+			value:    "id=NPM_TOKEN",
+			expected: Secret{ID: "NPM_TOKEN", Env: "NPM_TOKEN"},
+		},
+		{
+			name:     "explicit env type with src",
+			value:    "type=env,id=token,src=NPM_TOKEN",
+			expected: Secret{ID: "token", Env: "NPM_TOKEN"},
+		},
+		{
+			name:      "missing id",
+			value:     "src=/run/npmrc",
+			shouldErr: true,
+		},
+		{
+			name:      "src and env",
+			value:     "id=token,src=/run/npmrc,env=NPM_TOKEN",
+			shouldErr: true,
+		},
+		{
+			name:      "unknown key",
+			value:     "id=token,foo=bar",
+			shouldErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			arg := make(secretArg)
+			err := arg.Set(test.value)
+			if test.shouldErr {
+				if err == nil {
+					t.Errorf("expected an error for %s", test.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if arg[test.expected.ID] != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, arg[test.expected.ID])
+			}
+		})
+	}
+}
+
+func Test_Secret_Value(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KANIKO_TEST_SECRET", "from-env")
+
+	v, err := Secret{ID: "file", Source: path}.Value()
+	if err != nil || string(v) != "from-file" {
+		t.Errorf("expected from-file, got %q (%v)", v, err)
+	}
+	v, err = Secret{ID: "env", Env: "KANIKO_TEST_SECRET"}.Value()
+	if err != nil || string(v) != "from-env" {
+		t.Errorf("expected from-env, got %q (%v)", v, err)
+	}
+	if _, err := (Secret{ID: "missing", Env: "KANIKO_TEST_SECRET_MISSING"}).Value(); err == nil {
+		t.Error("expected an error for an unset environment variable")
+	}
+}
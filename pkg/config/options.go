@@ -28,6 +28,9 @@ import (
 type CacheOptions struct {
 	CacheDir string
 	CacheTTL time.Duration
+	// CacheMaxSize is the maximum size of the cache dir in bytes, 0 for no
+	// limit.
+	CacheMaxSize int64
 }
 
 // RegistryOptions are all the options related to the registries, set by command line arguments.
@@ -55,8 +58,11 @@ type KanikoOptions struct {
 	Destinations             multiArg
 	BuildArgs                multiArg
 	Labels                   multiArg
+	Secrets                  secretArg
+	BuildContexts            keyValueArg
 	Git                      KanikoGitOptions
 	IgnorePaths              multiArg
+	CacheFrom                multiArg
 	DockerfilePath           string
 	SrcContext               string
 	SnapshotMode             string
@@ -69,11 +75,14 @@ type KanikoOptions struct {
 	KanikoDir                string
 	Target                   string
 	CacheRepo                string
+	CacheMountDir            string
+	CacheReport              string
 	DigestFile               string
 	ImageNameDigestFile      string
 	ImageNameTagDigestFile   string
 	OCILayoutPath            string
 	Compression              Compression
+	CacheMode                CacheMode
 	CompressionLevel         int
 	ImageFSExtractRetry      int
 	SingleSnapshot           bool
@@ -91,6 +100,7 @@ type KanikoOptions struct {
 	ForceBuildMetadata       bool
 	InitialFSUnpacked        bool
 	SkipPushPermissionCheck  bool
+	DryRun                   bool
 }
 
 type KanikoGitOptions struct {
@@ -167,13 +177,91 @@ func (c *Compression) Type() string {
 	return "compression"
 }
 
+// CacheMode is an enumeration of the ways layers are stored in a registry
+// cache repo
+type CacheMode string
+
+const (
+	// CacheModeLayer stores each layer in an image tagged with its cache key.
+	CacheModeLayer CacheMode = "layer"
+	// CacheModeIndex stores the layers in a single cache index.
+	CacheModeIndex CacheMode = "index"
+)
+
+func (c *CacheMode) String() string {
+	return string(*c)
+}
+
+func (c *CacheMode) Set(v string) error {
+	switch v {
+	case "layer", "index":
+		*c = CacheMode(v)
+		return nil
+	default:
+		return errors.New(`must be either "layer" or "index"`)
+	}
+}
+
+func (c *CacheMode) Type() string {
+	return "cache-mode"
+}
+
 // WarmerOptions are options that are set by command line arguments to the cache warmer.
 type WarmerOptions struct {
 	CacheOptions
 	RegistryOptions
-	CustomPlatform string
-	Images         multiArg
-	Force          bool
-	DockerfilePath string
-	BuildArgs      multiArg
+	CustomPlatform  string
+	Images          multiArg
+	Force           bool
+	DockerfilePath  string
+	BuildArgs       multiArg
+	SrcContext      string
+	CacheRepo       string
+	LayerCacheRepo  string
+	CacheCopyLayers bool
+	CacheRunLayers  bool
+	OCILayout       bool
+	Platforms       multiArg
+	Parallelism     int
+}
+
+// ManifestOptions are options that are set by command line arguments to the
+// manifest command, which assembles images into an image index.
+type ManifestOptions struct {
+	Images      multiArg
+	Annotations keyValueArg
+	Format      IndexFormat
+}
+
+// CachePruneOptions are options that are set by command line arguments to the
+// cache prune command. Entries older than the cache TTL are always deleted.
+type CachePruneOptions struct {
+	MaxEntries int
+	MaxSize    int64
+}
+
+// IndexFormat is an enumeration of the supported image index formats
+type IndexFormat string
+
+const (
+	OCIIndex           IndexFormat = "oci"
+	DockerManifestList IndexFormat = "docker"
+)
+
+func (f *IndexFormat) String() string {
+	return string(*f)
+}
+
+func (f *IndexFormat) Set(v string) error {
+	switch v {
+	case "oci", "docker":
+		*f = IndexFormat(v)
+		return nil
+	default:
+		return errors.New(`must be either "oci" or "docker"`)
+	}
+}
+
+func (f *IndexFormat) Type() string {
+	return "index-format"
 }
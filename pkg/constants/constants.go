@@ -33,6 +33,9 @@ const (
 	SnapshotModeTime = "time"
 	SnapshotModeFull = "full"
 	SnapshotModeRedo = "redo"
+	// SnapshotModeOverlay runs RUN commands against an overlay mount to
+	// find the files they change.
+	SnapshotModeOverlay = "overlay"
 
 	// NoBaseImage is the scratch image
 	NoBaseImage = "scratch"
@@ -43,6 +46,10 @@ const (
 	GitBuildContextPrefix      = "git://"
 	HTTPSBuildContextPrefix    = "https://"
 
+	// Named build contexts can also refer to an image
+	DockerImageBuildContextPrefix = "docker-image://"
+	OCILayoutBuildContextPrefix   = "oci-layout://"
+
 	HOME = "HOME"
 	// DefaultHOMEValue is the default value Docker sets for $HOME
 	DefaultHOMEValue = "/root"
@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -60,7 +61,11 @@ func ParseStages(opts *config.KanikoOptions) ([]instructions.Stage, []instructio
 		return nil, nil, errors.Wrap(err, "parsing dockerfile")
 	}
 
-	metaArgs, err = expandNestedArgs(metaArgs, opts.BuildArgs)
+	buildArgs, err := BuildArgsWithPlatform(opts.BuildArgs, opts.CustomPlatform)
+	if err != nil {
+		return nil, nil, err
+	}
+	metaArgs, err = expandNestedArgs(metaArgs, buildArgs)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "expanding meta ARGs")
 	}
@@ -259,6 +264,36 @@ func ResolveCrossStageCommands(cmds []instructions.Command, stageNameToIdx map[s
 	}
 }
 
+// RunMountsFrom returns the stages or images the --mount options of a RUN
+// instruction refer to with from=, which can't contain variables.
+func RunMountsFrom(cmd *instructions.RunCommand) []string {
+	// Commands which weren't created by the parser carry no mount state.
+	if !slices.Contains(cmd.FlagsUsed, "mount") {
+		return nil
+	}
+	var from []string
+	for _, m := range instructions.GetMounts(cmd) {
+		if m.From != "" {
+			from = append(from, m.From)
+		}
+	}
+	return from
+}
+
+// ExpandRunMounts returns the --mount options of a RUN instruction with ARGs
+// and ENVs resolved.
+func ExpandRunMounts(cmd *instructions.RunCommand, envs []string) ([]*instructions.Mount, error) {
+	if !slices.Contains(cmd.FlagsUsed, "mount") {
+		return nil, nil
+	}
+	if err := cmd.Expand(func(word string) (string, error) {
+		return util.ResolveEnvironmentReplacement(word, envs, false)
+	}); err != nil {
+		return nil, errors.Wrap(err, "expanding mounts")
+	}
+	return instructions.GetMounts(cmd), nil
+}
+
 // resolveStagesArgs resolves all the args from list of stages
 func resolveStagesArgs(stages []instructions.Stage, args []string) error {
 	for i, s := range stages {
@@ -269,6 +304,17 @@ func resolveStagesArgs(stages []instructions.Stage, args []string) error {
 		if s.BaseName != resolvedBaseName {
 			stages[i].BaseName = resolvedBaseName
 		}
+		if s.Platform == "" {
+			continue
+		}
+		resolvedPlatform, err := util.ResolveEnvironmentReplacement(s.Platform, args, false)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("resolving platform %s", s.Platform))
+		}
+		if _, err := v1.ParsePlatform(resolvedPlatform); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("invalid platform %s of stage %s", resolvedPlatform, s.BaseName))
+		}
+		stages[i].Platform = resolvedPlatform
 	}
 	return nil
 }
@@ -278,12 +324,19 @@ func MakeKanikoStages(opts *config.KanikoOptions, stages []instructions.Stage, m
 	if err != nil {
 		return nil, errors.Wrap(err, "Error finding target stage")
 	}
-	args := unifyArgs(metaArgs, opts.BuildArgs)
+	buildArgs, err := BuildArgsWithPlatform(opts.BuildArgs, opts.CustomPlatform)
+	if err != nil {
+		return nil, err
+	}
+	args := unifyArgs(metaArgs, buildArgs)
 	if err := resolveStagesArgs(stages, args); err != nil {
 		return nil, errors.Wrap(err, "resolving args")
 	}
 	if opts.SkipUnusedStages {
-		stages = skipUnusedStages(stages, &targetStage, opts.Target)
+		stages, err = skipUnusedStages(stages, &targetStage, opts.Target)
+		if err != nil {
+			return nil, errors.Wrap(err, "skipping unused stages")
+		}
 	}
 	var kanikoStages []config.KanikoStage
 	for index, stage := range stages {
@@ -291,6 +344,10 @@ func MakeKanikoStages(opts *config.KanikoOptions, stages []instructions.Stage, m
 			logrus.Infof("Resolved base name %s to %s", stage.BaseName, stage.Name)
 		}
 		baseImageIndex := baseImageIndex(index, stages)
+		// A named build context takes the place of a stage of the same name.
+		if _, ok := opts.BuildContexts[stage.BaseName]; ok {
+			baseImageIndex = -1
+		}
 		kanikoStages = append(kanikoStages, config.KanikoStage{
 			Stage:                  stage,
 			BaseImageIndex:         baseImageIndex,
@@ -347,8 +404,21 @@ func unifyArgs(metaArgs []instructions.ArgCommand, buildArgs []string) []string
 	return args
 }
 
+// fromStageName returns the name of the stage a --from option refers to by
+// name or by index.
+func fromStageName(stages []instructions.Stage, from string) (string, error) {
+	index, err := strconv.Atoi(from)
+	if err != nil {
+		return from, nil
+	}
+	if index < 0 || index >= len(stages) {
+		return "", fmt.Errorf("stage %s not found", from)
+	}
+	return stages[index].Name, nil
+}
+
 // skipUnusedStages returns the list of used stages without the unnecessaries ones
-func skipUnusedStages(stages []instructions.Stage, lastStageIndex *int, target string) []instructions.Stage {
+func skipUnusedStages(stages []instructions.Stage, lastStageIndex *int, target string) ([]instructions.Stage, error) {
 	stagesDependencies := make(map[string]bool)
 	var onlyUsedStages []instructions.Stage
 	idx := *lastStageIndex
@@ -361,13 +431,21 @@ func skipUnusedStages(stages []instructions.Stage, lastStageIndex *int, target s
 			for _, c := range s.Commands {
 				switch cmd := c.(type) {
 				case *instructions.CopyCommand:
-					stageName := cmd.From
-					if copyFromIndex, err := strconv.Atoi(stageName); err == nil {
-						stageName = stages[copyFromIndex].Name
+					stageName, err := fromStageName(stages, cmd.From)
+					if err != nil {
+						return nil, err
 					}
 					if !stagesDependencies[stageName] {
 						stagesDependencies[stageName] = true
 					}
+				case *instructions.RunCommand:
+					for _, from := range RunMountsFrom(cmd) {
+						stageName, err := fromStageName(stages, from)
+						if err != nil {
+							return nil, err
+						}
+						stagesDependencies[stageName] = true
+					}
 				}
 			}
 			if i != idx {
@@ -377,7 +455,7 @@ func skipUnusedStages(stages []instructions.Stage, lastStageIndex *int, target s
 	}
 	dependenciesLen := len(stagesDependencies)
 	if target == "" && dependenciesLen == 0 {
-		return stages
+		return stages, nil
 	} else if dependenciesLen > 0 {
 		for i := 0; i < idx; i++ {
 			if stages[i].Name == "" {
@@ -394,5 +472,5 @@ func skipUnusedStages(stages []instructions.Stage, lastStageIndex *int, target s
 		*lastStageIndex = len(onlyUsedStages) - 1
 	}
 
-	return onlyUsedStages
+	return onlyUsedStages, nil
 }
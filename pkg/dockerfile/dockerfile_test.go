@@ -468,6 +468,66 @@ func Test_ResolveStagesArgs(t *testing.T) {
 	}
 }
 
+func Test_PlatformArgs(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     []string
+	}{
+		{
+			platform: "linux/amd64",
+			want:     []string{"TARGETPLATFORM=linux/amd64", "TARGETOS=linux", "TARGETARCH=amd64", "TARGETVARIANT="},
+		},
+		{
+			platform: "linux/arm/v7",
+			want:     []string{"TARGETPLATFORM=linux/arm/v7", "TARGETOS=linux", "TARGETARCH=arm", "TARGETVARIANT=v7"},
+		},
+		{
+			platform: "linux/aarch64",
+			want:     []string{"TARGETPLATFORM=linux/arm64", "TARGETOS=linux", "TARGETARCH=arm64", "TARGETVARIANT="},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.platform, func(t *testing.T) {
+			args, err := PlatformArgs(test.platform)
+			testutil.CheckErrorAndDeepEqual(t, false, err, test.want, args[4:])
+		})
+	}
+
+	_, err := PlatformArgs("linux/amd64/v2/extra")
+	testutil.CheckError(t, true, err)
+}
+
+func Test_MakeKanikoStages_PlatformArgs(t *testing.T) {
+	dockerfile := `
+	FROM --platform=$BUILDPLATFORM golang:1.24 AS build
+	FROM alpine:$TARGETARCH
+	COPY --from=build /out /out
+	`
+	stages, metaArgs, err := Parse([]byte(dockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &config.KanikoOptions{CustomPlatform: "linux/arm64", BuildArgs: []string{"TARGETARCH=custom"}}
+	kanikoStages, err := MakeKanikoStages(opts, stages, metaArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildPlatform, err := PlatformArgs("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.CheckDeepEqual(t, "BUILDPLATFORM="+kanikoStages[0].Platform, buildPlatform[0])
+	testutil.CheckDeepEqual(t, "", kanikoStages[1].Platform)
+	// --build-arg overrides the platform args
+	testutil.CheckDeepEqual(t, "alpine:custom", kanikoStages[1].BaseName)
+
+	args, err := BuildArgsWithPlatform(nil, opts.CustomPlatform)
+	testutil.CheckNoError(t, err)
+	ba := NewBuildArgs(args)
+	ba.AddArg("TARGETARCH", nil)
+	testutil.CheckDeepEqual(t, []string{"TARGETARCH=arm64"}, ba.FilterAllowed(nil))
+}
+
 func Test_SkipingUnusedStages(t *testing.T) {
 	tests := []struct {
 		description                   string
@@ -532,6 +592,33 @@ func Test_SkipingUnusedStages(t *testing.T) {
 				"":          1,
 			},
 		},
+		{
+			description: "dockerfile_with_mountFrom",
+			dockerfile: `
+			FROM alpine:3.11 AS base-dev
+			RUN echo dev > /hi
+			FROM alpine:3.11 AS base-prod
+			RUN echo prod > /hi
+			FROM alpine:3.11
+			RUN --mount=type=bind,from=base-prod,source=/hi,target=/finalhi cat /finalhi
+			`,
+			targets: []string{"base-dev", "base-prod", ""},
+			expectedSourceCodes: map[string][]string{
+				"base-dev":  {"FROM alpine:3.11 AS base-dev"},
+				"base-prod": {"FROM alpine:3.11 AS base-prod"},
+				"":          {"FROM alpine:3.11 AS base-prod", "FROM alpine:3.11"},
+			},
+			expectedTargetIndexBeforeSkip: map[string]int{
+				"base-dev":  0,
+				"base-prod": 1,
+				"":          2,
+			},
+			expectedTargetIndexAfterSkip: map[string]int{
+				"base-dev":  0,
+				"base-prod": 0,
+				"":          1,
+			},
+		},
 		{
 			description: "dockerfile_with_two_copyFrom",
 			dockerfile: `
@@ -645,7 +732,8 @@ func Test_SkipingUnusedStages(t *testing.T) {
 			targetIndex, err := targetStage(stages, target)
 			testutil.CheckError(t, false, err)
 			targetIndexBeforeSkip := targetIndex
-			onlyUsedStages := skipUnusedStages(stages, &targetIndex, target)
+			onlyUsedStages, err := skipUnusedStages(stages, &targetIndex, target)
+			testutil.CheckError(t, false, err)
 			for _, s := range onlyUsedStages {
 				actualSourceCodes[target] = append(actualSourceCodes[target], s.SourceCode)
 			}
@@ -657,3 +745,26 @@ func Test_SkipingUnusedStages(t *testing.T) {
 		}
 	}
 }
+
+func Test_SkipingUnusedStages_StageNotFound(t *testing.T) {
+	tests := []struct {
+		instruction string
+		expectedErr string
+	}{
+		{"COPY --from=7 /hi /hi", "stage 7 not found"},
+		{"RUN --mount=type=bind,from=7,target=/mnt cat /mnt/hi", "stage 7 not found"},
+		{"RUN --mount=type=bind,from=-1,target=/mnt cat /mnt/hi", "stage -1 not found"},
+	}
+	for _, test := range tests {
+		t.Run(test.instruction, func(t *testing.T) {
+			stages, _, err := Parse([]byte("FROM alpine:3.11 AS base\nFROM alpine:3.11\n" + test.instruction + "\n"))
+			testutil.CheckNoError(t, err)
+			targetIndex := 1
+			_, err = skipUnusedStages(stages, &targetIndex, "")
+			testutil.CheckError(t, true, err)
+			if err != nil {
+				testutil.CheckDeepEqual(t, test.expectedErr, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dockerfile
+
+import (
+	"github.com/containerd/containerd/platforms"
+	"github.com/pkg/errors"
+)
+
+// PlatformArgs returns the platform args BuildKit sets automatically, e.g.
+// TARGETARCH, for the target platform and the platform kaniko runs on. An
+// empty target platform is the platform kaniko runs on.
+func PlatformArgs(targetPlatform string) ([]string, error) {
+	build := platforms.Normalize(platforms.DefaultSpec())
+	target := build
+	if targetPlatform != "" {
+		p, err := platforms.Parse(targetPlatform)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing platform %s", targetPlatform)
+		}
+		target = platforms.Normalize(p)
+	}
+	return []string{
+		"BUILDPLATFORM=" + platforms.Format(build),
+		"BUILDOS=" + build.OS,
+		"BUILDARCH=" + build.Architecture,
+		"BUILDVARIANT=" + build.Variant,
+		"TARGETPLATFORM=" + platforms.Format(target),
+		"TARGETOS=" + target.OS,
+		"TARGETARCH=" + target.Architecture,
+		"TARGETVARIANT=" + target.Variant,
+	}, nil
+}
+
+// BuildArgsWithPlatform returns the --build-arg values preceded by the platform
+// args for --custom-platform, so that a --build-arg overrides a platform arg.
+func BuildArgsWithPlatform(buildArgs []string, customPlatform string) ([]string, error) {
+	args, err := PlatformArgs(customPlatform)
+	if err != nil {
+		return nil, err
+	}
+	return append(args, buildArgs...), nil
+}
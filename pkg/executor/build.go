@@ -24,6 +24,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
@@ -53,6 +54,10 @@ import (
 // This is the size of an empty tar in Go
 const emptyTarSize = 1024
 
+// cacheLookupConcurrency is the number of layers looked up in the layer cache
+// at a time.
+const cacheLookupConcurrency = 8
+
 // for testing
 var (
 	initializeConfig = initConfig
@@ -64,6 +69,7 @@ type snapShotter interface {
 	Init() error
 	TakeSnapshotFS() (string, error)
 	TakeSnapshot([]string, bool, bool) (string, error)
+	TakeSnapshotChanges([]string, []string) (string, error)
 }
 
 // stageBuilder contains all fields necessary to build one stage of a Dockerfile
@@ -81,8 +87,10 @@ type stageBuilder struct {
 	digestToCacheKey map[string]string
 	stageIdxToDigest map[string]string
 	snapshotter      snapShotter
+	overlay          *snapshot.Overlay
 	layerCache       cache.LayerCache
 	pushLayerToCache cachePusher
+	cacheReport      StageCacheReport
 }
 
 // newStageBuilder returns a new type stageBuilder which contains all the information required to build the stage
@@ -91,7 +99,11 @@ func newStageBuilder(args *dockerfile.BuildArgs, opts *config.KanikoOptions, sta
 	if err != nil {
 		return nil, err
 	}
+	return newStageBuilderFromImage(sourceImage, args, opts, stage, crossStageDeps, dcm, sid, stageNameToIdx, fileContext)
+}
 
+// newStageBuilderFromImage returns a new type stageBuilder for the stage with the given base image
+func newStageBuilderFromImage(sourceImage v1.Image, args *dockerfile.BuildArgs, opts *config.KanikoOptions, stage config.KanikoStage, crossStageDeps map[int][]string, dcm map[string]string, sid map[string]string, stageNameToIdx map[string]string, fileContext util.FileContext) (*stageBuilder, error) {
 	imageConfig, err := initializeConfig(sourceImage, opts)
 	if err != nil {
 		return nil, err
@@ -131,9 +143,12 @@ func newStageBuilder(args *dockerfile.BuildArgs, opts *config.KanikoOptions, sta
 		layerCache:       newLayerCache(opts),
 		pushLayerToCache: pushLayerToCache,
 	}
+	if opts.SnapshotMode == constants.SnapshotModeOverlay && !opts.SingleSnapshot {
+		s.overlay = snapshot.NewOverlay(config.RootDir, filepath.Join(config.KanikoDir, "overlay"))
+	}
 
 	for _, cmd := range s.stage.Commands {
-		command, err := commands.GetCommand(cmd, fileContext, opts.RunV2, opts.CacheCopyLayers, opts.CacheRunLayers)
+		command, err := commands.GetCommand(cmd, fileContext, opts, stageNameToIdx)
 		if err != nil {
 			return nil, err
 		}
@@ -146,7 +161,11 @@ func newStageBuilder(args *dockerfile.BuildArgs, opts *config.KanikoOptions, sta
 	if args != nil {
 		s.args = args.Clone()
 	} else {
-		s.args = dockerfile.NewBuildArgs(s.opts.BuildArgs)
+		buildArgs, err := dockerfile.BuildArgsWithPlatform(s.opts.BuildArgs, s.opts.CustomPlatform)
+		if err != nil {
+			return nil, err
+		}
+		s.args = dockerfile.NewBuildArgs(buildArgs)
 	}
 	s.args.AddMetaArgs(s.stage.MetaArgs)
 	return s, nil
@@ -183,17 +202,70 @@ func initConfig(img partial.WithConfigFile, opts *config.KanikoOptions) (*v1.Con
 	return imageConfig, nil
 }
 
+// usesCache returns true if the build looks layers up by cache key, in the
+// cache repo or in the --cache-from images.
+func usesCache(opts *config.KanikoOptions) bool {
+	return opts.Cache || len(opts.CacheFrom) > 0
+}
+
 func newLayerCache(opts *config.KanikoOptions) cache.LayerCache {
+	if len(opts.CacheFrom) == 0 {
+		return newRepoLayerCache(opts)
+	}
+	caches := cache.LayerCaches{&cache.ImageCache{
+		Opts:   opts,
+		Remote: remote.RetrieveRemoteImage,
+	}}
+	if opts.Cache {
+		caches = append(caches, newRepoLayerCache(opts))
+	}
+	return caches
+}
+
+func newRepoLayerCache(opts *config.KanikoOptions) cache.LayerCache {
 	if isOCILayout(opts.CacheRepo) {
 		return &cache.LayoutCache{
 			Opts: opts,
 		}
 	}
+	if cache.IsObjectStorage(opts.CacheRepo) {
+		return &cache.ObjectStorageCache{
+			Opts: opts,
+		}
+	}
+	if opts.CacheMode == config.CacheModeIndex {
+		return &cache.IndexCache{
+			Opts: opts,
+		}
+	}
 	return &cache.RegistryCache{
 		Opts: opts,
 	}
 }
 
+// cacheIndexOf returns the cache index of the layer cache, or nil if it
+// doesn't use one.
+func cacheIndexOf(layerCache cache.LayerCache) *cache.IndexCache {
+	return repoCacheOf[*cache.IndexCache](layerCache)
+}
+
+// repoCacheOf returns the cache of type T the layer cache is or includes, or
+// nil if there is none.
+func repoCacheOf[T cache.LayerCache](layerCache cache.LayerCache) T {
+	var zero T
+	switch lc := layerCache.(type) {
+	case T:
+		return lc
+	case cache.LayerCaches:
+		for _, c := range lc {
+			if found, ok := c.(T); ok {
+				return found
+			}
+		}
+	}
+	return zero
+}
+
 func isOCILayout(path string) bool {
 	return strings.HasPrefix(path, "oci:")
 }
@@ -211,24 +283,96 @@ func (s *stageBuilder) populateCompositeKey(command commands.DockerCommand, file
 
 	if command.IsArgsEnvsRequiredInCache() {
 		if len(replacementEnvs) > 0 {
-			compositeKey.AddKey(fmt.Sprintf("|%d", len(replacementEnvs)))
-			compositeKey.AddKey(replacementEnvs...)
+			compositeKey.addInput(CacheKeyInput{Kind: InputEnvCount, Value: fmt.Sprintf("|%d", len(replacementEnvs))})
+			for _, env := range replacementEnvs {
+				name, _, _ := strings.Cut(env, "=")
+				compositeKey.addEnv(name, env)
+			}
 		}
 	}
 
 	// Add the next command to the cache key.
-	compositeKey.AddKey(command.String())
+	compositeKey.addInput(CacheKeyInput{Kind: InputCommand, Value: command.String()})
+
+	// A download is identified by its checksum, so the cached layer is
+	// reused without downloading the file again.
+	if checksumCmd, ok := command.(commands.ChecksumCommand); ok {
+		checksum, err := checksumCmd.Checksum(replacementEnvs)
+		if err != nil {
+			return compositeKey, err
+		}
+		if checksum != "" {
+			compositeKey.addInput(CacheKeyInput{Kind: InputChecksum, Value: checksum})
+		}
+	}
+
+	// Files excluded by the command don't change its outcome, so they are
+	// left out of the key like the ones of .dockerignore.
+	fileContext := s.fileContext
+	if excludingCmd, ok := command.(commands.ExcludePatternsCommand); ok {
+		excludes, err := excludingCmd.ExcludePatterns(replacementEnvs)
+		if err != nil {
+			return compositeKey, err
+		}
+		fileContext = fileContext.WithExcludes(excludes)
+	}
+
+	// Files copied with their parent directories end up at a destination
+	// depending on their path, so the path is part of the key too.
+	parentsCmd, ok := command.(commands.ParentsCommand)
+	keepParents := ok && parentsCmd.KeepsParents()
 
 	for _, f := range files {
-		if err := compositeKey.AddPath(f, s.fileContext); err != nil {
+		if keepParents {
+			compositeKey.addInput(CacheKeyInput{Kind: InputPath, Value: strings.TrimPrefix(f, fileContext.Root)})
+		}
+		if err := compositeKey.AddPath(f, fileContext); err != nil {
 			return compositeKey, err
 		}
 	}
 	return compositeKey, nil
 }
 
+// populateCacheKey populates the composite key with the command like
+// populateCompositeKey does, and returns the key the layer of the command is
+// cached with. The layer of a linked command doesn't depend on the previous
+// layers, so its key leaves out their composite key. The working directory
+// takes its place, as relative destinations still depend on it.
+func (s *stageBuilder) populateCacheKey(command commands.DockerCommand, files []string, compositeKey CompositeCache, cfg *v1.Config) (CompositeCache, string, error) {
+	if isLinked(command) {
+		workdirKey := newCompositeCacheFrom(InputWorkdir, cfg.WorkingDir)
+		workdirKey.files = compositeKey.files
+		linkKey, err := s.populateCompositeKey(command, files, *workdirKey, s.args, cfg.Env)
+		if err != nil {
+			return compositeKey, "", err
+		}
+		ck, err := linkKey.Hash()
+		if err != nil {
+			return compositeKey, "", errors.Wrap(err, "failed to hash composite key")
+		}
+		// The following commands still depend on the linked layer.
+		compositeKey.addInput(CacheKeyInput{Kind: InputLayer, Name: command.String(), Value: ck, Inputs: linkKey.Inputs()})
+		return compositeKey, ck, nil
+	}
+
+	compositeKey, err := s.populateCompositeKey(command, files, compositeKey, s.args, cfg.Env)
+	if err != nil {
+		return compositeKey, "", err
+	}
+	ck, err := compositeKey.Hash()
+	if err != nil {
+		return compositeKey, "", errors.Wrap(err, "failed to hash composite key")
+	}
+	return compositeKey, ck, nil
+}
+
+func isLinked(command commands.DockerCommand) bool {
+	linkCmd, ok := command.(commands.LinkCommand)
+	return ok && linkCmd.Linked()
+}
+
 func (s *stageBuilder) optimize(compositeKey CompositeCache, cfg v1.Config) error {
-	if !s.opts.Cache {
+	if !usesCache(s.opts) {
 		return nil
 	}
 	var buildArgs = s.args.Clone()
@@ -237,69 +381,189 @@ func (s *stageBuilder) optimize(compositeKey CompositeCache, cfg v1.Config) erro
 		s.args = buildArgs
 	}()
 
-	stopCache := false
-	// Possibly replace commands with their cached implementations.
+	// Compute the cache keys of all the commands first.
 	// We walk through all the commands, running any commands that only operate on metadata.
 	// We throw the metadata away after, but we need it to properly track command dependencies
 	// for things like COPY ${FOO} or RUN commands that use environment variables.
+	lookups, err := s.cacheKeys(&compositeKey, &cfg, false)
+	if err != nil {
+		return err
+	}
+
+	// Then look the layers up, and possibly replace commands with their
+	// cached implementations.
+	s.lookupLayers(lookups)
+	for _, l := range lookups {
+		if !l.hit {
+			continue
+		}
+		command := s.cmds[l.index]
+		if cacheCmd := command.CacheCommand(l.img); cacheCmd != nil {
+			logrus.Infof("Using caching version of cmd: %s", command.String())
+			s.cmds[l.index] = cacheCmd
+		}
+	}
+	return nil
+}
+
+// cacheLookup is the cache key of a command and the lookup of its layer in
+// the layer cache.
+type cacheLookup struct {
+	index int
+	ck    string
+	key   string
+	// unknown is why the cache key is unknown when predicting it.
+	unknown string
+	linked  bool
+	// lookup is true if the layer of the command is looked up.
+	lookup bool
+	img    v1.Image
+	err    error
+	// hit is true if the layer is used by the build.
+	hit bool
+}
+
+// cacheKeys computes the cache keys of the commands, executing the commands
+// which only operate on metadata on cfg. When predicting them for a build
+// plan, the files of other stages don't exist yet: the keys of the commands
+// from the first one using them on are unknown instead of an error, like
+// the keys of all of them if compositeKey is nil.
+func (s *stageBuilder) cacheKeys(compositeKey *CompositeCache, cfg *v1.Config, predict bool) ([]cacheLookup, error) {
+	unknown := ""
+	if compositeKey == nil {
+		unknown = "the base stage uses files of another stage or image"
+	}
+	s.finalCacheKey = ""
+	var lookups []cacheLookup
 	for i, command := range s.cmds {
 		if command == nil {
 			continue
 		}
-		files, err := command.FilesUsedFromContext(&cfg, s.args)
-		if err != nil {
-			return errors.Wrap(err, "failed to get files used from context")
+		l := cacheLookup{index: i, linked: isLinked(command)}
+		if unknown == "" {
+			ck, err := s.cacheKey(command, compositeKey, cfg, predict)
+			switch {
+			case predict && errors.Is(err, errStageFiles):
+				unknown = "the command uses files of another stage or image"
+			case predict && err != nil:
+				unknown = err.Error()
+			case err != nil:
+				return nil, err
+			default:
+				l.ck, l.key = ck, compositeKey.Key()
+				logrus.Debugf("Optimize: composite key for command %v %v", command.String(), compositeKey)
+				logrus.Debugf("Optimize: cache key for command %v %v", command.String(), ck)
+			}
 		}
+		l.unknown = unknown
+		l.lookup = unknown == "" && usesCache(s.opts) && command.ShouldCacheOutput()
+		s.finalCacheKey = l.ck
+		lookups = append(lookups, l)
 
-		compositeKey, err = s.populateCompositeKey(command, files, compositeKey, s.args, cfg.Env)
-		if err != nil {
-			return err
+		// Mutate the config for any commands that require it.
+		if command.MetadataOnly() {
+			if err := command.ExecuteCommand(cfg, s.args); err != nil {
+				return nil, err
+			}
 		}
+	}
+	return lookups, nil
+}
 
-		logrus.Debugf("Optimize: composite key for command %v %v", command.String(), compositeKey)
-		ck, err := compositeKey.Hash()
-		if err != nil {
-			return errors.Wrap(err, "failed to hash composite key")
-		}
+// cacheKey adds the command to compositeKey and returns its cache key.
+func (s *stageBuilder) cacheKey(command commands.DockerCommand, compositeKey *CompositeCache, cfg *v1.Config, predict bool) (string, error) {
+	var files []string
+	var err error
+	if predict {
+		files, err = s.filesUsedFromContext(command, cfg)
+	} else {
+		files, err = command.FilesUsedFromContext(cfg, s.args)
+		err = errors.Wrap(err, "failed to get files used from context")
+	}
+	if err != nil {
+		return "", err
+	}
+	key, ck, err := s.populateCacheKey(command, files, *compositeKey, cfg)
+	if err != nil {
+		return "", err
+	}
+	*compositeKey = key
+	return ck, nil
+}
 
-		logrus.Debugf("Optimize: cache key for command %v %v", command.String(), ck)
-		s.finalCacheKey = ck
+// lookupLayers looks the layers of the commands up, and sets which ones the
+// build uses: the ones found up to the first miss, and linked ones after it
+// since they don't depend on the previous layers.
+func (s *stageBuilder) lookupLayers(lookups []cacheLookup) {
+	var toRetrieve []*cacheLookup
+	for i := range lookups {
+		if lookups[i].lookup {
+			toRetrieve = append(toRetrieve, &lookups[i])
+		}
+	}
+	s.retrieveLayers(toRetrieve)
 
-		if command.ShouldCacheOutput() && !stopCache {
-			img, err := s.layerCache.RetrieveLayer(ck)
+	stopCache := false
+	for _, l := range toRetrieve {
+		if stopCache && !l.linked {
+			continue
+		}
+		if l.err != nil {
+			command := s.cmds[l.index]
+			logrus.Debugf("Failed to retrieve layer: %s", l.err)
+			logrus.Infof("No cached layer found for cmd %s", command.String())
+			logrus.Debugf("Key missing was: %s", l.key)
+			stopCache = true
+			continue
+		}
+		l.hit = true
+	}
+}
 
-			if err != nil {
-				logrus.Debugf("Failed to retrieve layer: %s", err)
-				logrus.Infof("No cached layer found for cmd %s", command.String())
-				logrus.Debugf("Key missing was: %s", compositeKey.Key())
-				stopCache = true
-				continue
+var errLookupSkipped = errors.New("not looked up after a cache miss")
+
+// retrieveLayers looks the layers up in the layer cache, with at most
+// cacheLookupConcurrency lookups at a time. The layers after a miss aren't
+// used, so they aren't looked up once it is found, except linked ones.
+func (s *stageBuilder) retrieveLayers(lookups []*cacheLookup) {
+	var firstMiss atomic.Int64
+	firstMiss.Store(int64(len(lookups)))
+	var g errgroup.Group
+	g.SetLimit(cacheLookupConcurrency)
+	for i, l := range lookups {
+		g.Go(func() error {
+			if int64(i) > firstMiss.Load() && !l.linked {
+				l.err = errLookupSkipped
+				return nil
 			}
-
-			if cacheCmd := command.CacheCommand(img); cacheCmd != nil {
-				logrus.Infof("Using caching version of cmd: %s", command.String())
-				s.cmds[i] = cacheCmd
+			l.img, l.err = s.layerCache.RetrieveLayer(l.ck)
+			if l.err == nil {
+				return nil
 			}
-		}
-
-		// Mutate the config for any commands that require it.
-		if command.MetadataOnly() {
-			if err := command.ExecuteCommand(&cfg, s.args); err != nil {
-				return err
+			for {
+				miss := firstMiss.Load()
+				if int64(i) >= miss || firstMiss.CompareAndSwap(miss, int64(i)) {
+					return nil
+				}
 			}
-		}
+		})
 	}
-	return nil
+	// A layer which can't be retrieved is only a cache miss.
+	_ = g.Wait()
 }
 
 func (s *stageBuilder) build() error {
 	// Set the initial cache key to be the base image digest, the build args and the SrcContext.
 	var compositeKey *CompositeCache
 	if cacheKey, ok := s.digestToCacheKey[s.baseImageDigest]; ok {
-		compositeKey = NewCompositeCache(cacheKey)
+		compositeKey = newCompositeCacheFrom(InputStage, cacheKey)
 	} else {
-		compositeKey = NewCompositeCache(s.baseImageDigest)
+		compositeKey = newCompositeCacheFrom(InputBase, s.baseImageDigest)
 	}
+	// The hashes of the files of directories are only kept for the report.
+	compositeKey.files = s.opts.CacheReport != ""
+
+	s.cacheReport = StageCacheReport{Name: s.stage.Name, Base: compositeKey.Inputs()}
 
 	// Apply optimizations to the instructions.
 	if err := s.optimize(*compositeKey, s.cf.Config); err != nil {
@@ -361,11 +625,15 @@ func (s *stageBuilder) build() error {
 			return errors.Wrap(err, "failed to get files used from context")
 		}
 
-		if s.opts.Cache {
-			*compositeKey, err = s.populateCompositeKey(command, files, *compositeKey, s.args, s.cf.Config.Env)
-			if err != nil && s.opts.Cache {
+		var ck string
+		var inputs []CacheKeyInput
+		if usesCache(s.opts) {
+			n := len(compositeKey.inputs)
+			*compositeKey, ck, err = s.populateCacheKey(command, files, *compositeKey, &s.cf.Config)
+			if err != nil {
 				return err
 			}
+			inputs = compositeKey.Inputs()[n:]
 		}
 
 		logrus.Info(command.String())
@@ -378,7 +646,9 @@ func (s *stageBuilder) build() error {
 				return false
 			}
 		}()
-		if !initSnapshotTaken && !isCacheCommand && !command.ProvidesFilesToSnapshot() {
+		s.reportCommand(command, ck, inputs, isCacheCommand)
+		merged := s.mountOverlay(command, isCacheCommand)
+		if !initSnapshotTaken && merged == "" && !isCacheCommand && !command.ProvidesFilesToSnapshot() {
 			// Take initial snapshot if command does not expect to return
 			// a list of files.
 			if err := s.initSnapshotWithTimings(); err != nil {
@@ -387,7 +657,13 @@ func (s *stageBuilder) build() error {
 			initSnapshotTaken = true
 		}
 
-		if err := command.ExecuteCommand(&s.cf.Config, s.args); err != nil {
+		var changed, deleted []string
+		if merged != "" {
+			changed, deleted, err = s.executeInOverlay(command.(commands.RootfsCommand), merged)
+		} else {
+			err = command.ExecuteCommand(&s.cf.Config, s.args)
+		}
+		if err != nil {
 			return errors.Wrap(err, "failed to execute command")
 		}
 		files = command.FilesToSnapshot()
@@ -400,22 +676,22 @@ func (s *stageBuilder) build() error {
 		if isCacheCommand {
 			v := command.(commands.Cached)
 			layer := v.Layer()
-			if err := s.saveLayerToImage(layer, command.String()); err != nil {
+			if err := s.saveLayerToImage(layer, command.String(), ck); err != nil {
 				return errors.Wrap(err, "failed to save layer")
 			}
 		} else {
-			tarPath, err := s.takeSnapshot(files, command.ShouldDetectDeletedFiles())
+			var tarPath string
+			if merged != "" {
+				tarPath, err = s.takeOverlaySnapshot(changed, deleted)
+			} else {
+				tarPath, err = s.takeSnapshot(files, command.ShouldDetectDeletedFiles())
+			}
 			if err != nil {
 				return errors.Wrap(err, "failed to take snapshot")
 			}
 
 			if s.opts.Cache {
 				logrus.Debugf("Build: composite key for command %v %v", command.String(), compositeKey)
-				ck, err := compositeKey.Hash()
-				if err != nil {
-					return errors.Wrap(err, "failed to hash composite key")
-				}
-
 				logrus.Debugf("Build: cache key for command %v %v", command.String(), ck)
 
 				// Push layer to cache (in parallel) now along with new config file
@@ -425,7 +701,7 @@ func (s *stageBuilder) build() error {
 					})
 				}
 			}
-			if err := s.saveSnapshotToImage(command.String(), tarPath); err != nil {
+			if err := s.saveSnapshotToImage(command.String(), ck, tarPath); err != nil {
 				return errors.Wrap(err, "failed to save snapshot to image")
 			}
 		}
@@ -454,6 +730,47 @@ func (s *stageBuilder) takeSnapshot(files []string, shdDelete bool) (string, err
 	return snapshot, err
 }
 
+// mountOverlay mounts the overlay the command is executed in with
+// --snapshot-mode=overlay, and returns where it is mounted. It returns an
+// empty string if the command is executed as usual.
+func (s *stageBuilder) mountOverlay(command commands.DockerCommand, isCacheCommand bool) string {
+	if _, ok := command.(commands.RootfsCommand); !ok || s.overlay == nil || isCacheCommand {
+		return ""
+	}
+	merged, err := s.overlay.Mount()
+	if err != nil {
+		logrus.Warnf("Unable to use overlayfs, falling back to snapshotting the full filesystem: %s", err)
+		s.overlay = nil
+		return ""
+	}
+	return merged
+}
+
+// executeInOverlay executes the command in the overlay mounted at merged and
+// moves its changes to the root directory. It returns the changed and the
+// deleted files.
+func (s *stageBuilder) executeInOverlay(command commands.RootfsCommand, merged string) ([]string, []string, error) {
+	command.SetRootfs(merged)
+	err := command.ExecuteCommand(&s.cf.Config, s.args)
+	command.SetRootfs("")
+	if uerr := s.overlay.Unmount(); err == nil {
+		err = uerr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.overlay.Apply()
+}
+
+func (s *stageBuilder) takeOverlaySnapshot(changed, deleted []string) (string, error) {
+	t := timing.Start("Snapshotting FS")
+	// Volumes are very weird. They get snapshotted in the next command.
+	changed = append(changed, util.Volumes()...)
+	snapshot, err := s.snapshotter.TakeSnapshotChanges(changed, deleted)
+	timing.DefaultRun.Stop(t)
+	return snapshot, err
+}
+
 func (s *stageBuilder) shouldTakeSnapshot(index int, isMetadatCmd bool) bool {
 	isLastCommand := index == len(s.cmds)-1
 
@@ -463,7 +780,7 @@ func (s *stageBuilder) shouldTakeSnapshot(index int, isMetadatCmd bool) bool {
 	}
 
 	// Always take snapshots if we're using the cache.
-	if s.opts.Cache {
+	if usesCache(s.opts) {
 		return true
 	}
 
@@ -471,7 +788,7 @@ func (s *stageBuilder) shouldTakeSnapshot(index int, isMetadatCmd bool) bool {
 	return !isMetadatCmd
 }
 
-func (s *stageBuilder) saveSnapshotToImage(createdBy string, tarPath string) error {
+func (s *stageBuilder) saveSnapshotToImage(createdBy, ck string, tarPath string) error {
 	layer, err := s.saveSnapshotToLayer(tarPath)
 	if err != nil {
 		return err
@@ -481,7 +798,7 @@ func (s *stageBuilder) saveSnapshotToImage(createdBy string, tarPath string) err
 		return nil
 	}
 
-	return s.saveLayerToImage(layer, createdBy)
+	return s.saveLayerToImage(layer, createdBy, ck)
 }
 
 func (s *stageBuilder) saveSnapshotToLayer(tarPath string) (v1.Layer, error) {
@@ -607,19 +924,25 @@ func (s *stageBuilder) convertLayerMediaType(layer v1.Layer) (v1.Layer, error) {
 	return layer, nil
 }
 
-func (s *stageBuilder) saveLayerToImage(layer v1.Layer, createdBy string) error {
+// saveLayerToImage appends the layer to the image. The cache key ck of the
+// command which created it is recorded in its history, for --cache-from.
+func (s *stageBuilder) saveLayerToImage(layer v1.Layer, createdBy, ck string) error {
 	var err error
 	layer, err = s.convertLayerMediaType(layer)
 	if err != nil {
 		return err
 	}
+	history := v1.History{
+		Author:    constants.Author,
+		CreatedBy: createdBy,
+	}
+	if ck != "" {
+		history.Comment = cache.CacheKeyComment(ck)
+	}
 	s.image, err = mutate.Append(s.image,
 		mutate.Addendum{
-			Layer: layer,
-			History: v1.History{
-				Author:    constants.Author,
-				CreatedBy: createdBy,
-			},
+			Layer:   layer,
+			History: history,
 		},
 	)
 	return err
@@ -628,8 +951,12 @@ func (s *stageBuilder) saveLayerToImage(layer v1.Layer, createdBy string) error
 func CalculateDependencies(stages []config.KanikoStage, opts *config.KanikoOptions, stageNameToIdx map[string]string) (map[int][]string, error) {
 	images := []v1.Image{}
 	depGraph := map[int][]string{}
+	buildArgs, err := dockerfile.BuildArgsWithPlatform(opts.BuildArgs, opts.CustomPlatform)
+	if err != nil {
+		return nil, err
+	}
 	for _, s := range stages {
-		ba := dockerfile.NewBuildArgs(opts.BuildArgs)
+		ba := dockerfile.NewBuildArgs(buildArgs)
 		ba.AddMetaArgs(s.MetaArgs)
 		var image v1.Image
 		var err error
@@ -665,6 +992,25 @@ func CalculateDependencies(stages []config.KanikoStage, opts *config.KanikoOptio
 					}
 					depGraph[i] = append(depGraph[i], resolved...)
 				}
+			case *instructions.RunCommand:
+				mounts, err := dockerfile.ExpandRunMounts(cmd, ba.ReplacementEnvs(cfg.Config.Env))
+				if err != nil {
+					return nil, err
+				}
+				for _, m := range mounts {
+					if m.Type != instructions.MountTypeBind || m.From == "" {
+						continue
+					}
+					from := m.From
+					if idx, ok := stageNameToIdx[strings.ToLower(from)]; ok {
+						from = idx
+					}
+					i, err := strconv.Atoi(from)
+					if err != nil {
+						continue
+					}
+					depGraph[i] = append(depGraph[i], filepath.Join("/", m.Source))
+				}
 			case *instructions.EnvCommand:
 				if err := util.UpdateConfigEnv(cmd.Env, &cfg.Config, ba.ReplacementEnvs(cfg.Config.Env)); err != nil {
 					return nil, err
@@ -703,12 +1049,13 @@ func DoBuild(opts *config.KanikoOptions) (v1.Image, error) {
 	if err != nil {
 		return nil, err
 	}
-	stageNameToIdx := ResolveCrossStageInstructions(kanikoStages)
+	stageNameToIdx := ResolveCrossStageInstructions(kanikoStages, opts.BuildContexts)
 
 	fileContext, err := util.NewFileContextFromDockerfile(opts.DockerfilePath, opts.SrcContext)
 	if err != nil {
 		return nil, err
 	}
+	fileContext.NamedContexts = namedContextDirs(opts.BuildContexts)
 
 	// Some stages may refer to other random images, not previous stages
 	if err := fetchExtraStages(kanikoStages, opts); err != nil {
@@ -721,6 +1068,12 @@ func DoBuild(opts *config.KanikoOptions) (v1.Image, error) {
 	logrus.Infof("Built cross stage deps: %v", crossStageDependencies)
 
 	var args *dockerfile.BuildArgs
+	cacheReport := &CacheReport{}
+	// The stages share the layer cache, so that the cache index and the
+	// --cache-from images are only fetched once.
+	layerCache := newLayerCache(opts)
+	cacheIndex := cacheIndexOf(layerCache)
+	objectCache := repoCacheOf[*cache.ObjectStorageCache](layerCache)
 
 	for index, stage := range kanikoStages {
 		sb, err := newStageBuilder(
@@ -737,10 +1090,19 @@ func DoBuild(opts *config.KanikoOptions) (v1.Image, error) {
 		if err != nil {
 			return nil, err
 		}
+		sb.layerCache = layerCache
+		switch {
+		case cacheIndex != nil:
+			sb.pushLayerToCache = pushLayerToCacheIndex(cacheIndex)
+		case objectCache != nil:
+			sb.pushLayerToCache = pushLayerToObjectStorage(objectCache)
+		}
 		args = sb.args
 		if err := sb.build(); err != nil {
 			return nil, errors.Wrap(err, "error building stage")
 		}
+		sb.cacheReport.Index = dockerfileIndex(stages, stage)
+		cacheReport.Stages = append(cacheReport.Stages, sb.cacheReport)
 
 		reviewConfig(stage, &sb.cf.Config)
 
@@ -753,12 +1115,21 @@ func DoBuild(opts *config.KanikoOptions) (v1.Image, error) {
 		if err != nil {
 			return nil, err
 		}
-		if opts.CustomPlatform == "" {
+		platform := opts.CustomPlatform
+		if stage.Platform != "" {
+			platform = stage.Platform
+		}
+		if platform == "" {
 			configFile.OS = runtime.GOOS
 			configFile.Architecture = runtime.GOARCH
 		} else {
-			configFile.OS = strings.Split(opts.CustomPlatform, "/")[0]
-			configFile.Architecture = strings.Split(opts.CustomPlatform, "/")[1]
+			parts := strings.Split(platform, "/")
+			configFile.OS = parts[0]
+			configFile.Architecture = parts[1]
+			// The variant tells apart e.g. linux/arm/v6 and linux/arm/v7 in an image index.
+			if len(parts) > 2 {
+				configFile.Variant = parts[2]
+			}
 		}
 		sourceImage, err = mutate.ConfigFile(sourceImage, configFile)
 		if err != nil {
@@ -786,6 +1157,16 @@ func DoBuild(opts *config.KanikoOptions) (v1.Image, error) {
 					return nil, err
 				}
 			}
+			if cacheIndex != nil && opts.Cache && !opts.NoPushCache {
+				if err := cacheIndex.Push(); err != nil {
+					logrus.Warnf("Error pushing cache index: %s", err)
+				}
+			}
+			if opts.CacheReport != "" {
+				if err := WriteCacheReport(opts.CacheReport, cacheReport); err != nil {
+					return nil, err
+				}
+			}
 			if opts.Cleanup {
 				if err = util.DeleteFilesystem(); err != nil {
 					return nil, err
@@ -907,34 +1288,54 @@ func fetchExtraStages(stages []config.KanikoStage, opts *config.KanikoOptions) e
 
 	for stageIndex, s := range stages {
 		for _, cmd := range s.Commands {
-			c, ok := cmd.(*instructions.CopyCommand)
-			if !ok || c.From == "" {
-				continue
+			var froms []string
+			switch c := cmd.(type) {
+			case *instructions.CopyCommand:
+				if c.From != "" {
+					froms = append(froms, c.From)
+				}
+			case *instructions.RunCommand:
+				froms = dockerfile.RunMountsFrom(c)
 			}
 
-			// FROMs at this point are guaranteed to be either an integer referring to a previous stage,
-			// the name of a previous stage, or a name of a remote image.
+			for _, from := range froms {
+				// FROMs at this point are guaranteed to be either an integer referring to a previous stage,
+				// the name of a previous stage, or a name of a remote image.
 
-			// If it is an integer stage index, validate that it is actually a previous index
-			if fromIndex, err := strconv.Atoi(c.From); err == nil && stageIndex > fromIndex && fromIndex >= 0 {
-				continue
-			}
-			// Check if the name is the alias of a previous stage
-			if fromPreviousStage(c, names) {
-				continue
-			}
+				// If it is an integer stage index, validate that it is actually a previous index
+				if fromIndex, err := strconv.Atoi(from); err == nil && stageIndex > fromIndex && fromIndex >= 0 {
+					continue
+				}
+				// Check if the name is a named build context, which takes the
+				// place of a stage of the same name. Directories are used in place.
+				source, isContext := opts.BuildContexts[from]
+				if isContext && strings.HasPrefix(source, constants.LocalDirBuildContextPrefix) {
+					continue
+				}
+				// Check if the name is the alias of a previous stage
+				if !isContext && fromPreviousStage(from, names) {
+					continue
+				}
 
-			// This must be an image name, fetch it.
-			logrus.Debugf("Found extra base image stage %s", c.From)
-			sourceImage, err := remote.RetrieveRemoteImage(c.From, opts.RegistryOptions, opts.CustomPlatform)
-			if err != nil {
-				return err
-			}
-			if err := saveStageAsTarball(c.From, sourceImage); err != nil {
-				return err
-			}
-			if err := extractImageToDependencyDir(c.From, sourceImage); err != nil {
-				return err
+				// This must be an image name, fetch it.
+				var sourceImage v1.Image
+				var err error
+				if isContext {
+					logrus.Debugf("Found extra build context stage %s", from)
+					sourceImage, err = image_util.RetrieveBuildContextImage(source, opts)
+				} else {
+					logrus.Debugf("Found extra base image stage %s", from)
+					sourceImage, err = remote.RetrieveRemoteImage(from, opts.RegistryOptions, opts.CustomPlatform)
+				}
+				if err != nil {
+					return err
+				}
+				if err := saveStageAsTarball(from, sourceImage); err != nil {
+					return err
+				}
+				if err := extractImageToDependencyDir(from, sourceImage); err != nil {
+					return err
+				}
 			}
 		}
 		// Store the name of the current stage in the list with names, if applicable.
@@ -945,9 +1346,21 @@ func fetchExtraStages(stages []config.KanikoStage, opts *config.KanikoOptions) e
 	return nil
 }
 
-func fromPreviousStage(copyCommand *instructions.CopyCommand, previousStageNames []string) bool {
+// namedContextDirs returns the directories of the named build contexts which
+// aren't images.
+func namedContextDirs(buildContexts map[string]string) map[string]string {
+	dirs := make(map[string]string)
+	for name, source := range buildContexts {
+		if dir, ok := strings.CutPrefix(source, constants.LocalDirBuildContextPrefix); ok {
+			dirs[name] = dir
+		}
+	}
+	return dirs
+}
+
+func fromPreviousStage(from string, previousStageNames []string) bool {
 	for _, previousStageName := range previousStageNames {
-		if previousStageName == copyCommand.From {
+		if strings.EqualFold(previousStageName, from) {
 			return true
 		}
 	}
@@ -990,6 +1403,9 @@ func getHasher(snapshotMode string) (func(string) (string, error), error) {
 		return util.Hasher(), nil
 	case constants.SnapshotModeRedo:
 		return util.RedoHasher(), nil
+	case constants.SnapshotModeOverlay:
+		logrus.Info("Changes of RUN commands will be detected with overlayfs when possible")
+		return util.Hasher(), nil
 	default:
 		return nil, fmt.Errorf("%s is not a valid snapshot mode", snapshotMode)
 	}
@@ -1032,11 +1448,12 @@ func reviewConfig(stage config.KanikoStage, config *v1.Config) {
 
 // iterates over a list of KanikoStage and resolves instructions referring to earlier stages
 // returns a mapping of stage name to stage id, f.e - ["first": "0", "second": "1", "target": "2"]
-func ResolveCrossStageInstructions(stages []config.KanikoStage) map[string]string {
+func ResolveCrossStageInstructions(stages []config.KanikoStage, buildContexts map[string]string) map[string]string {
 	nameToIndex := make(map[string]string)
 	for i, stage := range stages {
 		index := strconv.Itoa(i)
-		if stage.Name != "" {
+		// A named build context takes the place of a stage of the same name.
+		if _, ok := buildContexts[stage.Name]; stage.Name != "" && !ok {
 			nameToIndex[stage.Name] = index
 		}
 		dockerfile.ResolveCrossStageCommands(stage.Commands, nameToIndex)
@@ -39,6 +39,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/moby/buildkit/frontend/dockerfile/instructions"
 )
@@ -360,6 +361,23 @@ COPY --from=second /bar /bat
 				1: {"/bar"},
 			},
 		},
+		{
+			name: "run mounts",
+			args: args{
+				dockerfile: `
+FROM scratch as builder
+FROM scratch
+ARG out=/out
+RUN --mount=type=bind,from=builder,source=$out,target=/src make install
+RUN --mount=type=bind,from=0,target=/src make install
+RUN --mount=type=bind,source=.,target=/src make install
+RUN --mount=type=cache,target=/root/.cache make install
+`,
+			},
+			want: map[int][]string{
+				0: {"/out", "/"},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -384,7 +402,7 @@ COPY --from=second /bar /bat
 			if err != nil {
 				t.Errorf("Failed to parse stages to Kaniko Stages: %s", err)
 			}
-			stageNameToIdx := ResolveCrossStageInstructions(kanikoStages)
+			stageNameToIdx := ResolveCrossStageInstructions(kanikoStages, nil)
 
 			got, err := CalculateDependencies(kanikoStages, opts, stageNameToIdx)
 			if err != nil {
@@ -588,6 +606,71 @@ func Test_newLayerCache_layoutCache(t *testing.T) {
 	})
 }
 
+func Test_newLayerCache_indexCache(t *testing.T) {
+	t.Run("cache mode index uses the cache index", func(t *testing.T) {
+		layerCache := newLayerCache(&config.KanikoOptions{CacheRepo: "some-cache-repo", CacheMode: config.CacheModeIndex})
+		if _, ok := layerCache.(*cache.IndexCache); !ok {
+			t.Errorf("expected layer cache to be an index cache; got %T", layerCache)
+		}
+		if cacheIndexOf(layerCache) == nil {
+			t.Error("expected the cache index of the layer cache")
+		}
+	})
+	t.Run("cache index behind cache-from images", func(t *testing.T) {
+		layerCache := newLayerCache(&config.KanikoOptions{
+			CacheRepo: "some-cache-repo",
+			CacheMode: config.CacheModeIndex,
+			CacheFrom: []string{"some-image"},
+			Cache:     true,
+		})
+		if cacheIndexOf(layerCache) == nil {
+			t.Error("expected the cache index of the layer cache")
+		}
+	})
+}
+
+func Test_newLayerCache_cacheFrom(t *testing.T) {
+	t.Run("cache-from images are looked up before the cache repo", func(t *testing.T) {
+		layerCache := newLayerCache(&config.KanikoOptions{
+			CacheRepo: "some-cache-repo",
+			CacheFrom: []string{"some-image"},
+			Cache:     true,
+		})
+		caches, ok := layerCache.(cache.LayerCaches)
+		if !ok || len(caches) != 2 {
+			t.Fatalf("expected layer cache to be two layer caches; got %T", layerCache)
+		}
+		if _, ok := caches[0].(*cache.ImageCache); !ok {
+			t.Errorf("expected the first layer cache to be an image cache; got %T", caches[0])
+		}
+		if _, ok := caches[1].(*cache.RegistryCache); !ok {
+			t.Errorf("expected the second layer cache to be a registry cache; got %T", caches[1])
+		}
+	})
+	t.Run("cache repo is not used without --cache", func(t *testing.T) {
+		layerCache := newLayerCache(&config.KanikoOptions{CacheFrom: []string{"some-image"}})
+		caches, ok := layerCache.(cache.LayerCaches)
+		if !ok || len(caches) != 1 {
+			t.Fatalf("expected layer cache to be a single layer cache; got %v", layerCache)
+		}
+	})
+}
+
+func Test_stageBuilder_saveLayerToImage_CacheKey(t *testing.T) {
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb := &stageBuilder{image: empty.Image, opts: &config.KanikoOptions{}}
+	testutil.CheckNoError(t, sb.saveLayerToImage(layer, "RUN make", "key"))
+	testutil.CheckNoError(t, sb.saveLayerToImage(layer, "RUN make install", ""))
+
+	cf, err := sb.image.ConfigFile()
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, cache.CacheKeyComment("key"), cf.History[0].Comment)
+	testutil.CheckDeepEqual(t, "", cf.History[1].Comment)
+}
+
 func Test_stageBuilder_optimize(t *testing.T) {
 	testCases := []struct {
 		opts     *config.KanikoOptions
@@ -653,6 +736,72 @@ func newStageContext(command string, args map[string]string, env []string) stage
 	return stageContext{MockDockerCommand{command: command}, dockerArgs, env}
 }
 
+func Test_stageBuilder_optimize_StopsAtFirstMiss(t *testing.T) {
+	cf := &v1.ConfigFile{}
+	sb := &stageBuilder{opts: &config.KanikoOptions{Cache: true}, cf: cf, args: dockerfile.NewBuildArgs(nil)}
+	var cmds []commands.DockerCommand
+	for _, run := range []string{"RUN a", "RUN b", "RUN c", "RUN d"} {
+		cmds = append(cmds, MockDockerCommand{command: run, cacheCommand: MockCachedDockerCommand{}})
+	}
+
+	var keys []string
+	compositeKey := *NewCompositeCache("base")
+	for _, command := range cmds {
+		var ck string
+		var err error
+		compositeKey, ck, err = sb.populateCacheKey(command, nil, compositeKey, &cf.Config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, ck)
+	}
+
+	// The layers after the first miss aren't used, even if they are found.
+	lc := &fakeLayerCache{keySequence: []string{keys[0], keys[2], keys[3]}}
+	sb.layerCache = lc
+	sb.cmds = append([]commands.DockerCommand{}, cmds...)
+	if err := sb.optimize(*NewCompositeCache("base"), cf.Config); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sb.cmds[0].(MockCachedDockerCommand); !ok {
+		t.Errorf("expected the first command to be cached, got %T", sb.cmds[0])
+	}
+	for i, command := range sb.cmds[1:] {
+		if _, ok := command.(MockDockerCommand); !ok {
+			t.Errorf("expected command %d not to be cached, got %T", i+1, command)
+		}
+	}
+}
+
+func Test_stageBuilder_optimize_SkipsLookupsAfterMiss(t *testing.T) {
+	cf := &v1.ConfigFile{}
+	sb := &stageBuilder{opts: &config.KanikoOptions{Cache: true}, cf: cf, args: dockerfile.NewBuildArgs(nil)}
+	var cmds []commands.DockerCommand
+	for i := 0; i < 10*cacheLookupConcurrency; i++ {
+		cmds = append(cmds, MockDockerCommand{command: fmt.Sprintf("RUN %d", i), cacheCommand: MockCachedDockerCommand{}})
+	}
+	firstKey := *NewCompositeCache("base")
+	_, ck, err := sb.populateCacheKey(cmds[0], nil, firstKey, &cf.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the lookups started before the miss of the second layer was found
+	// are done: the hit, and at most one per concurrent lookup.
+	lc := &fakeLayerCache{keySequence: []string{ck}}
+	sb.layerCache = lc
+	sb.cmds = cmds
+	if err := sb.optimize(*NewCompositeCache("base"), cf.Config); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(lc.receivedKeys); n < 2 || n > cacheLookupConcurrency+1 {
+		t.Errorf("expected between 2 and %d lookups, got %d", cacheLookupConcurrency+1, n)
+	}
+	if _, ok := sb.cmds[0].(MockCachedDockerCommand); !ok {
+		t.Errorf("expected the first command to be cached, got %T", sb.cmds[0])
+	}
+}
+
 func Test_stageBuilder_populateCompositeKey(t *testing.T) {
 	type testcase struct {
 		description string
@@ -864,6 +1013,76 @@ func Test_stageBuilder_populateCompositeKey(t *testing.T) {
 			),
 			shdEqual: true,
 		},
+		{
+			description: "cache key for same [ADD] with different checksums",
+			cmd1: newStageContext(
+				"ADD --checksum=$SUM https://example.com/file /file",
+				map[string]string{"SUM": "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+				[]string{},
+			),
+			cmd2: newStageContext(
+				"ADD --checksum=$SUM https://example.com/file /file",
+				map[string]string{"SUM": "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},
+				[]string{},
+			),
+			shdEqual: false,
+		},
+		{
+			description: "cache key for same [ADD] with same checksum",
+			cmd1: newStageContext(
+				"ADD --checksum=$SUM https://example.com/file /file",
+				map[string]string{"SUM": "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+				[]string{"ENV=1"},
+			),
+			cmd2: newStageContext(
+				"ADD --checksum=$SUM https://example.com/file /file",
+				map[string]string{"SUM": "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+				[]string{"ENV=2"},
+			),
+			shdEqual: true,
+		},
+		{
+			description: "cache key for [RUN] heredocs with different content",
+			cmd1: newStageContext(
+				"RUN <<EOF\necho foo > test\nEOF",
+				map[string]string{},
+				[]string{},
+			),
+			cmd2: newStageContext(
+				"RUN <<EOF\necho bar > test\nEOF",
+				map[string]string{},
+				[]string{},
+			),
+			shdEqual: false,
+		},
+		{
+			description: "cache key for same [COPY] heredoc with different env",
+			cmd1: newStageContext(
+				"COPY <<EOF /test\n$ENV\nEOF",
+				map[string]string{},
+				[]string{"ENV=1"},
+			),
+			cmd2: newStageContext(
+				"COPY <<EOF /test\n$ENV\nEOF",
+				map[string]string{},
+				[]string{"ENV=2"},
+			),
+			shdEqual: false,
+		},
+		{
+			description: "cache key for same quoted [COPY] heredoc with different env",
+			cmd1: newStageContext(
+				"COPY <<'EOF' /test\n$ENV\nEOF",
+				map[string]string{},
+				[]string{"ENV=1"},
+			),
+			cmd2: newStageContext(
+				"COPY <<'EOF' /test\n$ENV\nEOF",
+				map[string]string{},
+				[]string{"ENV=2"},
+			),
+			shdEqual: true,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.description, func(t *testing.T) {
@@ -876,7 +1095,7 @@ func Test_stageBuilder_populateCompositeKey(t *testing.T) {
 			}
 
 			fc1 := util.FileContext{Root: "workspace"}
-			dockerCommand1, err := commands.GetCommand(instructions1[0], fc1, false, true, true)
+			dockerCommand1, err := commands.GetCommand(instructions1[0], fc1, &config.KanikoOptions{CacheCopyLayers: true, CacheRunLayers: true}, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -887,7 +1106,7 @@ func Test_stageBuilder_populateCompositeKey(t *testing.T) {
 			}
 
 			fc2 := util.FileContext{Root: "workspace"}
-			dockerCommand2, err := commands.GetCommand(instructions[0], fc2, false, true, true)
+			dockerCommand2, err := commands.GetCommand(instructions[0], fc2, &config.KanikoOptions{CacheCopyLayers: true, CacheRunLayers: true}, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -1079,7 +1298,7 @@ func Test_stageBuilder_build(t *testing.T) {
 			if err != nil {
 				t.Errorf("Failed to parse stages to Kaniko Stages: %s", err)
 			}
-			_ = ResolveCrossStageInstructions(kanikoStages)
+			_ = ResolveCrossStageInstructions(kanikoStages, nil)
 			stage := kanikoStages[0]
 
 			cmds := stage.Commands
@@ -1146,7 +1365,7 @@ COPY %s foo.txt
 			if err != nil {
 				t.Errorf("Failed to parse stages to Kaniko Stages: %s", err)
 			}
-			_ = ResolveCrossStageInstructions(kanikoStages)
+			_ = ResolveCrossStageInstructions(kanikoStages, nil)
 			stage := kanikoStages[0]
 
 			cmds := stage.Commands
@@ -1223,7 +1442,7 @@ COPY %s bar.txt
 			if err != nil {
 				t.Errorf("Failed to parse stages to Kaniko Stages: %s", err)
 			}
-			_ = ResolveCrossStageInstructions(kanikoStages)
+			_ = ResolveCrossStageInstructions(kanikoStages, nil)
 			stage := kanikoStages[0]
 
 			cmds := stage.Commands
@@ -1297,7 +1516,7 @@ RUN foobar
 			if err != nil {
 				t.Errorf("Failed to parse stages to Kaniko Stages: %s", err)
 			}
-			_ = ResolveCrossStageInstructions(kanikoStages)
+			_ = ResolveCrossStageInstructions(kanikoStages, nil)
 			stage := kanikoStages[0]
 
 			cmds := stage.Commands
@@ -1555,9 +1774,8 @@ func getCommands(fileContext util.FileContext, cmds []instructions.Command, cach
 		cmd, err := commands.GetCommand(
 			c,
 			fileContext,
-			false,
-			cacheCopy,
-			cacheRun,
+			&config.KanikoOptions{CacheCopyLayers: cacheCopy, CacheRunLayers: cacheRun},
+			nil,
 		)
 		if err != nil {
 			panic(err)
@@ -1653,7 +1871,7 @@ func Test_stageBuild_populateCompositeKeyForCopyCommand(t *testing.T) {
 			}
 
 			fc := util.FileContext{Root: "workspace"}
-			copyCommand, err := commands.GetCommand(instructions[0], fc, false, true, true)
+			copyCommand, err := commands.GetCommand(instructions[0], fc, &config.KanikoOptions{CacheCopyLayers: true, CacheRunLayers: true}, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -1728,7 +1946,7 @@ func Test_ResolveCrossStageInstructions(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	stageToIdx := ResolveCrossStageInstructions(kanikoStages)
+	stageToIdx := ResolveCrossStageInstructions(kanikoStages, nil)
 	for index, stage := range stages {
 		if index == 0 {
 			continue
@@ -2022,3 +2240,274 @@ func Test_stageBuilder_convertLayerMediaType(t *testing.T) {
 		})
 	}
 }
+
+func Test_stageBuilder_populateCompositeKey_CopyExclude(t *testing.T) {
+	contextDir := t.TempDir()
+	for name, content := range map[string]string{"main.go": "main", "README.md": "readme"} {
+		if err := os.WriteFile(filepath.Join(contextDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fc := util.FileContext{Root: contextDir}
+	copyCommand, err := commands.GetCommand(&instructions.CopyCommand{
+		SourcesAndDest:  instructions.SourcesAndDest{SourcePaths: []string{"."}, DestPath: "/app"},
+		ExcludePatterns: []string{"$EXCLUDE"},
+	}, fc, &config.KanikoOptions{CacheCopyLayers: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb := &stageBuilder{fileContext: fc}
+	env := []string{"EXCLUDE=*.md"}
+	key := func() string {
+		t.Helper()
+		args := dockerfile.NewBuildArgs(nil)
+		files, err := copyCommand.FilesUsedFromContext(&v1.Config{Env: env}, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ck, err := sb.populateCompositeKey(copyCommand, files, CompositeCache{}, args, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := ck.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	original := key()
+	if err := os.WriteFile(filepath.Join(contextDir, "README.md"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if excluded := key(); excluded != original {
+		t.Errorf("expected changing an excluded file to keep the key %s, got %s", original, excluded)
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, "main.go"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if included := key(); included == original {
+		t.Errorf("expected changing an included file to change the key %s", original)
+	}
+}
+
+func Test_stageBuilder_populateCompositeKey_CopyParents(t *testing.T) {
+	contextDir := t.TempDir()
+	for _, dir := range []string{"api", "web"} {
+		if err := os.MkdirAll(filepath.Join(contextDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, "api", "package.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fc := util.FileContext{Root: contextDir}
+	sb := &stageBuilder{fileContext: fc}
+	key := func(parents bool) string {
+		t.Helper()
+		cmd, err := commands.GetCommand(&instructions.CopyCommand{
+			SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"*/package.json"}, DestPath: "/app/"},
+			Parents:        parents,
+		}, fc, &config.KanikoOptions{CacheCopyLayers: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args := dockerfile.NewBuildArgs(nil)
+		files, err := cmd.FilesUsedFromContext(&v1.Config{}, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ck, err := sb.populateCompositeKey(cmd, files, CompositeCache{}, args, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := ck.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	original, originalParents := key(false), key(true)
+	if err := os.Rename(filepath.Join(contextDir, "api", "package.json"), filepath.Join(contextDir, "web", "package.json")); err != nil {
+		t.Fatal(err)
+	}
+	if moved := key(false); moved != original {
+		t.Errorf("expected moving a file copied without its parents to keep the key %s, got %s", original, moved)
+	}
+	if moved := key(true); moved == originalParents {
+		t.Errorf("expected moving a file copied with its parents to change the key %s", originalParents)
+	}
+}
+
+func Test_stageBuilder_populateCacheKey_CopyLink(t *testing.T) {
+	contextDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(contextDir, "main.go"), []byte("main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fc := util.FileContext{Root: contextDir}
+	sb := &stageBuilder{fileContext: fc, args: dockerfile.NewBuildArgs(nil)}
+	key := func(link bool, previous, workdir string) (string, string) {
+		t.Helper()
+		cmd, err := commands.GetCommand(&instructions.CopyCommand{
+			SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"main.go"}, DestPath: "main.go"},
+			Link:           link,
+		}, fc, &config.KanikoOptions{CacheCopyLayers: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := &v1.Config{WorkingDir: workdir}
+		files, err := cmd.FilesUsedFromContext(cfg, sb.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compositeKey, ck, err := sb.populateCacheKey(cmd, files, *NewCompositeCache(previous), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chained, err := compositeKey.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ck, chained
+	}
+
+	linked, linkedChained := key(true, "base-1", "/app")
+	otherBase, otherBaseChained := key(true, "base-2", "/app")
+	if linked != otherBase {
+		t.Errorf("expected the key of a linked layer to not depend on the previous layers, got %s and %s", linked, otherBase)
+	}
+	if linkedChained == otherBaseChained {
+		t.Errorf("expected the key of the following commands to depend on the previous layers, got %s", linkedChained)
+	}
+	if otherWorkdir, _ := key(true, "base-1", "/srv"); otherWorkdir == linked {
+		t.Errorf("expected the key of a linked layer to depend on the working directory, got %s", linked)
+	}
+
+	unlinked, unlinkedChained := key(false, "base-1", "/app")
+	if unlinked != unlinkedChained {
+		t.Errorf("expected the key of a layer to be the composite key, got %s and %s", unlinked, unlinkedChained)
+	}
+	if otherBase, _ := key(false, "base-2", "/app"); otherBase == unlinked {
+		t.Errorf("expected the key of a layer to depend on the previous layers, got %s", unlinked)
+	}
+}
+
+func Test_stageBuilder_optimize_CopyLink(t *testing.T) {
+	contextDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(contextDir, "main.go"), []byte("main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fc := util.FileContext{Root: contextDir}
+	copyCommand, err := commands.GetCommand(&instructions.CopyCommand{
+		SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"main.go"}, DestPath: "/app/"},
+		Link:           true,
+	}, fc, &config.KanikoOptions{CacheCopyLayers: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cf := &v1.ConfigFile{}
+	sb := &stageBuilder{
+		opts:        &config.KanikoOptions{Cache: true},
+		cf:          cf,
+		fileContext: fc,
+		args:        dockerfile.NewBuildArgs(nil),
+	}
+	files, err := copyCommand.FilesUsedFromContext(&cf.Config, sb.args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, linkKey, err := sb.populateCacheKey(copyCommand, files, CompositeCache{}, &cf.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The previous command misses the cache, the linked layer is still found.
+	lc := &fakeLayerCache{keySequence: []string{linkKey}}
+	sb.layerCache = lc
+	sb.cmds = []commands.DockerCommand{MockDockerCommand{command: "RUN changed"}, copyCommand}
+	if err := sb.optimize(*NewCompositeCache("base"), cf.Config); err != nil {
+		t.Fatal(err)
+	}
+	cached, ok := sb.cmds[1].(*commands.CachingCopyCommand)
+	if !ok {
+		t.Fatalf("expected the linked COPY to be cached, got %T", sb.cmds[1])
+	}
+	testutil.CheckDeepEqual(t, true, cached.Linked())
+	testutil.CheckDeepEqual(t, 0, len(lc.keySequence))
+}
+
+func Test_ResolveCrossStageInstructions_BuildContexts(t *testing.T) {
+	df := `
+	FROM scratch AS deps
+	RUN echo hi > /hi
+
+	FROM deps
+	COPY --from=deps /hi /hi2
+	`
+	stages, metaArgs, err := dockerfile.Parse([]byte(df))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &config.KanikoOptions{BuildContexts: map[string]string{"deps": "dir:///deps"}}
+	kanikoStages, err := dockerfile.MakeKanikoStages(opts, stages, metaArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stageToIdx := ResolveCrossStageInstructions(kanikoStages, opts.BuildContexts)
+
+	testutil.CheckDeepEqual(t, map[string]string{}, stageToIdx)
+	testutil.CheckDeepEqual(t, -1, kanikoStages[1].BaseImageIndex)
+	testutil.CheckDeepEqual(t, "deps", kanikoStages[1].Commands[0].(*instructions.CopyCommand).From)
+}
+
+func Test_namedContextDirs(t *testing.T) {
+	buildContexts := map[string]string{
+		"deps":   "dir:///kaniko/contexts/0",
+		"base":   "docker-image://alpine:3.20",
+		"layout": "oci-layout:///layouts/app:v1",
+	}
+	testutil.CheckDeepEqual(t, map[string]string{"deps": "/kaniko/contexts/0"}, namedContextDirs(buildContexts))
+}
+
+func Test_stageBuilder_populateCompositeKey_CopyFromBuildContext(t *testing.T) {
+	contextDir, namedContext := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(namedContext, "dep.so"), []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fc := util.FileContext{Root: contextDir, NamedContexts: map[string]string{"deps": namedContext}}
+	sb := &stageBuilder{fileContext: fc}
+	key := func() string {
+		t.Helper()
+		cmd, err := commands.GetCommand(&instructions.CopyCommand{
+			SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"dep.so"}, DestPath: "/lib/"},
+			From:           "deps",
+		}, fc, &config.KanikoOptions{CacheCopyLayers: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args := dockerfile.NewBuildArgs(nil)
+		files, err := cmd.FilesUsedFromContext(&v1.Config{}, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ck, err := sb.populateCompositeKey(cmd, files, CompositeCache{}, args, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := ck.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	original := key()
+	if err := os.WriteFile(filepath.Join(namedContext, "dep.so"), []byte("v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changed := key(); changed == original {
+		t.Errorf("expected changing a file of the named build context to change the key %s", original)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/commands"
+	"github.com/pkg/errors"
+)
+
+// CacheReport records the inputs of the cache keys of the commands of a
+// build, as written to the file given with --cache-report.
+type CacheReport struct {
+	Stages []StageCacheReport `json:"stages"`
+}
+
+// StageCacheReport records the inputs of the cache keys of the commands of a
+// stage. Base is what the keys of the stage start from: the digest of its base
+// image, or the cache key of the stage it is built on.
+type StageCacheReport struct {
+	Index    int                  `json:"index"`
+	Name     string               `json:"name,omitempty"`
+	Base     []CacheKeyInput      `json:"base"`
+	Commands []CommandCacheReport `json:"commands"`
+}
+
+// CommandCacheReport is a command with its cache key, whether its layer was
+// found in the cache, and the inputs it added to the composite key. The
+// cache key is the hash of the base and of the inputs of all commands up to
+// this one, except for linked commands, whose layer input holds the inputs
+// of their key.
+type CommandCacheReport struct {
+	Command  string          `json:"command"`
+	CacheKey string          `json:"cacheKey"`
+	Cache    string          `json:"cache,omitempty"`
+	Inputs   []CacheKeyInput `json:"inputs"`
+}
+
+// CacheKeyChange is a command whose cache key changed since a previous build,
+// with the input which invalidated it.
+type CacheKeyChange struct {
+	Stage   int    `json:"stage"`
+	Command string `json:"command,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+func (c CacheKeyChange) String() string {
+	if c.Command == "" {
+		return fmt.Sprintf("stage %d: %s", c.Stage, c.Reason)
+	}
+	return fmt.Sprintf("stage %d: %s: %s", c.Stage, c.Command, c.Reason)
+}
+
+// reportCommand adds the command to the cache report of the stage, if one is
+// written.
+func (s *stageBuilder) reportCommand(command commands.DockerCommand, ck string, inputs []CacheKeyInput, cached bool) {
+	if s.opts.CacheReport == "" {
+		return
+	}
+	r := CommandCacheReport{Command: command.String(), CacheKey: ck, Inputs: inputs}
+	switch {
+	case cached:
+		r.Cache = CacheHit
+	case command.ShouldCacheOutput():
+		r.Cache = CacheMiss
+	}
+	s.cacheReport.Commands = append(s.cacheReport.Commands, r)
+}
+
+// WriteCacheReport writes the cache report to path as JSON.
+func WriteCacheReport(path string, report *CacheReport) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshaling cache report")
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return errors.Wrapf(err, "writing cache report to %s", path)
+	}
+	return nil
+}
+
+// ReadCacheReport reads a cache report written by WriteCacheReport.
+func ReadCacheReport(path string) (*CacheReport, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading cache report %s", path)
+	}
+	report := &CacheReport{}
+	if err := json.Unmarshal(b, report); err != nil {
+		return nil, errors.Wrapf(err, "parsing cache report %s", path)
+	}
+	return report, nil
+}
+
+// DiffCacheReports returns the commands of current whose cache key differs
+// from the one of the same command in previous, with the first input of
+// their key which changed. The commands following a changed one are
+// invalidated by it, unless their own inputs changed too.
+func DiffCacheReports(previous, current *CacheReport) []CacheKeyChange {
+	previousStages := make(map[int]StageCacheReport)
+	for _, stage := range previous.Stages {
+		previousStages[stage.Index] = stage
+	}
+
+	var changes []CacheKeyChange
+	for _, stage := range current.Stages {
+		prev, ok := previousStages[stage.Index]
+		if !ok {
+			changes = append(changes, CacheKeyChange{Stage: stage.Index, Reason: "stage not in the previous report"})
+			continue
+		}
+		cause := ""
+		if diff := diffInputs(prev.Base, stage.Base); diff != "" {
+			cause = "base " + diff
+		}
+		for i, cmd := range stage.Commands {
+			change := CacheKeyChange{Stage: stage.Index, Command: cmd.Command}
+			switch {
+			case i >= len(prev.Commands):
+				change.Reason = "command not in the previous report"
+			case prev.Commands[i].CacheKey == cmd.CacheKey:
+				continue
+			case prev.Commands[i].Command != cmd.Command:
+				change.Reason = fmt.Sprintf("command changed from %q", prev.Commands[i].Command)
+			default:
+				change.Reason = diffInputs(prev.Commands[i].Inputs, cmd.Inputs)
+			}
+			if change.Reason == "" {
+				change.Reason = cause
+			}
+			if change.Reason == "" {
+				change.Reason = "a previous key changed"
+			}
+			changes = append(changes, change)
+			cause = fmt.Sprintf("invalidated by %q", cmd.Command)
+		}
+	}
+	return changes
+}
+
+// diffInputs describes the first input which differs between previous and
+// current, or returns an empty string if there is none.
+func diffInputs(previous, current []CacheKeyInput) string {
+	for i := 0; i < len(previous) || i < len(current); i++ {
+		switch {
+		case i >= len(previous):
+			return describeInput(current[i]) + " added"
+		case i >= len(current):
+			return describeInput(previous[i]) + " removed"
+		}
+		p, c := previous[i], current[i]
+		if p.Kind == c.Kind && p.Name == c.Name && p.Value == c.Value {
+			continue
+		}
+		if p.Kind != c.Kind || p.Name != c.Name {
+			return fmt.Sprintf("%s replaced by %s", describeInput(p), describeInput(c))
+		}
+		switch c.Kind {
+		case InputDir:
+			if diff := diffFiles(p.Files, c.Files); diff != "" {
+				return fmt.Sprintf("%s changed: %s", describeInput(c), diff)
+			}
+		case InputLayer:
+			if diff := diffInputs(p.Inputs, c.Inputs); diff != "" {
+				return fmt.Sprintf("%s changed: %s", describeInput(c), diff)
+			}
+		case InputFile, InputEnv:
+			return describeInput(c) + " changed"
+		}
+		return fmt.Sprintf("%s changed from %q to %q", describeInput(c), p.Value, c.Value)
+	}
+	return ""
+}
+
+func describeInput(input CacheKeyInput) string {
+	if input.Name == "" {
+		return input.Kind
+	}
+	return fmt.Sprintf("%s %s", input.Kind, input.Name)
+}
+
+// diffFiles describes the files of a directory which were added, removed or
+// changed.
+func diffFiles(previous, current map[string]string) string {
+	var added, removed, changed []string
+	for f, hash := range current {
+		prev, ok := previous[f]
+		switch {
+		case !ok:
+			added = append(added, f)
+		case prev != hash:
+			changed = append(changed, f)
+		}
+	}
+	for f := range previous {
+		if _, ok := current[f]; !ok {
+			removed = append(removed, f)
+		}
+	}
+
+	var diffs []string
+	for _, files := range []struct {
+		what  string
+		files []string
+	}{{"changed", changed}, {"added", added}, {"removed", removed}} {
+		if len(files.files) > 0 {
+			sort.Strings(files.files)
+			diffs = append(diffs, fmt.Sprintf("%s %s", files.what, strings.Join(files.files, ", ")))
+		}
+	}
+	return strings.Join(diffs, "; ")
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/commands"
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func Test_DiffCacheReports(t *testing.T) {
+	base := []CacheKeyInput{{Kind: InputBase, Value: "sha256:base"}}
+	copyInputs := []CacheKeyInput{
+		{Kind: InputCommand, Value: "COPY src /src"},
+		{Kind: InputDir, Name: "src", Value: "dir-1", Files: map[string]string{"a": "a-1", "b": "b-1"}},
+	}
+	runInputs := []CacheKeyInput{
+		{Kind: InputEnvCount, Value: "|1"},
+		{Kind: InputEnv, Name: "FOO", Value: "FOO=bar"},
+		{Kind: InputCommand, Value: "RUN make"},
+	}
+	report := func(base, copyInputs, runInputs []CacheKeyInput, copyKey, runKey string) *CacheReport {
+		return &CacheReport{Stages: []StageCacheReport{{
+			Index: 1,
+			Base:  base,
+			Commands: []CommandCacheReport{
+				{Command: "COPY src /src", CacheKey: copyKey, Inputs: copyInputs},
+				{Command: "RUN make", CacheKey: runKey, Inputs: runInputs},
+			},
+		}}}
+	}
+	previous := report(base, copyInputs, runInputs, "copy-1", "run-1")
+
+	tests := []struct {
+		name    string
+		current *CacheReport
+		want    []CacheKeyChange
+	}{
+		{
+			name:    "unchanged",
+			current: report(base, copyInputs, runInputs, "copy-1", "run-1"),
+		},
+		{
+			name: "changed base",
+			current: report([]CacheKeyInput{{Kind: InputBase, Value: "sha256:other"}},
+				copyInputs, runInputs, "copy-2", "run-2"),
+			want: []CacheKeyChange{
+				{Stage: 1, Command: "COPY src /src", Reason: `base base changed from "sha256:base" to "sha256:other"`},
+				{Stage: 1, Command: "RUN make", Reason: `invalidated by "COPY src /src"`},
+			},
+		},
+		{
+			name: "changed file",
+			current: report(base, []CacheKeyInput{
+				{Kind: InputCommand, Value: "COPY src /src"},
+				{Kind: InputDir, Name: "src", Value: "dir-2", Files: map[string]string{"a": "a-2", "c": "c-1"}},
+			}, runInputs, "copy-2", "run-2"),
+			want: []CacheKeyChange{
+				{Stage: 1, Command: "COPY src /src", Reason: "dir src changed: changed a; added c; removed b"},
+				{Stage: 1, Command: "RUN make", Reason: `invalidated by "COPY src /src"`},
+			},
+		},
+		{
+			name: "changed env",
+			current: report(base, copyInputs, []CacheKeyInput{
+				{Kind: InputEnvCount, Value: "|1"},
+				{Kind: InputEnv, Name: "FOO", Value: "FOO=baz"},
+				{Kind: InputCommand, Value: "RUN make"},
+			}, "copy-1", "run-2"),
+			want: []CacheKeyChange{
+				{Stage: 1, Command: "RUN make", Reason: "env FOO changed"},
+			},
+		},
+		{
+			name: "changed linked layer",
+			current: report(base, []CacheKeyInput{{Kind: InputLayer, Name: "COPY --link src /src", Value: "link-2", Inputs: []CacheKeyInput{
+				{Kind: InputWorkdir, Value: "/"},
+				{Kind: InputFile, Name: "src", Value: "src-2"},
+			}}}, runInputs, "link-2", "run-2"),
+			want: []CacheKeyChange{
+				{Stage: 1, Command: "COPY src /src", Reason: "command replaced by layer COPY --link src /src"},
+				{Stage: 1, Command: "RUN make", Reason: `invalidated by "COPY src /src"`},
+			},
+		},
+		{
+			name:    "new stage",
+			current: &CacheReport{Stages: []StageCacheReport{{Index: 2, Base: base}}},
+			want:    []CacheKeyChange{{Stage: 2, Reason: "stage not in the previous report"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testutil.CheckDeepEqual(t, test.want, DiffCacheReports(previous, test.current))
+		})
+	}
+}
+
+func Test_diffInputs_Layer(t *testing.T) {
+	layer := func(hash string) []CacheKeyInput {
+		return []CacheKeyInput{{Kind: InputLayer, Name: "COPY --link src /src", Value: "link-" + hash, Inputs: []CacheKeyInput{
+			{Kind: InputWorkdir, Value: "/"},
+			{Kind: InputFile, Name: "src", Value: hash},
+		}}}
+	}
+	testutil.CheckDeepEqual(t, "layer COPY --link src /src changed: file src changed", diffInputs(layer("1"), layer("2")))
+	testutil.CheckDeepEqual(t, "", diffInputs(layer("1"), layer("1")))
+}
+
+func Test_stageBuilder_cacheReport(t *testing.T) {
+	contextDir := t.TempDir()
+	fc := util.FileContext{Root: contextDir}
+	opts := &config.KanikoOptions{CacheCopyLayers: true, CacheRunLayers: true, CacheReport: "report.json"}
+
+	stageReport := func(content, foo string) StageCacheReport {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(contextDir, "main.go"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmds, err := dockerfile.ParseCommands([]string{"COPY main.go /app/", "RUN go build"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sb := &stageBuilder{opts: opts, fileContext: fc, args: dockerfile.NewBuildArgs(nil)}
+		compositeKey := newCompositeCacheFrom(InputBase, "sha256:base")
+		compositeKey.files = true
+		sb.cacheReport = StageCacheReport{Base: compositeKey.Inputs()}
+		cfg := &v1.Config{Env: []string{"FOO=" + foo}}
+		for _, c := range cmds {
+			cmd, err := commands.GetCommand(c, fc, opts, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, err := cmd.FilesUsedFromContext(cfg, sb.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := len(compositeKey.inputs)
+			var ck string
+			*compositeKey, ck, err = sb.populateCacheKey(cmd, files, *compositeKey, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sb.reportCommand(cmd, ck, compositeKey.Inputs()[n:], false)
+		}
+		return sb.cacheReport
+	}
+
+	previous := &CacheReport{Stages: []StageCacheReport{stageReport("main", "bar")}}
+	testutil.CheckDeepEqual(t, []CacheKeyInput{
+		{Kind: InputCommand, Value: "COPY main.go /app/"},
+		{Kind: InputFile, Name: "main.go", Value: previous.Stages[0].Commands[0].Inputs[1].Value},
+	}, previous.Stages[0].Commands[0].Inputs)
+	testutil.CheckDeepEqual(t, CacheMiss, previous.Stages[0].Commands[0].Cache)
+	// The report must not reveal the values of the variables.
+	b, err := json.Marshal(previous)
+	testutil.CheckNoError(t, err)
+	if strings.Contains(string(b), "FOO=bar") {
+		t.Errorf("report contains the value of FOO: %s", b)
+	}
+
+	current := &CacheReport{Stages: []StageCacheReport{stageReport("main", "baz")}}
+	testutil.CheckDeepEqual(t, []CacheKeyChange{
+		{Stage: 0, Command: "RUN go build", Reason: "env FOO changed"},
+	}, DiffCacheReports(previous, current))
+
+	current = &CacheReport{Stages: []StageCacheReport{stageReport("changed", "bar")}}
+	testutil.CheckDeepEqual(t, []CacheKeyChange{
+		{Stage: 0, Command: "COPY main.go /app/", Reason: "file main.go changed"},
+		{Stage: 0, Command: "RUN go build", Reason: `invalidated by "COPY main.go /app/"`},
+	}, DiffCacheReports(previous, current))
+}
+
+func Test_stageBuilder_populateCacheKey_Files(t *testing.T) {
+	contextDir := t.TempDir()
+	if err := createFilesystemStructure(contextDir, []string{"src"}, []string{"src/a", "src/b"}); err != nil {
+		t.Fatal(err)
+	}
+	fc := util.FileContext{Root: contextDir}
+	opts := &config.KanikoOptions{CacheCopyLayers: true}
+	cmds, err := dockerfile.ParseCommands([]string{"COPY src /src", "COPY --link src /src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hashes of the files of a directory are only kept if the composite
+	// key asks for them, including in the key of a linked layer.
+	for _, withFiles := range []bool{false, true} {
+		sb := &stageBuilder{opts: opts, fileContext: fc, args: dockerfile.NewBuildArgs(nil)}
+		for _, c := range cmds {
+			cmd, err := commands.GetCommand(c, fc, opts, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &v1.Config{}
+			files, err := cmd.FilesUsedFromContext(cfg, sb.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compositeKey := NewCompositeCache("base")
+			compositeKey.files = withFiles
+			*compositeKey, _, err = sb.populateCacheKey(cmd, files, *compositeKey, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs := compositeKey.Inputs()
+			dir := inputs[len(inputs)-1]
+			if dir.Kind == InputLayer {
+				dir = dir.Inputs[len(dir.Inputs)-1]
+			}
+			testutil.CheckDeepEqual(t, InputDir, dir.Kind)
+			testutil.CheckDeepEqual(t, withFiles, len(dir.Files) == 3)
+			testutil.CheckDeepEqual(t, !withFiles, dir.Files == nil)
+		}
+	}
+}
+
+func Test_CacheReport_WriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	report := &CacheReport{Stages: []StageCacheReport{{
+		Index: 0,
+		Name:  "builder",
+		Base:  []CacheKeyInput{{Kind: InputBase, Value: "sha256:base"}},
+		Commands: []CommandCacheReport{{
+			Command:  "COPY src /src",
+			CacheKey: "key",
+			Cache:    CacheHit,
+			Inputs:   []CacheKeyInput{{Kind: InputDir, Name: "src", Value: "dir", Files: map[string]string{"a": "a"}}},
+		}},
+	}}}
+	testutil.CheckNoError(t, WriteCacheReport(path, report))
+	read, err := ReadCacheReport(path)
+	testutil.CheckErrorAndDeepEqual(t, false, err, report, read)
+
+	_, err = ReadCacheReport(filepath.Join(t.TempDir(), "missing.json"))
+	testutil.CheckError(t, true, err)
+}
@@ -21,28 +21,89 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/pkg/errors"
 )
 
+// Kinds of the inputs of a composite key.
+const (
+	InputKey      = "key"
+	InputBase     = "base"
+	InputStage    = "stage"
+	InputWorkdir  = "workdir"
+	InputEnvCount = "envCount"
+	InputEnv      = "env"
+	InputCommand  = "command"
+	InputChecksum = "checksum"
+	InputPath     = "path"
+	InputFile     = "file"
+	InputDir      = "dir"
+	InputLayer    = "layer"
+)
+
 // NewCompositeCache returns an initialized composite cache object.
 func NewCompositeCache(initial ...string) *CompositeCache {
-	c := CompositeCache{
-		keys: initial,
-	}
+	c := CompositeCache{}
+	c.AddKey(initial...)
+	return &c
+}
+
+// newCompositeCacheFrom returns a composite cache starting with a key of the
+// given kind.
+func newCompositeCacheFrom(kind, key string) *CompositeCache {
+	c := CompositeCache{}
+	c.addInput(CacheKeyInput{Kind: kind, Value: key})
 	return &c
 }
 
 // CompositeCache is a type that generates a cache key from a series of keys.
 type CompositeCache struct {
-	keys []string
+	keys   []string
+	inputs []CacheKeyInput
+	// files keeps the hashes of the files of the directories added to the
+	// key in their input, which is only needed by the cache report.
+	files bool
+}
+
+// CacheKeyInput is a key of a composite key along with what it was derived
+// from, so that a changed key can be traced back to its origin. Value is the
+// key itself, or the hash of an environment variable, Name the file or
+// variable it was derived from. The hashes of the files of a directory are
+// kept in Files, and the inputs of the key of a linked layer in Inputs.
+type CacheKeyInput struct {
+	Kind   string            `json:"kind"`
+	Name   string            `json:"name,omitempty"`
+	Value  string            `json:"value"`
+	Files  map[string]string `json:"files,omitempty"`
+	Inputs []CacheKeyInput   `json:"inputs,omitempty"`
 }
 
 // AddKey adds the specified key to the sequence.
 func (s *CompositeCache) AddKey(k ...string) {
-	s.keys = append(s.keys, k...)
+	for _, key := range k {
+		s.addInput(CacheKeyInput{Kind: InputKey, Value: key})
+	}
+}
+
+func (s *CompositeCache) addInput(input CacheKeyInput) {
+	s.keys = append(s.keys, input.Value)
+	s.inputs = append(s.inputs, input)
+}
+
+// addEnv adds an environment variable or build arg to the key. Its input
+// only records a hash of the variable, so that the cache report does not
+// reveal the values of secrets passed as build args.
+func (s *CompositeCache) addEnv(name, env string) {
+	s.keys = append(s.keys, env)
+	s.inputs = append(s.inputs, CacheKeyInput{Kind: InputEnv, Name: name, Value: fmt.Sprintf("%x", sha256.Sum256([]byte(env)))})
+}
+
+// Inputs returns the inputs of the composite key in the order they were added.
+func (s *CompositeCache) Inputs() []CacheKeyInput {
+	return slices.Clone(s.inputs)
 }
 
 // Key returns the human readable composite key as a string.
@@ -62,8 +123,9 @@ func (s *CompositeCache) AddPath(p string, context util.FileContext) error {
 		return errors.Wrap(err, "could not add path")
 	}
 
+	name := contextPath(p, context)
 	if fi.Mode().IsDir() {
-		empty, k, err := hashDir(p, context)
+		empty, k, files, err := hashDir(p, context, s.files)
 		if err != nil {
 			return err
 		}
@@ -71,7 +133,7 @@ func (s *CompositeCache) AddPath(p string, context util.FileContext) error {
 		// Only add the hash of this directory to the key
 		// if there is any ignored content.
 		if !empty || !context.ExcludesFile(p) {
-			s.keys = append(s.keys, k)
+			s.addInput(CacheKeyInput{Kind: InputDir, Name: name, Value: k, Files: files})
 		}
 		return nil
 	}
@@ -87,14 +149,38 @@ func (s *CompositeCache) AddPath(p string, context util.FileContext) error {
 		return err
 	}
 
-	s.keys = append(s.keys, fmt.Sprintf("%x", sha.Sum(nil)))
+	s.addInput(CacheKeyInput{Kind: InputFile, Name: name, Value: fmt.Sprintf("%x", sha.Sum(nil))})
 	return nil
 }
 
-// HashDir returns a hash of the directory.
-func hashDir(p string, context util.FileContext) (bool, string, error) {
+// contextPath returns the path of p relative to the root of the build context
+// or of the named build context it is in, so that keys derived from the same
+// file can be compared between builds with contexts at different places.
+func contextPath(p string, context util.FileContext) string {
+	roots := []string{context.Root}
+	for _, dir := range context.NamedContexts {
+		roots = append(roots, dir)
+	}
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(root, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return p
+}
+
+// HashDir returns a hash of the directory, along with the hashes of its
+// files relative to the directory if withFiles is true.
+func hashDir(p string, context util.FileContext, withFiles bool) (bool, string, map[string]string, error) {
 	sha := sha256.New()
 	empty := true
+	var files map[string]string
+	if withFiles {
+		files = make(map[string]string)
+	}
 	if err := filepath.Walk(p, func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -111,11 +197,16 @@ func hashDir(p string, context util.FileContext) (bool, string, error) {
 		if _, err := sha.Write([]byte(fileHash)); err != nil {
 			return err
 		}
+		if withFiles {
+			if rel, err := filepath.Rel(p, path); err == nil {
+				files[rel] = fileHash
+			}
+		}
 		empty = false
 		return nil
 	}); err != nil {
-		return false, "", err
+		return false, "", nil, err
 	}
 
-	return empty, fmt.Sprintf("%x", sha.Sum(nil)), nil
+	return empty, fmt.Sprintf("%x", sha.Sum(nil)), files, nil
 }
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
 )
 
 func Test_NewCompositeCache(t *testing.T) {
@@ -135,6 +136,50 @@ func Test_CompositeCache_AddPath_file(t *testing.T) {
 	}
 }
 
+func Test_CompositeCache_Inputs(t *testing.T) {
+	root := t.TempDir()
+	if err := createFilesystemStructure(root, []string{"dir"}, []string{"dir/a", "dir/b", "c"}); err != nil {
+		t.Fatalf("got error setting up test %v", err)
+	}
+	hasher := util.CacheHasher()
+	fileHash := func(p string) string {
+		h, err := hasher(filepath.Join(root, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	r := NewCompositeCache("meow")
+	r.files = true
+	context := util.FileContext{Root: root}
+	for _, p := range []string{"dir", "c"} {
+		if err := r.AddPath(filepath.Join(root, p), context); err != nil {
+			t.Fatalf("expected error to be nil but was %v", err)
+		}
+	}
+
+	inputs := r.Inputs()
+	testutil.CheckDeepEqual(t, 3, len(inputs))
+	testutil.CheckDeepEqual(t, CacheKeyInput{Kind: InputKey, Value: "meow"}, inputs[0])
+	testutil.CheckDeepEqual(t, InputDir, inputs[1].Kind)
+	testutil.CheckDeepEqual(t, "dir", inputs[1].Name)
+	testutil.CheckDeepEqual(t, map[string]string{".": fileHash("dir"), "a": fileHash("dir/a"), "b": fileHash("dir/b")}, inputs[1].Files)
+	testutil.CheckDeepEqual(t, InputFile, inputs[2].Kind)
+	testutil.CheckDeepEqual(t, "c", inputs[2].Name)
+	for i, input := range inputs {
+		testutil.CheckDeepEqual(t, r.keys[i], input.Value)
+	}
+
+	// The hashes of the files are only kept if they are asked for.
+	r = NewCompositeCache("meow")
+	if err := r.AddPath(filepath.Join(root, "dir"), context); err != nil {
+		t.Fatalf("expected error to be nil but was %v", err)
+	}
+	testutil.CheckDeepEqual(t, inputs[1].Value, r.Inputs()[1].Value)
+	testutil.CheckDeepEqual(t, true, r.Inputs()[1].Files == nil)
+}
+
 func createFilesystemStructure(root string, directories, files []string) error {
 	for _, d := range directories {
 		dirPath := path.Join(root, d)
@@ -523,3 +568,35 @@ func Test_CompositeKey_AddPath_WithExtraDirIgnored_Works(t *testing.T) {
 		})
 	}
 }
+
+func Test_CompositeKey_AddPath_WithInstructionExcludes(t *testing.T) {
+	testDir1 := t.TempDir()
+	if err := createFilesystemStructure(testDir1, []string{"tests"}, []string{"main.go", "tests/main_test.go"}); err != nil {
+		t.Fatalf("Error creating filesytem structure: %s", err)
+	}
+	testDir2 := t.TempDir()
+	if err := createFilesystemStructure(testDir2, []string{"tests"}, []string{"main.go", "README.md", "tests/other_test.go"}); err != nil {
+		t.Fatalf("Error creating filesytem structure: %s", err)
+	}
+
+	excludes := []string{"*.md", "tests/"}
+	hash1, err := hashDirectory(testDir1, util.FileContext{Root: testDir1}.WithExcludes(excludes))
+	if err != nil {
+		t.Fatalf("Failed to calculate hash: %s", err)
+	}
+	hash2, err := hashDirectory(testDir2, util.FileContext{Root: testDir2}.WithExcludes(excludes))
+	if err != nil {
+		t.Fatalf("Failed to calculate hash: %s", err)
+	}
+	if hash1 != hash2 {
+		t.Errorf("Expected equal hashes, got: %s and %s", hash1, hash2)
+	}
+
+	hash3, err := hashDirectory(testDir2, util.FileContext{Root: testDir2})
+	if err != nil {
+		t.Fatalf("Failed to calculate hash: %s", err)
+	}
+	if hash1 == hash3 {
+		t.Errorf("Expected different hashes without exclusions, got: %s", hash1)
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"slices"
+	"sync"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/commands"
 	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
@@ -44,6 +46,9 @@ func (f *fakeSnapShotter) TakeSnapshotFS() (string, error) {
 func (f *fakeSnapShotter) TakeSnapshot(_ []string, _, _ bool) (string, error) {
 	return f.tarPath, nil
 }
+func (f *fakeSnapShotter) TakeSnapshotChanges(_, _ []string) (string, error) {
+	return f.tarPath, nil
+}
 
 type MockDockerCommand struct {
 	command             string
@@ -127,14 +132,19 @@ type fakeLayerCache struct {
 	retrieve     bool
 	receivedKeys []string
 	img          v1.Image
-	keySequence  []string
+	// keySequence are the keys which are found, each once. The layers are
+	// looked up concurrently, so in any order.
+	keySequence []string
+	mu          sync.Mutex
 }
 
 func (f *fakeLayerCache) RetrieveLayer(key string) (v1.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.receivedKeys = append(f.receivedKeys, key)
 	if len(f.keySequence) > 0 {
-		if f.keySequence[0] == key {
-			f.keySequence = f.keySequence[1:]
+		if i := slices.Index(f.keySequence, key); i >= 0 {
+			f.keySequence = slices.Delete(f.keySequence, i, i+1)
 			return f.img, nil
 		}
 		return f.img, errors.New("could not find layer")
@@ -0,0 +1,161 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/constants"
+	image_util "github.com/GoogleContainerTools/kaniko/pkg/image"
+	"github.com/GoogleContainerTools/kaniko/pkg/timing"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// BuildIndex returns an image index of the images of manifestOpts, which are
+// either references to a registry or OCI layouts prefixed with oci-layout://.
+// The platform of each image is taken from its config, and has to be unique.
+func BuildIndex(manifestOpts *config.ManifestOptions, opts *config.KanikoOptions) (v1.ImageIndex, error) {
+	if len(manifestOpts.Images) == 0 {
+		return nil, errors.New("must provide at least one image to add to the index")
+	}
+	mediaType := types.OCIImageIndex
+	if manifestOpts.Format == config.DockerManifestList {
+		if len(manifestOpts.Annotations) > 0 {
+			return nil, errors.New("annotations are only supported by OCI image indexes")
+		}
+		mediaType = types.DockerManifestList
+	}
+
+	var adds []mutate.IndexAddendum
+	platforms := map[string]string{}
+	for _, source := range manifestOpts.Images {
+		image, err := retrieveIndexImage(source, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "retrieving image %s", source)
+		}
+		cf, err := image.ConfigFile()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading config of image %s", source)
+		}
+		platform := cf.Platform()
+		if platform == nil || platform.OS == "" || platform.Architecture == "" {
+			return nil, fmt.Errorf("image %s has no OS and architecture in its config", source)
+		}
+		if other, ok := platforms[platform.String()]; ok {
+			return nil, fmt.Errorf("images %s and %s are both for platform %s", other, source, platform)
+		}
+		platforms[platform.String()] = source
+		logrus.Infof("Adding image %s for platform %s", source, platform)
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        image,
+			Descriptor: v1.Descriptor{Platform: platform},
+		})
+	}
+
+	index := mutate.IndexMediaType(mutate.AppendManifests(empty.Index, adds...), mediaType)
+	if len(manifestOpts.Annotations) > 0 {
+		index = mutate.Annotations(index, manifestOpts.Annotations).(v1.ImageIndex)
+	}
+	return index, nil
+}
+
+// retrieveIndexImage returns the image of source. An image index given as
+// source is resolved to the image for --custom-platform.
+func retrieveIndexImage(source string, opts *config.KanikoOptions) (v1.Image, error) {
+	if !strings.HasPrefix(source, constants.OCILayoutBuildContextPrefix) {
+		source = constants.DockerImageBuildContextPrefix + source
+	}
+	return image_util.RetrieveBuildContextImage(source, opts)
+}
+
+// DoPushIndex pushes the image index to the destinations specified in opts,
+// and writes it to the OCI layout and the digest file like DoPush.
+func DoPushIndex(index v1.ImageIndex, opts *config.KanikoOptions) error {
+	t := timing.Start("Total Push Time")
+	defer timing.DefaultRun.Stop(t)
+
+	if !opts.NoPush && len(opts.Destinations) == 0 {
+		return errors.New("must provide at least one destination to push")
+	}
+	if opts.TarPath != "" {
+		return errors.New("image indexes can't be saved as a tarball")
+	}
+
+	digest, err := index.Digest()
+	if err != nil {
+		return errors.Wrap(err, "error fetching digest")
+	}
+	if opts.DigestFile != "" {
+		if err := writeDigestFile(opts.DigestFile, []byte(digest.String())); err != nil {
+			return errors.Wrap(err, "writing digest to file failed")
+		}
+	}
+
+	if opts.OCILayoutPath != "" {
+		path, err := layout.Write(opts.OCILayoutPath, empty.Index)
+		if err != nil {
+			return errors.Wrap(err, "writing empty layout")
+		}
+		if err := path.AppendIndex(index); err != nil {
+			return errors.Wrap(err, "appending index")
+		}
+	}
+
+	if opts.NoPush {
+		logrus.Info("Skipping push to container registry due to --no-push flag")
+		return nil
+	}
+
+	for _, destination := range opts.Destinations {
+		destRef, err := name.NewTag(destination, name.WeakValidation)
+		if err != nil {
+			return errors.Wrap(err, "getting tag for destination")
+		}
+		destRef, remoteOpts, err := remoteWriteOptions(destRef, opts)
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("Pushing image index to %s", destRef.String())
+		retryFunc := func() error {
+			if err := remote.WriteIndex(destRef, index, remoteOpts...); err != nil {
+				if isIgnoredPushError(err, opts) {
+					logrus.Infof("Immutable tag error ignored for %s", destRef.Context().Digest(digest.String()))
+					return nil
+				}
+				return err
+			}
+			logrus.Infof("Pushed %s", destRef.Context().Digest(digest.String()))
+			return nil
+		}
+		if err := util.Retry(retryFunc, opts.PushRetry, 1000); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to push to destination %s", destRef))
+		}
+	}
+	return nil
+}
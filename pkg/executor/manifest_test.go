@@ -0,0 +1,169 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+)
+
+// writePlatformLayout writes an image for platform to a new OCI layout and
+// returns it as an image source of the manifest command.
+func writePlatformLayout(t *testing.T, platform v1.Platform) (string, v1.Hash) {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf.OS, cf.Architecture, cf.Variant = platform.OS, platform.Architecture, platform.Variant
+	img, err = mutate.ConfigFile(img, cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendImage(img); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "oci-layout://" + dir, digest
+}
+
+func TestBuildIndex(t *testing.T) {
+	amd64, amd64Digest := writePlatformLayout(t, v1.Platform{OS: "linux", Architecture: "amd64"})
+	armv7, armv7Digest := writePlatformLayout(t, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"})
+	amd64Again, _ := writePlatformLayout(t, v1.Platform{OS: "linux", Architecture: "amd64"})
+	noPlatform, _ := writePlatformLayout(t, v1.Platform{})
+
+	tests := []struct {
+		name            string
+		manifestOpts    config.ManifestOptions
+		wantMediaType   types.MediaType
+		wantPlatforms   map[v1.Hash]string
+		wantAnnotations map[string]string
+		shdErr          bool
+	}{
+		{
+			name: "oci index with annotations",
+			manifestOpts: config.ManifestOptions{
+				Images:      []string{amd64, armv7},
+				Annotations: map[string]string{"org.opencontainers.image.version": "1.0"},
+				Format:      config.OCIIndex,
+			},
+			wantMediaType:   types.OCIImageIndex,
+			wantPlatforms:   map[v1.Hash]string{amd64Digest: "linux/amd64", armv7Digest: "linux/arm/v7"},
+			wantAnnotations: map[string]string{"org.opencontainers.image.version": "1.0"},
+		},
+		{
+			name:          "docker manifest list",
+			manifestOpts:  config.ManifestOptions{Images: []string{amd64, armv7}, Format: config.DockerManifestList},
+			wantMediaType: types.DockerManifestList,
+			wantPlatforms: map[v1.Hash]string{amd64Digest: "linux/amd64", armv7Digest: "linux/arm/v7"},
+		},
+		{
+			name: "docker manifest list with annotations",
+			manifestOpts: config.ManifestOptions{
+				Images:      []string{amd64},
+				Annotations: map[string]string{"key": "value"},
+				Format:      config.DockerManifestList,
+			},
+			shdErr: true,
+		},
+		{
+			name:         "two images for the same platform",
+			manifestOpts: config.ManifestOptions{Images: []string{amd64, amd64Again}},
+			shdErr:       true,
+		},
+		{
+			name:         "image without a platform",
+			manifestOpts: config.ManifestOptions{Images: []string{amd64, noPlatform}},
+			shdErr:       true,
+		},
+		{
+			name:         "no images",
+			manifestOpts: config.ManifestOptions{},
+			shdErr:       true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index, err := BuildIndex(&test.manifestOpts, &config.KanikoOptions{})
+			testutil.CheckError(t, test.shdErr, err)
+			if test.shdErr {
+				return
+			}
+			testutil.CheckError(t, false, validate.Index(index))
+
+			manifest, err := index.IndexManifest()
+			testutil.CheckNoError(t, err)
+			testutil.CheckDeepEqual(t, test.wantMediaType, manifest.MediaType)
+			testutil.CheckDeepEqual(t, test.wantAnnotations, manifest.Annotations)
+			platforms := map[v1.Hash]string{}
+			for _, m := range manifest.Manifests {
+				platforms[m.Digest] = m.Platform.String()
+			}
+			testutil.CheckDeepEqual(t, test.wantPlatforms, platforms)
+		})
+	}
+}
+
+func TestDoPushIndex(t *testing.T) {
+	amd64, _ := writePlatformLayout(t, v1.Platform{OS: "linux", Architecture: "amd64"})
+	arm64, _ := writePlatformLayout(t, v1.Platform{OS: "linux", Architecture: "arm64"})
+	index, err := BuildIndex(&config.ManifestOptions{Images: []string{amd64, arm64}}, &config.KanikoOptions{})
+	testutil.CheckNoError(t, err)
+	digest, err := index.Digest()
+	testutil.CheckNoError(t, err)
+
+	layoutPath := t.TempDir()
+	digestFile := filepath.Join(t.TempDir(), "digest")
+	opts := &config.KanikoOptions{NoPush: true, OCILayoutPath: layoutPath, DigestFile: digestFile}
+	testutil.CheckNoError(t, DoPushIndex(index, opts))
+
+	got, err := os.ReadFile(digestFile)
+	testutil.CheckErrorAndDeepEqual(t, false, err, digest.String(), string(got))
+
+	layoutIndex, err := layout.ImageIndexFromPath(layoutPath)
+	testutil.CheckNoError(t, err)
+	written, err := layoutIndex.ImageIndex(digest)
+	testutil.CheckNoError(t, err)
+	testutil.CheckError(t, false, validate.Index(written))
+
+	opts = &config.KanikoOptions{NoPush: true, TarPath: filepath.Join(t.TempDir(), "image.tar")}
+	testutil.CheckError(t, true, DoPushIndex(index, opts))
+}
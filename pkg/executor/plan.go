@@ -0,0 +1,263 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/commands"
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	image_util "github.com/GoogleContainerTools/kaniko/pkg/image"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/pkg/errors"
+)
+
+// Cache predictions of a command in a build plan.
+const (
+	CacheHit     = "hit"
+	CacheMiss    = "miss"
+	CacheUnknown = "unknown"
+)
+
+// BuildPlan is what a build would do, as computed by DoBuildPlan.
+type BuildPlan struct {
+	Stages        []StagePlan    `json:"stages"`
+	SkippedStages []SkippedStage `json:"skippedStages,omitempty"`
+}
+
+// StagePlan is a stage a build would build. Indexes are the ones of the
+// stages in the Dockerfile.
+type StagePlan struct {
+	Index           int           `json:"index"`
+	Name            string        `json:"name,omitempty"`
+	BaseName        string        `json:"baseName"`
+	BaseStage       *int          `json:"baseStage,omitempty"`
+	BaseImageDigest string        `json:"baseImageDigest,omitempty"`
+	Final           bool          `json:"final,omitempty"`
+	SavedFiles      []string      `json:"savedFiles,omitempty"`
+	Commands        []CommandPlan `json:"commands"`
+}
+
+// SkippedStage is a stage of the Dockerfile a build would skip.
+type SkippedStage struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// CommandPlan is a command of a stage with its cache key and whether its
+// layer would be found in the cache. The cache key of a command using files
+// of another stage is only known once that stage is built.
+type CommandPlan struct {
+	Command  string `json:"command"`
+	CacheKey string `json:"cacheKey,omitempty"`
+	Cache    string `json:"cache,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// DoBuildPlan returns what DoBuild would do with opts: the stages to build
+// and to skip, their base images and the cache keys of their commands, and
+// which layers would be found in the cache. No filesystem is unpacked and
+// no command other than the ones which only change the config is executed.
+func DoBuildPlan(opts *config.KanikoOptions) (*BuildPlan, error) {
+	stages, metaArgs, err := dockerfile.ParseStages(opts)
+	if err != nil {
+		return nil, err
+	}
+	kanikoStages, err := dockerfile.MakeKanikoStages(opts, stages, metaArgs)
+	if err != nil {
+		return nil, err
+	}
+	stageNameToIdx := ResolveCrossStageInstructions(kanikoStages, opts.BuildContexts)
+
+	fileContext, err := util.NewFileContextFromDockerfile(opts.DockerfilePath, opts.SrcContext)
+	if err != nil {
+		return nil, err
+	}
+	fileContext.NamedContexts = namedContextDirs(opts.BuildContexts)
+
+	crossStageDependencies, err := CalculateDependencies(kanikoStages, opts, stageNameToIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &BuildPlan{SkippedStages: skippedStages(stages, kanikoStages)}
+	digestToCacheKey := make(map[string]string)
+	stageIdxToDigest := make(map[string]string)
+	// The images and final cache keys of the stages, which later stages may
+	// be built on. An empty key is only known once the stage is built.
+	images := make([]v1.Image, len(kanikoStages))
+	finalCacheKeys := make([]string, len(kanikoStages))
+	var args *dockerfile.BuildArgs
+	layerCache := newLayerCache(opts)
+	for index, stage := range kanikoStages {
+		var sourceImage v1.Image
+		if stage.BaseImageStoredLocally {
+			sourceImage = images[stage.BaseImageIndex]
+		} else {
+			sourceImage, err = image_util.RetrieveSourceImage(stage, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sb, err := newStageBuilderFromImage(sourceImage, args, opts, stage, crossStageDependencies,
+			digestToCacheKey, stageIdxToDigest, stageNameToIdx, fileContext)
+		if err != nil {
+			return nil, err
+		}
+		sb.layerCache = layerCache
+		args = sb.args
+
+		stagePlan := StagePlan{
+			Index:      dockerfileIndex(stages, stage),
+			Name:       stage.Name,
+			BaseName:   stage.BaseName,
+			Final:      stage.Final,
+			SavedFiles: crossStageDependencies[index],
+		}
+		var compositeKey *CompositeCache
+		if stage.BaseImageStoredLocally {
+			baseStage := dockerfileIndex(stages, kanikoStages[stage.BaseImageIndex])
+			stagePlan.BaseStage = &baseStage
+			if key := finalCacheKeys[stage.BaseImageIndex]; key != "" {
+				compositeKey = newCompositeCacheFrom(InputStage, key)
+			}
+		} else {
+			stagePlan.BaseImageDigest = sb.baseImageDigest
+			compositeKey = newCompositeCacheFrom(InputBase, sb.baseImageDigest)
+		}
+
+		cfg := sb.cf.Config
+		stagePlan.Commands, err = sb.plan(compositeKey, &cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "planning stage %d", stagePlan.Index)
+		}
+		plan.Stages = append(plan.Stages, stagePlan)
+
+		reviewConfig(stage, &cfg)
+		images[index], err = mutate.Config(sb.image, cfg)
+		if err != nil {
+			return nil, err
+		}
+		finalCacheKeys[index] = sb.finalCacheKey
+	}
+	return plan, nil
+}
+
+// plan returns the commands of the stage with their cache keys, and whether
+// the build would use their layers from the cache, like optimize computes
+// them without replacing the commands. Commands which only change the config
+// are executed on cfg. compositeKey is nil if the key of the base image is
+// only known once it is built.
+func (s *stageBuilder) plan(compositeKey *CompositeCache, cfg *v1.Config) ([]CommandPlan, error) {
+	lookups, err := s.cacheKeys(compositeKey, cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	s.lookupLayers(lookups)
+
+	var plans []CommandPlan
+	for _, l := range lookups {
+		p := CommandPlan{Command: s.cmds[l.index].String(), CacheKey: l.ck}
+		switch {
+		case l.unknown != "":
+			p.Cache, p.Reason = CacheUnknown, l.unknown
+		case !l.lookup:
+		case l.hit:
+			p.Cache = CacheHit
+		default:
+			p.Cache = CacheMiss
+		}
+		plans = append(plans, p)
+	}
+	return plans, nil
+}
+
+var errStageFiles = errors.New("files of another stage or image")
+
+// filesUsedFromContext returns the files the command uses like
+// FilesUsedFromContext, or errStageFiles if they include files of another
+// stage or image saved to the kaniko directory, which only exist once built.
+func (s *stageBuilder) filesUsedFromContext(command commands.DockerCommand, cfg *v1.Config) ([]string, error) {
+	if copyCmd, ok := commands.CastAbstractCopyCommand(command); ok && copyCmd.From() != "" {
+		if _, ok := s.fileContext.NamedContexts[copyCmd.From()]; !ok {
+			return nil, errStageFiles
+		}
+	}
+	files, err := command.FilesUsedFromContext(cfg, s.args)
+	if err != nil {
+		return nil, err
+	}
+	roots := []string{s.fileContext.Root}
+	for _, dir := range s.fileContext.NamedContexts {
+		roots = append(roots, dir)
+	}
+	for _, f := range files {
+		if !isInDir(f, config.KanikoDir) {
+			continue
+		}
+		inContext := false
+		for _, root := range roots {
+			inContext = inContext || isInDir(f, root)
+		}
+		if !inContext {
+			return nil, errStageFiles
+		}
+	}
+	return files, nil
+}
+
+func isInDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// skippedStages returns the stages of the Dockerfile which aren't built,
+// because they aren't used or come after the target stage.
+func skippedStages(stages []instructions.Stage, kanikoStages []config.KanikoStage) []SkippedStage {
+	target := dockerfileIndex(stages, kanikoStages[len(kanikoStages)-1])
+	built := make(map[int]bool)
+	for _, s := range kanikoStages {
+		built[dockerfileIndex(stages, s)] = true
+	}
+	var skipped []SkippedStage
+	for i, s := range stages {
+		switch {
+		case i > target:
+			skipped = append(skipped, SkippedStage{Index: i, Name: s.Name, Reason: "after the target stage"})
+		case !built[i]:
+			skipped = append(skipped, SkippedStage{Index: i, Name: s.Name, Reason: "unused"})
+		}
+	}
+	return skipped
+}
+
+// dockerfileIndex returns the index of the stage in the Dockerfile, which
+// differs from its index once unused stages are skipped.
+func dockerfileIndex(stages []instructions.Stage, stage config.KanikoStage) int {
+	for i, s := range stages {
+		if len(s.Location) > 0 && len(stage.Location) > 0 && s.Location[0] == stage.Location[0] {
+			return i
+		}
+	}
+	return stage.Index
+}
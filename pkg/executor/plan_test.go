@@ -0,0 +1,159 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/commands"
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+func Test_stageBuilder_plan(t *testing.T) {
+	contextDir := t.TempDir()
+	for _, f := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(contextDir, f), []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fc := util.FileContext{Root: contextDir}
+	opts := &config.KanikoOptions{Cache: true, CacheCopyLayers: true}
+	var cmds []commands.DockerCommand
+	for _, c := range []instructions.Command{
+		&instructions.CopyCommand{SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"a"}, DestPath: "/a"}},
+		&instructions.CopyCommand{SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"b"}, DestPath: "/b"}},
+		&instructions.EnvCommand{Env: instructions.KeyValuePairs{{Key: "DIR", Value: "/c"}}},
+		&instructions.CopyCommand{SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"c"}, DestPath: "$DIR"}},
+		&instructions.CopyCommand{SourcesAndDest: instructions.SourcesAndDest{SourcePaths: []string{"c"}, DestPath: "/d"}, Link: true},
+	} {
+		cmd, err := commands.GetCommand(c, fc, opts, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	newStageBuilder := func(lc *fakeLayerCache) *stageBuilder {
+		return &stageBuilder{
+			opts:        opts,
+			cf:          &v1.ConfigFile{},
+			fileContext: fc,
+			args:        dockerfile.NewBuildArgs(nil),
+			cmds:        cmds,
+			layerCache:  lc,
+		}
+	}
+
+	// Find the keys of the commands to put them in the cache.
+	sb := newStageBuilder(&fakeLayerCache{})
+	cfg := v1.Config{}
+	plans, err := sb.plan(NewCompositeCache("base"), &cfg)
+	testutil.CheckNoError(t, err)
+	// The layer of the fourth command is in the cache too, but a build doesn't
+	// use it after the miss of the second one, unlike the linked layer.
+	lc := &fakeLayerCache{keySequence: []string{plans[0].CacheKey, plans[3].CacheKey, plans[4].CacheKey}}
+	sb = newStageBuilder(lc)
+	cfg = v1.Config{}
+	plans, err = sb.plan(NewCompositeCache("base"), &cfg)
+	testutil.CheckNoError(t, err)
+
+	var got []string
+	for _, p := range plans {
+		got = append(got, p.Cache)
+		if p.CacheKey == "" {
+			t.Errorf("expected a cache key for %s", p.Command)
+		}
+	}
+	testutil.CheckDeepEqual(t, []string{CacheHit, CacheMiss, "", CacheMiss, CacheHit}, got)
+	testutil.CheckDeepEqual(t, plans[4].CacheKey, sb.finalCacheKey)
+	testutil.CheckDeepEqual(t, []string{"DIR=/c"}, cfg.Env)
+}
+
+func TestDoBuildPlan(t *testing.T) {
+	contextDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(contextDir, "foo"), []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dockerfilePath := filepath.Join(contextDir, "Dockerfile")
+	if err := os.WriteFile(dockerfilePath, []byte(`
+FROM scratch AS unused
+COPY foo /foo
+
+FROM scratch AS build
+COPY foo /foo
+
+FROM build AS base
+ENV A=b
+COPY foo /bar
+
+FROM base AS final
+COPY --from=build /foo /foo
+COPY foo /baz
+
+FROM scratch AS after
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &config.KanikoOptions{
+		DockerfilePath:   dockerfilePath,
+		SrcContext:       contextDir,
+		SnapshotMode:     "full",
+		SkipUnusedStages: true,
+		Target:           "final",
+	}
+	plan, err := DoBuildPlan(opts)
+	testutil.CheckNoError(t, err)
+
+	testutil.CheckDeepEqual(t, []SkippedStage{
+		{Index: 0, Name: "unused", Reason: "unused"},
+		{Index: 4, Name: "after", Reason: "after the target stage"},
+	}, plan.SkippedStages)
+
+	var got []int
+	for _, s := range plan.Stages {
+		got = append(got, s.Index)
+	}
+	testutil.CheckDeepEqual(t, []int{1, 2, 3}, got)
+	build, base, final := plan.Stages[0], plan.Stages[1], plan.Stages[2]
+
+	testutil.CheckDeepEqual(t, []string{"/foo"}, build.SavedFiles)
+	testutil.CheckDeepEqual(t, 1, *base.BaseStage)
+	testutil.CheckDeepEqual(t, "", base.BaseImageDigest)
+	testutil.CheckDeepEqual(t, 2, *final.BaseStage)
+	testutil.CheckDeepEqual(t, true, final.Final)
+	testutil.CheckDeepEqual(t, false, build.BaseImageDigest == "")
+
+	// The key of a stage built on another one follows the key of that stage.
+	buildOnly := *NewCompositeCache(build.Commands[0].CacheKey)
+	buildOnly.AddKey("ENV A=b")
+	wantEnvKey, err := buildOnly.Hash()
+	testutil.CheckNoError(t, err)
+	testutil.CheckDeepEqual(t, wantEnvKey, base.Commands[0].CacheKey)
+
+	// Files of another stage only exist once it is built.
+	testutil.CheckDeepEqual(t, CacheUnknown, final.Commands[0].Cache)
+	testutil.CheckDeepEqual(t, "", final.Commands[0].CacheKey)
+	testutil.CheckDeepEqual(t, "the command uses files of another stage or image", final.Commands[0].Reason)
+	testutil.CheckDeepEqual(t, CacheUnknown, final.Commands[1].Cache)
+}
@@ -99,8 +99,8 @@ func CheckPushPermissions(opts *config.KanikoOptions) error {
 	} else if opts.NoPush && !opts.NoPushCache {
 		// When no push is set, we want to check permissions for the cache repo
 		// instead of the destinations
-		if isOCILayout(opts.CacheRepo) {
-			targets = []string{} // no need to check push permissions if we're just writing to disk
+		if isOCILayout(opts.CacheRepo) || cache.IsObjectStorage(opts.CacheRepo) {
+			targets = []string{} // no need to check push permissions if we're not writing to a registry
 		} else {
 			targets = []string{opts.CacheRepo}
 		}
@@ -262,26 +262,10 @@ func DoPush(image v1.Image, opts *config.KanikoOptions) error {
 
 	// continue pushing unless an error occurs
 	for _, destRef := range destRefs {
-		registryName := destRef.Repository.Registry.Name()
-		if opts.Insecure || opts.InsecureRegistries.Contains(registryName) {
-			newReg, err := name.NewRegistry(registryName, name.WeakValidation, name.Insecure)
-			if err != nil {
-				return errors.Wrap(err, "getting new insecure registry")
-			}
-			destRef.Repository.Registry = newReg
-		}
-
-		pushAuth, err := creds.GetKeychain().Resolve(destRef.Context().Registry)
+		destRef, remoteOpts, err := remoteWriteOptions(destRef, opts)
 		if err != nil {
-			return errors.Wrap(err, "resolving pushAuth")
-		}
-
-		localRt, err := util.MakeTransport(opts.RegistryOptions, registryName)
-		if err != nil {
-			return errors.Wrapf(err, "making transport for registry %q", registryName)
+			return err
 		}
-		tr := newRetry(localRt)
-		rt := &withUserAgent{t: tr}
 
 		logrus.Infof("Pushing image to %s", destRef.String())
 
@@ -291,18 +275,10 @@ func DoPush(image v1.Image, opts *config.KanikoOptions) error {
 				return err
 			}
 			digest := destRef.Context().Digest(dig.String())
-			if err := remote.Write(destRef, image, remote.WithAuth(pushAuth), remote.WithTransport(rt)); err != nil {
-				if !opts.PushIgnoreImmutableTagErrors {
-					return err
-				}
-
-				// check for known "tag immutable" errors
-				errStr := err.Error()
-				for _, candidate := range errTagImmutable {
-					if strings.Contains(errStr, candidate) {
-						logrus.Infof("Immutable tag error ignored for %s", digest)
-						return nil
-					}
+			if err := remote.Write(destRef, image, remoteOpts...); err != nil {
+				if isIgnoredPushError(err, opts) {
+					logrus.Infof("Immutable tag error ignored for %s", digest)
+					return nil
 				}
 				return err
 			}
@@ -318,6 +294,47 @@ func DoPush(image v1.Image, opts *config.KanikoOptions) error {
 	return writeImageOutputs(image, destRefs)
 }
 
+// remoteWriteOptions returns the destination, using plain HTTP for an insecure
+// registry, and the options to push to it.
+func remoteWriteOptions(destRef name.Tag, opts *config.KanikoOptions) (name.Tag, []remote.Option, error) {
+	registryName := destRef.Repository.Registry.Name()
+	if opts.Insecure || opts.InsecureRegistries.Contains(registryName) {
+		newReg, err := name.NewRegistry(registryName, name.WeakValidation, name.Insecure)
+		if err != nil {
+			return name.Tag{}, nil, errors.Wrap(err, "getting new insecure registry")
+		}
+		destRef.Repository.Registry = newReg
+	}
+
+	pushAuth, err := creds.GetKeychain().Resolve(destRef.Context().Registry)
+	if err != nil {
+		return name.Tag{}, nil, errors.Wrap(err, "resolving pushAuth")
+	}
+
+	localRt, err := util.MakeTransport(opts.RegistryOptions, registryName)
+	if err != nil {
+		return name.Tag{}, nil, errors.Wrapf(err, "making transport for registry %q", registryName)
+	}
+	tr := newRetry(localRt)
+	rt := &withUserAgent{t: tr}
+	return destRef, []remote.Option{remote.WithAuth(pushAuth), remote.WithTransport(rt)}, nil
+}
+
+// isIgnoredPushError returns true if err is a known "tag immutable" error and
+// --push-ignore-immutable-tag-errors is set.
+func isIgnoredPushError(err error, opts *config.KanikoOptions) bool {
+	if !opts.PushIgnoreImmutableTagErrors {
+		return false
+	}
+	errStr := err.Error()
+	for _, candidate := range errTagImmutable {
+		if strings.Contains(errStr, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
 func writeImageOutputs(image v1.Image, destRefs []name.Tag) error {
 	dir := os.Getenv("BUILDER_OUTPUT")
 	if dir == "" {
@@ -352,24 +369,7 @@ func writeImageOutputs(image v1.Image, destRefs []name.Tag) error {
 // pushLayerToCache pushes layer (tagged with cacheKey) to opts.CacheRepo
 // if opts.CacheRepo doesn't exist, infer the cache from the given destination
 func pushLayerToCache(opts *config.KanikoOptions, cacheKey string, tarPath string, createdBy string) error {
-	var layerOpts []tarball.LayerOption
-	if opts.CompressedCaching == true {
-		layerOpts = append(layerOpts, tarball.WithCompressedCaching)
-	}
-
-	if opts.CompressionLevel > 0 {
-		layerOpts = append(layerOpts, tarball.WithCompressionLevel(opts.CompressionLevel))
-	}
-
-	switch opts.Compression {
-	case config.ZStd:
-		layerOpts = append(layerOpts, tarball.WithCompression("zstd"), tarball.WithMediaType(types.OCILayerZStd))
-
-	case config.GZip:
-		// layer already gzipped by default
-	}
-
-	layer, err := tarball.LayerFromFile(tarPath, layerOpts...)
+	layer, err := cacheLayerFromFile(opts, tarPath)
 	if err != nil {
 		return err
 	}
@@ -410,6 +410,63 @@ func pushLayerToCache(opts *config.KanikoOptions, cacheKey string, tarPath strin
 	return DoPush(empty, &cacheOpts)
 }
 
+// cacheLayerFromFile returns the layer of the snapshot at tarPath, compressed
+// as configured for the cache.
+func cacheLayerFromFile(opts *config.KanikoOptions, tarPath string) (v1.Layer, error) {
+	var layerOpts []tarball.LayerOption
+	if opts.CompressedCaching == true {
+		layerOpts = append(layerOpts, tarball.WithCompressedCaching)
+	}
+
+	if opts.CompressionLevel > 0 {
+		layerOpts = append(layerOpts, tarball.WithCompressionLevel(opts.CompressionLevel))
+	}
+
+	switch opts.Compression {
+	case config.ZStd:
+		layerOpts = append(layerOpts, tarball.WithCompression("zstd"), tarball.WithMediaType(types.OCILayerZStd))
+
+	case config.GZip:
+		// layer already gzipped by default
+	}
+
+	return tarball.LayerFromFile(tarPath, layerOpts...)
+}
+
+// pushLayerToCacheIndex returns a cachePusher which pushes the layer to the
+// cache repo and adds it to the cache index.
+func pushLayerToCacheIndex(index *cache.IndexCache) cachePusher {
+	return func(opts *config.KanikoOptions, cacheKey string, tarPath string, createdBy string) error {
+		if opts.NoPushCache {
+			logrus.Info("Skipping push to cache index due to --no-push-cache flag")
+			return nil
+		}
+		layer, err := cacheLayerFromFile(opts, tarPath)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Pushing layer %s to cache index now", cacheKey)
+		return index.PushLayer(cacheKey, layer, createdBy)
+	}
+}
+
+// pushLayerToObjectStorage returns the cachePusher pushing layers to the object
+// storage cache, whose client is shared by the stages of the build.
+func pushLayerToObjectStorage(objectCache *cache.ObjectStorageCache) cachePusher {
+	return func(opts *config.KanikoOptions, cacheKey string, tarPath string, createdBy string) error {
+		if opts.NoPushCache {
+			logrus.Info("Skipping push to cache due to --no-push-cache flag")
+			return nil
+		}
+		layer, err := cacheLayerFromFile(opts, tarPath)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Pushing layer %s to cache now", cacheKey)
+		return objectCache.PushLayer(cacheKey, layer, createdBy)
+	}
+}
+
 // setDummyDestinations sets the dummy destinations required to generate new
 // tag names for tarPath in DoPush.
 func setDummyDestinations(opts *config.KanikoOptions) {
@@ -18,21 +18,27 @@ package executor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/GoogleContainerTools/kaniko/pkg/cache"
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
 	"github.com/GoogleContainerTools/kaniko/testutil"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/google/go-containerregistry/pkg/v1/validate"
 	"github.com/spf13/afero"
 )
@@ -388,6 +394,13 @@ func TestCheckPushPermissions(t *testing.T) {
 			checkPushPermsExpectedCallCount: 0,
 			noPush:                          true,
 		},
+		{
+			description:                     "no push and cache repo is an S3 bucket",
+			destinations:                    []string{"us-central1-docker.pkg.dev/prj/test-image"},
+			cacheRepo:                       "s3://bucket/cache",
+			checkPushPermsExpectedCallCount: 0,
+			noPush:                          true,
+		},
 	}
 
 	checkRemotePushPermission = fakeCheckPushPermission
@@ -505,3 +518,90 @@ func TestWriteDigestFile(t *testing.T) {
 		}
 	})
 }
+
+func Test_pushLayerToCacheIndex_NoPushCache(t *testing.T) {
+	writes := 0
+	reg := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writes++
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &config.KanikoOptions{CacheRepo: u.Host + "/cache", NoPushCache: true}
+	push := pushLayerToCacheIndex(&cache.IndexCache{Opts: opts})
+	testutil.CheckNoError(t, push(opts, "ck", layerTar(t), "RUN make"))
+	testutil.CheckDeepEqual(t, 0, writes)
+}
+
+// layerTar writes the content of a random layer to a tarball and returns its
+// path.
+func layerTar(t *testing.T) string {
+	t.Helper()
+	layer, err := random.Layer(100, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	tarPath := filepath.Join(t.TempDir(), "layer.tar")
+	f, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, rc); err != nil {
+		t.Fatal(err)
+	}
+	return tarPath
+}
+
+// countingStore is an ObjectStore counting the objects written to it.
+type countingStore struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (s *countingStore) Read(context.Context, string) (io.ReadCloser, error) {
+	return nil, os.ErrNotExist
+}
+
+func (s *countingStore) Write(_ context.Context, key string, r io.Reader) error {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = append(s.writes, key)
+	return nil
+}
+
+func Test_pushLayerToObjectStorage(t *testing.T) {
+	opts := &config.KanikoOptions{CacheRepo: "s3://bucket/cache", Cache: true, CacheFrom: []string{"some-image"}}
+	// The stages push to the object storage cache of the build.
+	objectCache := repoCacheOf[*cache.ObjectStorageCache](newLayerCache(opts))
+	if objectCache == nil {
+		t.Fatal("expected the object storage cache of the layer cache")
+	}
+	store := &countingStore{}
+	objectCache.Store = store
+	push := pushLayerToObjectStorage(objectCache)
+	for _, ck := range []string{"first", "second"} {
+		testutil.CheckNoError(t, push(opts, ck, layerTar(t), "RUN make"))
+	}
+	testutil.CheckDeepEqual(t, 4, len(store.writes))
+	testutil.CheckDeepEqual(t, "keys/second", store.writes[3])
+
+	opts.NoPushCache = true
+	testutil.CheckNoError(t, push(opts, "third", layerTar(t), "RUN make"))
+	testutil.CheckDeepEqual(t, 4, len(store.writes))
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/cache"
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// WarmLayerCache copies the cached layers the build of opts would use from
+// its cache repo to the 'oci:' cache repo layoutRepo, so that a build with
+// --cache-repo=layoutRepo finds them without access to the cache repo. The
+// cache keys are computed like DoBuildPlan does. It returns the cache keys of
+// the copied layers.
+func WarmLayerCache(opts *config.KanikoOptions, layoutRepo string) ([]string, error) {
+	if !isOCILayout(layoutRepo) {
+		return nil, errors.Errorf("%s is not an 'oci:' cache repo", layoutRepo)
+	}
+	plan, err := DoBuildPlan(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	layoutOpts := *opts
+	layoutOpts.CacheRepo = layoutRepo
+	layoutCache := &cache.LayoutCache{Opts: &layoutOpts}
+	layerCache := newLayerCache(opts)
+
+	var warmed []string
+	for _, stage := range plan.Stages {
+		for _, command := range stage.Commands {
+			if command.Cache != CacheHit {
+				continue
+			}
+			ck := command.CacheKey
+			if _, err := layoutCache.RetrieveLayer(ck); err == nil {
+				logrus.Infof("Layer of %s already in cache: %s", command.Command, ck)
+				continue
+			}
+			img, err := layerCache.RetrieveLayer(ck)
+			if err != nil {
+				return warmed, errors.Wrapf(err, "retrieving layer of %s", command.Command)
+			}
+			dest, err := cache.Destination(&layoutOpts, ck)
+			if err != nil {
+				return warmed, err
+			}
+			if err := writeLayout(strings.TrimPrefix(dest, "oci:"), img); err != nil {
+				return warmed, errors.Wrapf(err, "writing layer of %s", command.Command)
+			}
+			logrus.Infof("Wrote layer of %s to cache: %s", command.Command, ck)
+			warmed = append(warmed, ck)
+		}
+	}
+	return warmed, nil
+}
+
+// writeLayout writes the image to a new OCI image layout at path, replacing
+// the one there once it is complete.
+func writeLayout(path string, img v1.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	p, err := layout.Write(tmp, empty.Index)
+	if err != nil {
+		return errors.Wrap(err, "writing empty layout")
+	}
+	if err := p.AppendImage(img); err != nil {
+		return errors.Wrap(err, "appending image")
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
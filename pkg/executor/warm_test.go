@@ -0,0 +1,104 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/constants"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+)
+
+func TestWarmLayerCache(t *testing.T) {
+	testDir, fn := setupMultistageTests(t)
+	defer fn()
+	dockerfile := `
+FROM scratch
+COPY foo/bam.txt copied/
+ENV A=b
+COPY exec copied/
+`
+	dockerfilePath := filepath.Join(testDir, "workspace", "Dockerfile")
+	if err := os.WriteFile(dockerfilePath, []byte(dockerfile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cacheDir := t.TempDir()
+	opts := &config.KanikoOptions{
+		DockerfilePath:  dockerfilePath,
+		SrcContext:      filepath.Join(testDir, "workspace"),
+		SnapshotMode:    constants.SnapshotModeFull,
+		Cache:           true,
+		CacheCopyLayers: true,
+		CacheRepo:       "oci:" + filepath.Join(cacheDir, "build"),
+		CacheOptions:    config.CacheOptions{CacheTTL: time.Hour},
+	}
+
+	// A build pushes its layers to the cache repo under their cache keys.
+	_, err := DoBuild(opts)
+	testutil.CheckNoError(t, err)
+	built := cachedKeys(t, cacheDir, "build")
+	testutil.CheckDeepEqual(t, 2, len(built))
+
+	// Warming computes the same keys, and only copies the missing layers.
+	layoutRepo := "oci:" + filepath.Join(cacheDir, "warm")
+	warmed, err := WarmLayerCache(opts, layoutRepo)
+	testutil.CheckNoError(t, err)
+	sort.Strings(warmed)
+	testutil.CheckDeepEqual(t, built, warmed)
+	testutil.CheckDeepEqual(t, built, cachedKeys(t, cacheDir, "warm"))
+
+	warmed, err = WarmLayerCache(opts, layoutRepo)
+	testutil.CheckErrorAndDeepEqual(t, false, err, 0, len(warmed))
+
+	// A build with the warmed cache repo finds all its layers there.
+	warmOpts := *opts
+	warmOpts.CacheRepo = layoutRepo
+	plan, err := DoBuildPlan(&warmOpts)
+	testutil.CheckNoError(t, err)
+	var got []string
+	for _, command := range plan.Stages[0].Commands {
+		got = append(got, command.Cache)
+	}
+	testutil.CheckDeepEqual(t, []string{CacheHit, "", CacheHit}, got)
+}
+
+// cachedKeys returns the cache keys of the layers of the 'oci:' cache repo
+// repo in dir.
+func cachedKeys(t *testing.T, dir, repo string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	testutil.CheckNoError(t, err)
+	var keys []string
+	for _, entry := range entries {
+		if ck, ok := strings.CutPrefix(entry.Name(), repo+":"); ok {
+			keys = append(keys, ck)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestWarmLayerCache_NotLayout(t *testing.T) {
+	_, err := WarmLayerCache(&config.KanikoOptions{}, "gcr.io/foo/cache")
+	testutil.CheckError(t, true, err)
+}
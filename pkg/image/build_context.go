@@ -0,0 +1,177 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/buildcontext"
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/constants"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ociRefNameAnnotation holds the tag of an image in an OCI layout.
+const ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+// RetrieveBuildContextImage returns the image of a build context given a name
+// with --build-context, as resolved by buildcontext.UnpackNamedBuildContexts.
+// A directory is turned into an image with a single layer holding its files.
+func RetrieveBuildContextImage(source string, opts *config.KanikoOptions) (v1.Image, error) {
+	switch {
+	case strings.HasPrefix(source, constants.DockerImageBuildContextPrefix):
+		return RetrieveRemoteImage(strings.TrimPrefix(source, constants.DockerImageBuildContextPrefix), opts.RegistryOptions, opts.CustomPlatform)
+	case strings.HasPrefix(source, constants.OCILayoutBuildContextPrefix):
+		return ociLayoutImage(source, opts.CustomPlatform)
+	case strings.HasPrefix(source, constants.LocalDirBuildContextPrefix):
+		return dirImage(strings.TrimPrefix(source, constants.LocalDirBuildContextPrefix))
+	}
+	return nil, fmt.Errorf("unsupported build context %s", source)
+}
+
+func ociLayoutImage(source, customPlatform string) (v1.Image, error) {
+	path, ref := buildcontext.ParseOCILayout(source)
+	logrus.Infof("Retrieving image from OCI layout %s%s", path, ref)
+	p, err := layout.FromPath(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading OCI layout %s", path)
+	}
+	index, err := p.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var desc *v1.Descriptor
+	for i, m := range manifest.Manifests {
+		switch {
+		case strings.HasPrefix(ref, "@") && m.Digest.String() == ref[1:],
+			strings.HasPrefix(ref, ":") && m.Annotations[ociRefNameAnnotation] == ref[1:],
+			ref == "" && len(manifest.Manifests) == 1:
+			desc = &manifest.Manifests[i]
+		}
+	}
+	if desc == nil {
+		if ref == "" {
+			return nil, fmt.Errorf("OCI layout %s holds %d images, select one with @<digest> or :<tag>", path, len(manifest.Manifests))
+		}
+		return nil, fmt.Errorf("image %s not found in OCI layout %s", ref, path)
+	}
+	if !desc.MediaType.IsIndex() {
+		return index.Image(desc.Digest)
+	}
+
+	// Select the image of the platform being built for from an image index.
+	platform := v1.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+	if customPlatform != "" {
+		custom, err := v1.ParsePlatform(customPlatform)
+		if err != nil {
+			return nil, err
+		}
+		platform = *custom
+	}
+	child, err := index.ImageIndex(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	childManifest, err := child.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range childManifest.Manifests {
+		if m.Platform != nil && m.Platform.Satisfies(platform) {
+			return child.Image(m.Digest)
+		}
+	}
+	return nil, fmt.Errorf("no image for platform %s in OCI layout %s", platform.String(), path)
+}
+
+// dirImage returns an image with a single layer holding the files of dir.
+func dirImage(dir string) (v1.Image, error) {
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		r, w := io.Pipe()
+		go func() {
+			w.CloseWithError(tarDir(dir, w))
+		}()
+		return r, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating layer of %s", dir)
+	}
+	return mutate.AppendLayers(empty.Image, layer)
+}
+
+// tarDir writes the files of dir to w, with paths relative to dir.
+func tarDir(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	if err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uname, hdr.Gname = "", ""
+		hdr.Format = tar.FormatPAX
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	}); err != nil {
+		return err
+	}
+	return tw.Close()
+}
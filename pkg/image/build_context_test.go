@@ -0,0 +1,130 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package image
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+func digest(t *testing.T, img v1.Image) string {
+	t.Helper()
+	d, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.String()
+}
+
+func Test_RetrieveBuildContextImage_OCILayout(t *testing.T) {
+	images := make([]v1.Image, 3)
+	for i := range images {
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		images[i] = img
+	}
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: images[1], Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: images[2], Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	)
+
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendImage(images[0], layout.WithAnnotations(map[string]string{ociRefNameAnnotation: "v1"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendIndex(index, layout.WithAnnotations(map[string]string{ociRefNameAnnotation: "multi"})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		source   string
+		platform string
+		want     v1.Image
+		shdErr   bool
+	}{
+		{name: "tag", source: "oci-layout://" + dir + ":v1", want: images[0]},
+		{name: "digest", source: "oci-layout://" + dir + "@" + digest(t, images[0]), want: images[0]},
+		{name: "platform of an index", source: "oci-layout://" + dir + ":multi", platform: "linux/arm64", want: images[2]},
+		{name: "missing platform", source: "oci-layout://" + dir + ":multi", platform: "linux/s390x", shdErr: true},
+		{name: "missing tag", source: "oci-layout://" + dir + ":v2", shdErr: true},
+		{name: "ambiguous", source: "oci-layout://" + dir, shdErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img, err := RetrieveBuildContextImage(test.source, &config.KanikoOptions{CustomPlatform: test.platform})
+			testutil.CheckError(t, test.shdErr, err)
+			if !test.shdErr {
+				testutil.CheckDeepEqual(t, digest(t, test.want), digest(t, img))
+			}
+		})
+	}
+}
+
+func Test_RetrieveSourceImage_BuildContextDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file"), []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := RetrieveSourceImage(config.KanikoStage{
+		Stage: instructions.Stage{BaseName: "deps"},
+	}, &config.KanikoOptions{BuildContexts: map[string]string{"deps": "dir://" + dir}})
+	testutil.CheckError(t, false, err)
+
+	files := map[string]string{}
+	tr := tar.NewReader(mutate.Extract(img))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(content) + hdr.Linkname
+	}
+	testutil.CheckDeepEqual(t, map[string]string{"link": "sub/file", "sub": "", "sub/file": "content"}, files)
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/GoogleContainerTools/kaniko/pkg/cache"
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/constants"
+	"github.com/GoogleContainerTools/kaniko/pkg/dockerfile"
 	"github.com/GoogleContainerTools/kaniko/pkg/image/remote"
 	"github.com/GoogleContainerTools/kaniko/pkg/timing"
 	"github.com/GoogleContainerTools/kaniko/pkg/util"
@@ -53,11 +54,23 @@ func RetrieveSourceImage(stage config.KanikoStage, opts *config.KanikoOptions) (
 			buildArgs = append(buildArgs, fmt.Sprintf("%s=%s", arg.Key, arg.ValueString()))
 		}
 	}
-	buildArgs = append(buildArgs, opts.BuildArgs...)
+	platformBuildArgs, err := dockerfile.BuildArgsWithPlatform(opts.BuildArgs, opts.CustomPlatform)
+	if err != nil {
+		return nil, err
+	}
+	buildArgs = append(buildArgs, platformBuildArgs...)
 	currentBaseName, err := util.ResolveEnvironmentReplacement(stage.BaseName, buildArgs, false)
 	if err != nil {
 		return nil, err
 	}
+	// The platform of FROM --platform takes precedence over --custom-platform
+	platform := opts.CustomPlatform
+	if stage.Platform != "" {
+		platform, err = util.ResolveEnvironmentReplacement(stage.Platform, buildArgs, false)
+		if err != nil {
+			return nil, err
+		}
+	}
 	// First, check if the base image is a scratch image
 	if currentBaseName == constants.NoBaseImage {
 		logrus.Info("No base image, nothing to extract")
@@ -68,11 +81,17 @@ func RetrieveSourceImage(stage config.KanikoStage, opts *config.KanikoOptions) (
 	if stage.BaseImageStoredLocally {
 		return retrieveTarImage(stage.BaseImageIndex)
 	}
+	// Next, check if the base image is a build context given a name with
+	// --build-context, which also takes the place of a stage of the same name
+	if source, ok := opts.BuildContexts[currentBaseName]; ok {
+		logrus.Infof("Base image %s found in build context %s", currentBaseName, source)
+		return RetrieveBuildContextImage(source, opts)
+	}
 
 	// Finally, check if local caching is enabled
 	// If so, look in the local cache before trying the remote registry
 	if opts.Cache && opts.CacheDir != "" {
-		cachedImage, err := cachedImage(opts, currentBaseName)
+		cachedImage, err := cachedImage(opts, currentBaseName, platform)
 		if err != nil {
 			switch {
 			case cache.IsNotFound(err):
@@ -88,7 +107,7 @@ func RetrieveSourceImage(stage config.KanikoStage, opts *config.KanikoOptions) (
 	}
 
 	// Otherwise, initialize image as usual
-	return RetrieveRemoteImage(currentBaseName, opts.RegistryOptions, opts.CustomPlatform)
+	return RetrieveRemoteImage(currentBaseName, opts.RegistryOptions, platform)
 }
 
 func tarballImage(index int) (v1.Image, error) {
@@ -97,7 +116,7 @@ func tarballImage(index int) (v1.Image, error) {
 	return tarball.ImageFromPath(tarPath, nil)
 }
 
-func cachedImage(opts *config.KanikoOptions, image string) (v1.Image, error) {
+func cachedImage(opts *config.KanikoOptions, image, platform string) (v1.Image, error) {
 	ref, err := name.ParseReference(image, name.WeakValidation)
 	if err != nil {
 		return nil, err
@@ -107,7 +126,7 @@ func cachedImage(opts *config.KanikoOptions, image string) (v1.Image, error) {
 	if d, ok := ref.(name.Digest); ok {
 		cacheKey = d.DigestStr()
 	} else {
-		image, err := remote.RetrieveRemoteImage(image, opts.RegistryOptions, opts.CustomPlatform)
+		image, err := remote.RetrieveRemoteImage(image, opts.RegistryOptions, platform)
 		if err != nil {
 			return nil, err
 		}
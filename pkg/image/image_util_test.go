@@ -31,7 +31,7 @@ import (
 )
 
 var (
-	dockerfile = `
+	testDockerfile = `
 	FROM gcr.io/distroless/base:latest as base
 	COPY . .
 
@@ -45,7 +45,7 @@ var (
 )
 
 func Test_StandardImage(t *testing.T) {
-	stages, err := parse(dockerfile)
+	stages, err := parse(testDockerfile)
 	if err != nil {
 		t.Error(err)
 	}
@@ -63,8 +63,36 @@ func Test_StandardImage(t *testing.T) {
 	testutil.CheckErrorAndDeepEqual(t, false, err, nil, actual)
 }
 
+func Test_StandardImage_Platform(t *testing.T) {
+	stages, err := parse(`
+	FROM --platform=$BUILDPLATFORM golang:1.24 AS build
+	FROM gcr.io/distroless/static:nonroot`)
+	if err != nil {
+		t.Error(err)
+	}
+	original := RetrieveRemoteImage
+	defer func() {
+		RetrieveRemoteImage = original
+	}()
+	var platforms []string
+	RetrieveRemoteImage = func(image string, opts config.RegistryOptions, customPlatform string) (v1.Image, error) {
+		platforms = append(platforms, customPlatform)
+		return nil, nil
+	}
+	opts := &config.KanikoOptions{CustomPlatform: "linux/s390x"}
+	for _, stage := range stages {
+		_, err := RetrieveSourceImage(config.KanikoStage{Stage: stage}, opts)
+		testutil.CheckNoError(t, err)
+	}
+	buildPlatform := platforms[0]
+	testutil.CheckDeepEqual(t, []string{buildPlatform, "linux/s390x"}, platforms)
+	if buildPlatform == "" || buildPlatform == "$BUILDPLATFORM" {
+		t.Errorf("expected FROM --platform to be resolved to the build platform, got %q", buildPlatform)
+	}
+}
+
 func Test_ScratchImage(t *testing.T) {
-	stages, err := parse(dockerfile)
+	stages, err := parse(testDockerfile)
 	if err != nil {
 		t.Error(err)
 	}
@@ -76,7 +104,7 @@ func Test_ScratchImage(t *testing.T) {
 }
 
 func Test_TarImage(t *testing.T) {
-	stages, err := parse(dockerfile)
+	stages, err := parse(testDockerfile)
 	if err != nil {
 		t.Error(err)
 	}
@@ -97,7 +125,7 @@ func Test_TarImage(t *testing.T) {
 }
 
 func Test_ScratchImageFromMirror(t *testing.T) {
-	stages, err := parse(dockerfile)
+	stages, err := parse(testDockerfile)
 	if err != nil {
 		t.Error(err)
 	}
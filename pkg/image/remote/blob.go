@@ -0,0 +1,98 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/pkg/creds"
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+)
+
+// BlobOpener opens the blob with the given digest and size from offset, to
+// resume its download. It returns the offset the blob is actually read from,
+// which is 0 if the registry doesn't support range requests.
+type BlobOpener func(digest v1.Hash, size, offset int64) (io.ReadCloser, int64, error)
+
+// RetrieveRemoteBlobOpener returns a BlobOpener for the blobs of the specified
+// image, from the registry it is retrieved from.
+func RetrieveRemoteBlobOpener(image string, opts config.RegistryOptions, customPlatform string) (BlobOpener, error) {
+	return retrieve(image, opts, customPlatform, func(ref name.Reference, options ...remote.Option) (BlobOpener, error) {
+		if _, err := remote.Head(ref, options...); err != nil {
+			return nil, err
+		}
+		return blobOpener(ref.Context(), opts)
+	})
+}
+
+func blobOpener(repo name.Repository, opts config.RegistryOptions) (BlobOpener, error) {
+	tr, err := util.MakeTransport(opts, repo.RegistryStr())
+	if err != nil {
+		return nil, err
+	}
+	auth, err := creds.GetKeychain().Resolve(repo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving credentials for %s", repo)
+	}
+	rt, err := transport.NewWithContext(context.Background(), repo.Registry, auth, tr, []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "authenticating to %s", repo)
+	}
+	client := &http.Client{Transport: rt}
+
+	return func(digest v1.Hash, size, offset int64) (io.ReadCloser, int64, error) {
+		u := url.URL{
+			Scheme: repo.Registry.Scheme(),
+			Host:   repo.RegistryStr(),
+			Path:   fmt.Sprintf("/v2/%s/blobs/%s", repo.RepositoryStr(), digest),
+		}
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, size-1))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := transport.CheckError(resp, http.StatusOK, http.StatusPartialContent); err != nil {
+			resp.Body.Close()
+			return nil, 0, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp.Body, 0, nil
+		}
+		if contentRange := resp.Header.Get("Content-Range"); !strings.HasPrefix(contentRange, fmt.Sprintf("bytes %d-", offset)) {
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("unexpected content range %q of blob %s from offset %d", contentRange, digest, offset)
+		}
+		return resp.Body, offset, nil
+	}, nil
+}
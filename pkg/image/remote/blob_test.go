@@ -0,0 +1,74 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/config"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func Test_RetrieveRemoteBlobOpener(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := u.Host + "/base:latest"
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layers[0].Digest()
+	testutil.CheckNoError(t, err)
+	size, err := layers[0].Size()
+	testutil.CheckNoError(t, err)
+	rc, err := layers[0].Compressed()
+	testutil.CheckNoError(t, err)
+	data, err := io.ReadAll(rc)
+	testutil.CheckNoError(t, err)
+
+	open, err := RetrieveRemoteBlobOpener(image, config.RegistryOptions{}, "linux/amd64")
+	testutil.CheckNoError(t, err)
+	for _, offset := range []int64{0, 100} {
+		rc, got, err := open(digest, size, offset)
+		testutil.CheckErrorAndDeepEqual(t, false, err, offset, got)
+		content, err := io.ReadAll(rc)
+		testutil.CheckErrorAndDeepEqual(t, false, err, data[offset:], content)
+		rc.Close()
+	}
+}
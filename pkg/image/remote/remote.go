@@ -19,6 +19,7 @@ package remote
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/GoogleContainerTools/kaniko/pkg/creds"
@@ -32,23 +33,57 @@ import (
 )
 
 var (
-	manifestCache   = make(map[string]v1.Image)
-	remoteImageFunc = remote.Image
+	// manifestCacheMu guards manifestCache, as images can be retrieved
+	// concurrently.
+	manifestCacheMu      sync.Mutex
+	manifestCache        = make(map[string]v1.Image)
+	remoteImageFunc      = remote.Image
+	remoteDescriptorFunc = remote.Get
 )
 
 // RetrieveRemoteImage retrieves the manifest for the specified image from the specified registry
 func RetrieveRemoteImage(image string, opts config.RegistryOptions, customPlatform string) (v1.Image, error) {
 	logrus.Infof("Retrieving image manifest %s", image)
 
-	cachedRemoteImage := manifestCache[image]
+	// The image of an image index depends on the platform.
+	key := manifestCacheKey(image, customPlatform)
+	manifestCacheMu.Lock()
+	cachedRemoteImage := manifestCache[key]
+	manifestCacheMu.Unlock()
 	if cachedRemoteImage != nil {
 		logrus.Infof("Returning cached image manifest")
 		return cachedRemoteImage, nil
 	}
 
+	remoteImage, err := retrieve(image, opts, customPlatform, remoteImageFunc)
+	if remoteImage != nil {
+		manifestCacheMu.Lock()
+		manifestCache[key] = remoteImage
+		manifestCacheMu.Unlock()
+	}
+
+	return remoteImage, err
+}
+
+func manifestCacheKey(image, customPlatform string) string {
+	return image + "|" + customPlatform
+}
+
+// RetrieveRemoteDescriptor retrieves the descriptor of the specified image
+// from the specified registry, without resolving an image index to the image
+// of customPlatform.
+func RetrieveRemoteDescriptor(image string, opts config.RegistryOptions, customPlatform string) (*remote.Descriptor, error) {
+	logrus.Infof("Retrieving image descriptor %s", image)
+	return retrieve(image, opts, customPlatform, remoteDescriptorFunc)
+}
+
+// retrieve fetches the specified image with fetch from the registries it is
+// mapped to, falling back to its own registry.
+func retrieve[T any](image string, opts config.RegistryOptions, customPlatform string, fetch func(name.Reference, ...remote.Option) (T, error)) (T, error) {
+	var zero T
 	ref, err := name.ParseReference(image, name.WeakValidation)
 	if err != nil {
-		return nil, err
+		return zero, err
 	}
 
 	if newRegURLs, found := opts.RegistryMaps[ref.Context().RegistryStr()]; found {
@@ -60,29 +95,27 @@ func RetrieveRemoteImage(image string, opts config.RegistryOptions, customPlatfo
 
 			remappedRepository, err := remapRepository(ref.Context(), regToMapTo, repositoryPrefix, insecurePull)
 			if err != nil {
-				return nil, err
+				return zero, err
 			}
 
 			remappedRef := setNewRepository(ref, remappedRepository)
 
 			logrus.Infof("Retrieving image %s from mapped registry %s", remappedRef, regToMapTo)
-			retryFunc := func() (v1.Image, error) {
-				return remoteImageFunc(remappedRef, remoteOptions(regToMapTo, opts, customPlatform)...)
+			retryFunc := func() (T, error) {
+				return fetch(remappedRef, remoteOptions(regToMapTo, opts, customPlatform)...)
 			}
 
-			var remoteImage v1.Image
-			if remoteImage, err = util.RetryWithResult(retryFunc, opts.ImageDownloadRetry, 1000); err != nil {
+			remoteImage, err := util.RetryWithResult(retryFunc, opts.ImageDownloadRetry, 1000)
+			if err != nil {
 				logrus.Warnf("Failed to retrieve image %s from remapped registry %s: %s. Will try with the next registry, or fallback to the original registry.", remappedRef, regToMapTo, err)
 				continue
 			}
 
-			manifestCache[image] = remoteImage
-
 			return remoteImage, nil
 		}
 
 		if len(newRegURLs) > 0 && opts.SkipDefaultRegistryFallback {
-			return nil, fmt.Errorf("image not found on any configured mapped registries for %s", ref)
+			return zero, fmt.Errorf("image not found on any configured mapped registries for %s", ref)
 		}
 	}
 
@@ -90,23 +123,18 @@ func RetrieveRemoteImage(image string, opts config.RegistryOptions, customPlatfo
 	if opts.InsecurePull || opts.InsecureRegistries.Contains(registryName) {
 		newReg, err := name.NewRegistry(registryName, name.WeakValidation, name.Insecure)
 		if err != nil {
-			return nil, err
+			return zero, err
 		}
 		ref = setNewRegistry(ref, newReg)
 	}
 
 	logrus.Infof("Retrieving image %s from registry %s", ref, registryName)
 
-	retryFunc := func() (v1.Image, error) {
-		return remoteImageFunc(ref, remoteOptions(registryName, opts, customPlatform)...)
+	retryFunc := func() (T, error) {
+		return fetch(ref, remoteOptions(registryName, opts, customPlatform)...)
 	}
 
-	var remoteImage v1.Image
-	if remoteImage, err = util.RetryWithResult(retryFunc, opts.ImageDownloadRetry, 1000); remoteImage != nil {
-		manifestCache[image] = remoteImage
-	}
-
-	return remoteImage, err
+	return util.RetryWithResult(retryFunc, opts.ImageDownloadRetry, 1000)
 }
 
 // remapRepository adds the {repositoryPrefix}/ to the original repo, and normalizes with an additional library/ if necessary
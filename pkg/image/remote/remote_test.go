@@ -18,11 +18,17 @@ package remote
 
 import (
 	"errors"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/GoogleContainerTools/kaniko/pkg/config"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -180,13 +186,65 @@ func Test_RetrieveRemoteImage_manifestCache(t *testing.T) {
 		t.Fatal("Expected call to fail because there is no manifest for this image.")
 	}
 
-	manifestCache[nonExistingImageName] = &mockImage{}
+	manifestCache[manifestCacheKey(nonExistingImageName, "")] = &mockImage{}
 
 	if image, err := RetrieveRemoteImage(nonExistingImageName, config.RegistryOptions{}, ""); image == nil || err != nil {
 		t.Fatal("Expected call to succeed because there is a manifest for this image in the cache.")
 	}
 }
 
+func Test_RetrieveRemoteImage_manifestCachePlatforms(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := u.Host + "/multi:latest"
+
+	want := map[string]v1.Hash{}
+	var idx v1.ImageIndex = empty.Index
+	for _, p := range []string{"linux/amd64", "linux/arm64"} {
+		platform, err := v1.ParsePlatform(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want[p], err = img.Digest(); err != nil {
+			t.Fatal(err)
+		}
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: platform}})
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(ref, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	remoteImageFunc = remote.Image
+	manifestCache = make(map[string]v1.Image)
+	opts := config.RegistryOptions{InsecurePull: true}
+	// The same image retrieved for another platform isn't the cached one.
+	for _, p := range []string{"linux/amd64", "linux/arm64", "linux/amd64"} {
+		img, err := RetrieveRemoteImage(image, opts, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want[p] {
+			t.Errorf("Expected image %s for platform %s, got %s", want[p], p, got)
+		}
+	}
+}
+
 func Test_RetrieveRemoteImage_skipFallback(t *testing.T) {
 	registryMirror := "some-registry"
 
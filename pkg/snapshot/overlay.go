@@ -0,0 +1,392 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// opaqueXattrs are the extended attributes overlayfs marks a directory of the
+// upper directory with when it hides the content of the lower directory.
+var opaqueXattrs = []string{"trusted.overlay.opaque", "user.overlay.opaque"}
+
+const capabilityXattr = "security.capability"
+
+// Overlay runs commands against an overlay mount of the root directory, so
+// that the files they change and delete are the content of its upper
+// directory instead of having to be found by walking the whole filesystem.
+type Overlay struct {
+	root string
+	dir  string
+}
+
+// NewOverlay returns an Overlay of root, which keeps its upper, work and
+// merged directories in dir. dir must not be on an overlay filesystem.
+func NewOverlay(root, dir string) *Overlay {
+	return &Overlay{root: root, dir: dir}
+}
+
+func (o *Overlay) upper() string  { return filepath.Join(o.dir, "upper") }
+func (o *Overlay) work() string   { return filepath.Join(o.dir, "work") }
+func (o *Overlay) merged() string { return filepath.Join(o.dir, "merged") }
+
+// Mount mounts the overlay and returns the directory it is mounted at. The
+// paths of the ignore list, like /proc or the kaniko directory, are bound
+// into it, so that they are used as is instead of being copied up.
+func (o *Overlay) Mount() (string, error) {
+	if err := os.RemoveAll(o.dir); err != nil {
+		return "", errors.Wrap(err, "removing overlay dir")
+	}
+	for _, dir := range []string{o.upper(), o.work(), o.merged()} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", errors.Wrap(err, "creating overlay dir")
+		}
+	}
+	// Apply needs the upper directory to have the full copies of the files
+	// and directories which changed, not only their metadata or a redirect to
+	// the lower directory. Kernels which don't know these options fail the
+	// mount, and the full filesystem is snapshotted instead.
+	data := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s,metacopy=off,redirect_dir=off,index=off", o.root, o.upper(), o.work())
+	if err := unix.Mount("overlay", o.merged(), "overlay", 0, data); err != nil {
+		return "", errors.Wrapf(err, "mounting overlay of %s", o.root)
+	}
+	if err := unix.Mount("", o.merged(), "", unix.MS_PRIVATE|unix.MS_REC, ""); err != nil {
+		o.Unmount()
+		return "", errors.Wrap(err, "making overlay private")
+	}
+	if err := o.bindIgnored(); err != nil {
+		o.Unmount()
+		return "", err
+	}
+	return o.merged(), nil
+}
+
+// bindIgnored binds the existing paths of the ignore list below the root
+// into the overlay.
+func (o *Overlay) bindIgnored() error {
+	var paths []string
+	for _, entry := range util.IgnoreList() {
+		if !entry.PrefixMatchOnly {
+			paths = append(paths, entry.Path)
+		}
+	}
+	sort.Strings(paths)
+
+	var bound []string
+	for _, path := range paths {
+		rel, err := filepath.Rel(o.root, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if isBelowAny(path, bound) {
+			continue
+		}
+		fi, err := os.Lstat(path)
+		if err != nil || fi.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		target := filepath.Join(o.merged(), rel)
+		if _, err := os.Lstat(target); err != nil {
+			continue
+		}
+		logrus.Debugf("Binding %s into overlay", path)
+		if err := unix.Mount(path, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return errors.Wrapf(err, "binding %s into overlay", path)
+		}
+		bound = append(bound, path)
+	}
+	return nil
+}
+
+// Unmount unmounts the overlay, along with everything mounted below it.
+func (o *Overlay) Unmount() error {
+	if err := unix.Unmount(o.merged(), unix.MNT_DETACH); err != nil {
+		return errors.Wrap(err, "unmounting overlay")
+	}
+	return nil
+}
+
+// Apply moves the changes of the unmounted overlay to the root directory and
+// returns the paths which changed and the paths which were deleted. The
+// deleted paths include the content of deleted directories, like a walk of
+// the filesystem would find.
+func (o *Overlay) Apply() ([]string, []string, error) {
+	var changed, deleted []string
+	var dirs []string
+	upper := o.upper()
+
+	err := filepath.WalkDir(upper, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == upper {
+			return nil
+		}
+		rel, err := filepath.Rel(upper, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(o.root, rel)
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if isWhiteout(fi) {
+			below, err := removeAll(target)
+			if err != nil {
+				return err
+			}
+			deleted = append(append(deleted, target), below...)
+			return nil
+		}
+		changed = append(changed, target)
+
+		if fi.IsDir() {
+			if isOpaque(path) {
+				if err := removeHidden(path, target, &deleted); err != nil {
+					return err
+				}
+			}
+			dirs = append(dirs, path)
+			return applyDir(fi, target)
+		}
+		if tfi, err := os.Lstat(target); err == nil && tfi.IsDir() {
+			// The directory itself is replaced, only its content is deleted.
+			below, err := removeAll(target)
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, below...)
+		}
+		return moveFile(path, target, fi)
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "applying overlay changes")
+	}
+
+	// Directory times change as their content is moved, so they are set
+	// last, deepest first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(upper, dirs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		fi, err := os.Lstat(dirs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := setTimes(filepath.Join(o.root, rel), fi); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := os.RemoveAll(o.dir); err != nil {
+		logrus.Warnf("Error removing overlay dir %s: %s", o.dir, err)
+	}
+	return changed, deleted, nil
+}
+
+// isWhiteout returns true if fi is a whiteout of overlayfs, a character device
+// with the 0/0 device number.
+func isWhiteout(fi fs.FileInfo) bool {
+	if fi.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+// isOpaque returns true if the directory of the upper directory hides the
+// content of the directory below it.
+func isOpaque(path string) bool {
+	buf := make([]byte, 1)
+	for _, attr := range opaqueXattrs {
+		if n, err := unix.Lgetxattr(path, attr, buf); err == nil && n == 1 && buf[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
+
+func isBelowAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if util.HasFilepathPrefix(path, dir, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAll removes path and returns the paths below it.
+func removeAll(path string) ([]string, error) {
+	var below []string
+	err := filepath.WalkDir(path, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != path {
+			below = append(below, p)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return below, os.RemoveAll(path)
+}
+
+// removeHidden removes the entries of target which the opaque directory dir
+// doesn't have.
+func removeHidden(dir, target string, deleted *[]string) error {
+	entries, err := os.ReadDir(target)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := os.Lstat(filepath.Join(dir, entry.Name())); err == nil {
+			continue
+		}
+		hidden := filepath.Join(target, entry.Name())
+		below, err := removeAll(hidden)
+		if err != nil {
+			return err
+		}
+		*deleted = append(append(*deleted, hidden), below...)
+	}
+	return nil
+}
+
+// applyDir makes target a directory with the mode and owner of fi.
+func applyDir(fi fs.FileInfo, target string) error {
+	if tfi, err := os.Lstat(target); err == nil && !tfi.IsDir() {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(target, fi.Mode().Perm()); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return setOwnerAndMode(target, fi)
+}
+
+// moveFile moves the file at path of the upper directory to target, copying
+// it if target is on another filesystem.
+func moveFile(path, target string, fi fs.FileInfo) error {
+	err := os.Rename(path, target)
+	if !errors.Is(err, unix.EXDEV) {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var capability []byte
+	switch {
+	case fi.Mode().IsRegular():
+		if err := copyFile(path, target, fi); err != nil {
+			return err
+		}
+		buf := make([]byte, 1024)
+		if n, err := unix.Lgetxattr(path, capabilityXattr, buf); err == nil {
+			capability = buf[:n]
+		}
+	case fi.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(link, target); err != nil {
+			return err
+		}
+	default:
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("unsupported file %s", path)
+		}
+		if err := unix.Mknod(target, stat.Mode, int(stat.Rdev)); err != nil {
+			return err
+		}
+	}
+	if err := setOwnerAndMode(target, fi); err != nil {
+		return err
+	}
+	// The file capabilities are removed once the owner is set.
+	if capability != nil {
+		if err := unix.Lsetxattr(target, capabilityXattr, capability, 0); err != nil {
+			return err
+		}
+	}
+	return setTimes(target, fi)
+}
+
+func copyFile(path, target string, fi fs.FileInfo) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dest, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
+
+func setOwnerAndMode(target string, fi fs.FileInfo) error {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := os.Lchown(target, int(stat.Uid), int(stat.Gid)); err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	// Mode bits like setuid are cleared by chown, so the mode is set last.
+	return unix.Chmod(target, stat.Mode&07777)
+}
+
+func setTimes(target string, fi fs.FileInfo) error {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	times := []unix.Timespec{unix.NsecToTimespec(stat.Atim.Nano()), unix.NsecToTimespec(stat.Mtim.Nano())}
+	return unix.UtimesNanoAt(unix.AT_FDCWD, target, times, unix.AT_SYMLINK_NOFOLLOW)
+}
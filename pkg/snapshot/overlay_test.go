@@ -0,0 +1,127 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/GoogleContainerTools/kaniko/pkg/util"
+	"github.com/GoogleContainerTools/kaniko/testutil"
+)
+
+func TestOverlay(t *testing.T) {
+	testDir, err := setUpTestDir(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay := NewOverlay(testDir, filepath.Join(t.TempDir(), "overlay"))
+	merged, err := overlay.Mount()
+	if err != nil {
+		t.Skipf("Unable to mount overlay: %s", err)
+	}
+
+	// Change a file, add a directory, delete a directory and replace
+	// another one, which makes it opaque.
+	if err := os.WriteFile(filepath.Join(merged, "foo"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.SetupFiles(merged, map[string]string{"new/file": "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(merged, "bar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(merged, "baz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.SetupFiles(merged, map[string]string{"baz/other": "other"}); err != nil {
+		t.Fatal(err)
+	}
+	testutil.CheckNoError(t, overlay.Unmount())
+
+	changed, deleted, err := overlay.Apply()
+	testutil.CheckNoError(t, err)
+	sortAndCompareFilepaths(t, testDir, []string{"foo", "new", "new/file", "baz", "baz/other"}, changed)
+	sortAndCompareFilepaths(t, testDir, []string{"bar", "bar/bat", "baz/file"}, deleted)
+
+	for path, want := range map[string]string{"foo": "changed", "new/file": "new", "baz/other": "other", "kaniko/file": "file"} {
+		content, err := os.ReadFile(filepath.Join(testDir, path))
+		testutil.CheckErrorAndDeepEqual(t, false, err, want, string(content))
+	}
+	for _, path := range []string{"bar", "baz/file"} {
+		if _, err := os.Lstat(filepath.Join(testDir, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be deleted, got %v", path, err)
+		}
+	}
+
+	// The snapshot of the changes only has whiteouts for the deleted
+	// directory, not its content.
+	snapshotPathPrefix = t.TempDir()
+	snapshotter := NewSnapshotter(NewLayeredMap(util.Hasher()), testDir)
+	tarPath, err := snapshotter.TakeSnapshotChanges(changed, deleted)
+	testutil.CheckNoError(t, err)
+	files, err := listFilesInTar(tarPath)
+	testutil.CheckNoError(t, err)
+
+	prefix := strings.TrimLeft(testDir, "/")
+	var got []string
+	for _, f := range files {
+		if strings.HasPrefix(f, prefix+"/") {
+			got = append(got, strings.TrimPrefix(f, prefix+"/"))
+		}
+	}
+	sort.Strings(got)
+	want := []string{"", ".wh.bar", "baz/", "baz/.wh.file", "baz/other", "foo", "new/", "new/file"}
+	testutil.CheckDeepEqual(t, want, got)
+}
+
+func TestOverlay_Metadata(t *testing.T) {
+	testDir, err := setUpTestDir(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay := NewOverlay(testDir, filepath.Join(t.TempDir(), "overlay"))
+	merged, err := overlay.Mount()
+	if err != nil {
+		t.Skipf("Unable to mount overlay: %s", err)
+	}
+
+	// Changing the metadata of a file keeps its content.
+	if err := os.Chmod(filepath.Join(merged, "foo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Directories of the lower directory aren't renamed in place, tools like
+	// mv copy them instead.
+	err = os.Rename(filepath.Join(merged, "bar"), filepath.Join(merged, "renamed"))
+	testutil.CheckDeepEqual(t, true, errors.Is(err, syscall.EXDEV))
+	testutil.CheckNoError(t, overlay.Unmount())
+
+	changed, deleted, err := overlay.Apply()
+	testutil.CheckNoError(t, err)
+	sortAndCompareFilepaths(t, testDir, []string{"foo"}, changed)
+	testutil.CheckDeepEqual(t, 0, len(deleted))
+	content, err := os.ReadFile(filepath.Join(testDir, "foo"))
+	testutil.CheckErrorAndDeepEqual(t, false, err, "baz1", string(content))
+	fi, err := os.Stat(filepath.Join(testDir, "foo"))
+	testutil.CheckErrorAndDeepEqual(t, false, err, os.FileMode(0o600), fi.Mode().Perm())
+}
@@ -142,6 +142,63 @@ func (s *Snapshotter) TakeSnapshotFS() (string, error) {
 	return f.Name(), nil
 }
 
+// TakeSnapshotChanges creates a tarball of the given changed and deleted files, like the ones an
+// Overlay returns, without walking the filesystem.
+func (s *Snapshotter) TakeSnapshotChanges(changed, deleted []string) (string, error) {
+	f, err := os.CreateTemp(s.getSnashotPathPrefix(), "")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	logrus.Info("Taking snapshot of changed files...")
+	s.l.Snapshot()
+
+	resolvedFiles, err := filesystem.ResolvePaths(changed, s.ignorelist)
+	if err != nil {
+		return "", err
+	}
+	filesToAdd := []string{}
+	for _, path := range resolvedFiles {
+		if util.CheckIgnoreList(path) {
+			logrus.Debugf("Not adding %s to layer, as it's ignored", path)
+			continue
+		}
+		filesToAdd = append(filesToAdd, path)
+	}
+	deletedPaths := map[string]struct{}{}
+	for _, path := range deleted {
+		if !util.CheckIgnoreList(path) {
+			deletedPaths[path] = struct{}{}
+		}
+	}
+
+	logrus.Debugf("Adding to layer: %v", filesToAdd)
+	logrus.Debugf("Deleting in layer: %v", deletedPaths)
+
+	for _, file := range filesToAdd {
+		if err := s.l.Add(file); err != nil {
+			return "", fmt.Errorf("Unable to add file %s to layered map: %w", file, err)
+		}
+	}
+	for file := range deletedPaths {
+		if err := s.l.AddDelete(file); err != nil {
+			return "", fmt.Errorf("Unable to whiteout file %s in layered map: %w", file, err)
+		}
+	}
+
+	filesToWhiteout := removeObsoleteWhiteouts(deletedPaths)
+	sort.Strings(filesToAdd)
+	sort.Strings(filesToWhiteout)
+
+	t := util.NewTar(f)
+	defer t.Close()
+	if err := writeToTar(t, filesToAdd, filesToWhiteout); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
 func (s *Snapshotter) getSnashotPathPrefix() string {
 	if snapshotPathPrefix == "" {
 		return config.KanikoDir
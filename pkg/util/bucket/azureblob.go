@@ -0,0 +1,55 @@
+/*
+Copyright 2026 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// NewAzureBlobClient returns a client for the storage account of the given
+// Azure blob storage URL, authenticated with the account key from the
+// AZURE_STORAGE_ACCESS_KEY environment variable, along with the parts of the
+// URL.
+func NewAzureBlobClient(blobURL string) (*azblob.Client, azblob.URLParts, error) {
+	// Get Azure_STORAGE_ACCESS_KEY from environment variables
+	accountKey := os.Getenv("AZURE_STORAGE_ACCESS_KEY")
+	if len(accountKey) == 0 {
+		return nil, azblob.URLParts{}, errors.New("AZURE_STORAGE_ACCESS_KEY environment variable is not set")
+	}
+
+	// Get storage accountName for Azure Blob Storage
+	parts, err := azblob.ParseURL(blobURL)
+	if err != nil {
+		return nil, parts, err
+	}
+	accountName := strings.Split(parts.Host, ".")[0]
+
+	// Generate credential with accountName and accountKey
+	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	if err != nil {
+		return nil, parts, err
+	}
+	client, err := azblob.NewClientWithSharedKeyCredential(parts.Scheme+"://"+parts.Host+"/", credential, nil)
+	if err != nil {
+		return nil, parts, err
+	}
+	return client, parts, nil
+}
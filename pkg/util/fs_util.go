@@ -20,7 +20,11 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"math"
@@ -91,6 +95,12 @@ var skipKanikoDir = otiai10Cpy.Options{
 type FileContext struct {
 	Root          string
 	ExcludedFiles []string
+	// InstructionExcludes are the patterns of an instruction, like
+	// COPY --exclude, which apply on top of ExcludedFiles.
+	InstructionExcludes []string
+	// NamedContexts are the directories of the build contexts given a name
+	// with --build-context, which --from can refer to.
+	NamedContexts map[string]string
 }
 
 type ExtractFunction func(string, *tar.Header, string, io.Reader) error
@@ -624,7 +634,8 @@ func AddVolumePathToIgnoreList(path string) {
 //  1. If <src> is a remote file URL:
 //     - destination will have permissions of 0600 by default if not specified with chmod
 //     - If remote file has HTTP Last-Modified header, we set the mtime of the file to that timestamp
-func DownloadFileToDest(rawurl, dest string, uid, gid int64, chmod fs.FileMode) error {
+//     - If a checksum is given, dest is only created if the file matches it
+func DownloadFileToDest(rawurl, dest string, uid, gid int64, chmod fs.FileMode, checksum string) error {
 	resp, err := http.Get(rawurl) //nolint:noctx
 	if err != nil {
 		return err
@@ -635,7 +646,18 @@ func DownloadFileToDest(rawurl, dest string, uid, gid int64, chmod fs.FileMode)
 		return fmt.Errorf("invalid response status %d", resp.StatusCode)
 	}
 
-	if err := CreateFile(dest, resp.Body, chmod, uint32(uid), uint32(gid)); err != nil {
+	body := io.Reader(resp.Body)
+	if checksum != "" {
+		verified, err := verifyChecksum(resp.Body, checksum)
+		if err != nil {
+			return errors.Wrapf(err, "verifying %s", rawurl)
+		}
+		defer os.Remove(verified.Name())
+		defer verified.Close()
+		body = verified
+	}
+
+	if err := CreateFile(dest, body, chmod, uint32(uid), uint32(gid)); err != nil {
 		return err
 	}
 	mTime := time.Time{}
@@ -648,6 +670,58 @@ func DownloadFileToDest(rawurl, dest string, uid, gid int64, chmod fs.FileMode)
 	return os.Chtimes(dest, mTime, mTime)
 }
 
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// ValidateChecksum returns an error if checksum is not of the form
+// <algorithm>:<hex> with a supported algorithm.
+func ValidateChecksum(checksum string) error {
+	algorithm, encoded, ok := strings.Cut(checksum, ":")
+	newHash, supported := checksumAlgorithms[algorithm]
+	if !ok || !supported {
+		return fmt.Errorf("invalid checksum %q: expected sha256, sha384 or sha512 digest", checksum)
+	}
+	if _, err := hex.DecodeString(encoded); err != nil || len(encoded) != 2*newHash().Size() {
+		return fmt.Errorf("invalid checksum %q: malformed %s digest", checksum, algorithm)
+	}
+	return nil
+}
+
+// verifyChecksum writes the content of r to a temporary file and returns it,
+// rewound, if the content matches checksum.
+func verifyChecksum(r io.Reader, checksum string) (*os.File, error) {
+	if err := ValidateChecksum(checksum); err != nil {
+		return nil, err
+	}
+	algorithm, expected, _ := strings.Cut(checksum, ":")
+	h := checksumAlgorithms[algorithm]()
+
+	f, err := os.CreateTemp(config.KanikoDir, "download-")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating temporary file")
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		cleanup()
+		return nil, errors.Wrap(err, "downloading file")
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != strings.ToLower(expected) {
+		cleanup()
+		return nil, fmt.Errorf("checksum mismatch: expected %s, got %s:%s", checksum, algorithm, actual)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return f, nil
+}
+
 // DetermineTargetFileOwnership returns the user provided uid/gid combination.
 // If they are set to -1, the uid/gid from the original file is used.
 func DetermineTargetFileOwnership(fi os.FileInfo, uid, gid int64) (int64, int64) {
@@ -802,12 +876,37 @@ func (c FileContext) ExcludesFile(path string) bool {
 			return false
 		}
 	}
-	match, err := patternmatcher.Matches(path, c.ExcludedFiles)
-	if err != nil {
-		logrus.Errorf("Error matching, including %s in build: %v", path, err)
-		return false
+	// The patterns are matched separately, so that an instruction can't
+	// include a file again which is excluded from the context.
+	for _, patterns := range [][]string{c.ExcludedFiles, c.InstructionExcludes} {
+		match, err := patternmatcher.Matches(path, patterns)
+		if err != nil {
+			logrus.Errorf("Error matching, including %s in build: %v", path, err)
+			return false
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// WithExcludes returns a copy of the context which also excludes the files
+// matching patterns. Like in .dockerignore, patterns are relative to the root.
+func (c FileContext) WithExcludes(patterns []string) FileContext {
+	c.InstructionExcludes = append([]string{}, c.InstructionExcludes...)
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		invert := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(filepath.Clean(strings.TrimPrefix(pattern, "!")), "/")
+		if invert {
+			pattern = "!" + pattern
+		}
+		c.InstructionExcludes = append(c.InstructionExcludes, pattern)
 	}
-	return match
+	return c
 }
 
 // HasFilepathPrefix checks if the given file path begins with prefix
@@ -972,6 +972,30 @@ func Test_correctDockerignoreFileIsUsed(t *testing.T) {
 	}
 }
 
+func TestFileContext_WithExcludes(t *testing.T) {
+	fileContext := FileContext{Root: "/context", ExcludedFiles: []string{"secret"}}.
+		WithExcludes([]string{"*.md", "/tests/", "!secret", ""})
+
+	testutil.CheckDeepEqual(t, []string{"*.md", "tests", "!secret"}, fileContext.InstructionExcludes)
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{path: "README.md", excluded: true},
+		{path: "/context/README.md", excluded: true},
+		{path: "docs/README.md", excluded: false},
+		{path: "tests/unit/main_test.go", excluded: true},
+		{path: "main.go", excluded: false},
+		// Instruction patterns can't include files excluded from the context.
+		{path: "secret", excluded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			testutil.CheckDeepEqual(t, tt.excluded, fileContext.ExcludesFile(tt.path))
+		})
+	}
+}
+
 func Test_CopyFile_skips_self(t *testing.T) {
 	t.Parallel()
 	tempDir := t.TempDir()